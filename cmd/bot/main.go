@@ -6,13 +6,17 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/vibin/whatsapp-llm-bot/internal/adapters/primary/http"
 	"github.com/vibin/whatsapp-llm-bot/internal/adapters/primary/whatsapp"
+	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/document"
 	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/llm"
+	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/speech"
 	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/storage"
+	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/transcription"
 	"github.com/vibin/whatsapp-llm-bot/internal/adapters/secondary/webhook"
 	"github.com/vibin/whatsapp-llm-bot/internal/config"
 	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
@@ -45,22 +49,22 @@ func main() {
 	// Create WhatsApp logger adapter
 	waLogger := &whatsmeowLogger{logger: logger}
 
-	// Initialize components
-	messageRepo := storage.NewMemoryRepository()
+	// Initialize message repository
+	messageRepo, err := newMessageRepository(cfg.Storage, logger)
+	if err != nil {
+		logger.Error("Failed to create message repository", "error", err)
+		os.Exit(1)
+	}
 
-	// Parse timeout
-	timeout, err := time.ParseDuration(cfg.Ollama.Timeout)
+	summaryRepo := newSummaryRepository(messageRepo)
+	documentRepo := newDocumentRepository(messageRepo)
+	knowledgeRepo, err := newKnowledgeRepository(messageRepo)
 	if err != nil {
-		logger.Error("Invalid timeout format, using default 30s", "error", err)
-		timeout = 30 * time.Second
+		logger.Error("Failed to create knowledge repository", "error", err)
+		os.Exit(1)
 	}
 
-	llmProvider, err := llm.NewOllamaProvider(
-		cfg.Ollama.URL,
-		cfg.Ollama.Model,
-		cfg.Ollama.Temperature,
-		timeout,
-	)
+	llmProvider, err := newLLMProvider(cfg, logger)
 	if err != nil {
 		logger.Error("Failed to create LLM provider", "error", err)
 		os.Exit(1)
@@ -71,6 +75,10 @@ func main() {
 		logger.Warn("LLM service is not available, but continuing anyway")
 	}
 
+	// Queue requests so bursts of messages do not overload the LLM host
+	llmQueue := services.NewLLMQueue(llmProvider, cfg.LLM.Queue, logger)
+	llmProvider = llmQueue.Provider()
+
 	// Initialize group manager
 	groupMgr := services.NewGroupService(configStore)
 	if err := groupMgr.SyncWithConfig(); err != nil {
@@ -88,9 +96,13 @@ func main() {
 		logger.Error("Failed to create WhatsApp client", "error", err)
 		os.Exit(1)
 	}
+	waClient.UpdateFFmpegPath(cfg.Speech.FFmpegPath)
+	waClient.UpdateDocuments(cfg.Documents.Enabled)
+	waClient.UpdateTranscription(cfg.Transcription.Enabled)
 
 	// Initialize webhook client
 	webhookClient := webhook.NewClient(30 * time.Second)
+	webhookClient.UpdateRetryConfig(cfg.WebhookRetry)
 
 	// Initialize schedule repository
 	scheduleRepo, err := storage.NewScheduleRepository("/data/schedules.db")
@@ -99,6 +111,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize audit log
+	auditRepo, err := storage.NewAuditRepository("/data/audit.db")
+	if err != nil {
+		logger.Error("Failed to create audit repository", "error", err)
+		os.Exit(1)
+	}
+	defer auditRepo.Close()
+	auditService := services.NewAuditService(auditRepo, logger)
+
 	// Initialize chat service
 	chatService := services.NewChatService(
 		llmProvider,
@@ -110,6 +131,26 @@ func main() {
 		cfg.Webhooks,
 		logger,
 	)
+	chatService.UpdateStreaming(streamSettings(cfg, logger))
+	chatService.UpdatePersonas(cfg.WhatsApp.GroupPersonas)
+	chatService.UpdateDirectMessages(cfg.WhatsApp.DirectMessages)
+	chatService.UpdateVisionModel(visionModel(cfg))
+	chatService.UpdateTranscriber(newTranscriber(cfg.Transcription, logger))
+	chatService.UpdateSpeech(newSpeechSynthesizer(cfg.Speech, logger), cfg.Speech.Modifier)
+	chatService.UpdateDocuments(newDocumentService(cfg.Documents, documentRepo, logger))
+	chatService.UpdateContextBuilder(newContextBuilder(cfg))
+	chatService.UpdatePublicURL(cfg.App.PublicURL)
+	chatService.SetSummaryRepository(enabledSummaries(cfg.Summary, summaryRepo))
+
+	// Initialize knowledge base
+	knowledgeService := services.NewKnowledgeService(knowledgeRepo, logger)
+	knowledgeService.UpdateConfig(cfg.Knowledge, llmQueue.Embedder(newEmbedder(cfg, logger)))
+	chatService.SetKnowledgeService(knowledgeService)
+
+	// Initialize rate limits
+	rateLimiter := services.NewRateLimiter(cfg.RateLimit, logger)
+	chatService.SetRateLimiter(rateLimiter)
+	chatService.SetLLMQueue(llmQueue)
 
 	// Start WhatsApp client
 	logger.Info("Starting WhatsApp client")
@@ -126,11 +167,23 @@ func main() {
 
 	// Initialize scheduler service
 	schedulerService := services.NewSchedulerService(scheduleRepo, webhookClient, waClient, logger)
+	schedulerService.SetLLMProvider(llmProvider)
+	schedulerService.UpdateWebhooks(cfg.Webhooks)
 	if err := schedulerService.Start(ctx); err != nil {
 		logger.Error("Failed to start scheduler", "error", err)
 	}
 	defer schedulerService.Stop()
 
+	// Expose tools to the LLM
+	if cfg.Tools.Enabled {
+		chatService.UpdateTools(newToolRegistry(cfg.Tools, schedulerService, auditService, chatService), cfg.Tools.MaxIterations)
+	}
+
+	// Initialize summary service
+	summaryService := services.NewSummaryService(llmProvider, messageRepo, summaryRepo, groupMgr, cfg.Summary, logger)
+	summaryService.UpdateEnabled(ctx, cfg.Summary.Enabled)
+	defer summaryService.Stop()
+
 	// Initialize presence service
 	presenceService := services.NewPresenceService(logger)
 	if err := presenceService.Start(ctx); err != nil {
@@ -157,13 +210,21 @@ func main() {
 	}
 
 	// Initialize HTTP server
-	httpHandlers := http.NewHandlers(waClient, groupMgr, configStore, logger)
-	scheduleHandlers := http.NewScheduleHandlers(schedulerService)
+	httpHandlers := http.NewHandlers(waClient, groupMgr, configStore, auditService, logger)
+	scheduleHandlers := http.NewScheduleHandlers(schedulerService, auditService)
 	presenceHandlers := http.NewPresenceHandlers(presenceService, func(jid string, priority int) error {
 		subscriptionMgr.QueueSubscription(jid, priority)
 		return nil
 	})
-	httpServer := http.NewServer(cfg.App.Port, httpHandlers, scheduleHandlers, presenceHandlers, logger)
+	summaryHandlers := http.NewSummaryHandlers(summaryService)
+	knowledgeHandlers := http.NewKnowledgeHandlers(knowledgeService)
+	callbackHandlers := http.NewCallbackHandlers(chatService)
+	messagingService := services.NewMessagingService(waClient, groupMgr, messageRepo, logger)
+	messagingService.UpdateDirectMessages(cfg.WhatsApp.DirectMessages)
+	messagingHandlers := http.NewMessagingHandlers(messagingService)
+	auditHandlers := http.NewAuditHandlers(auditService)
+	httpServer := http.NewServer(cfg.App.Port, httpHandlers, scheduleHandlers, presenceHandlers, summaryHandlers, knowledgeHandlers, callbackHandlers, messagingHandlers, auditHandlers, logger)
+	httpServer.UpdateAPIConfig(cfg.API)
 
 	if err := httpServer.Start(ctx); err != nil {
 		logger.Error("Failed to start HTTP server", "error", err)
@@ -179,7 +240,34 @@ func main() {
 
 		// Update chat service with new webhook configs and trigger words
 		chatService.UpdateWebhooks(newConfig.Webhooks)
+		chatService.UpdatePublicURL(newConfig.App.PublicURL)
+		schedulerService.UpdateWebhooks(newConfig.Webhooks)
+		webhookClient.UpdateRetryConfig(newConfig.WebhookRetry)
+		httpServer.UpdateAPIConfig(newConfig.API)
 		chatService.UpdateTriggerWords(newConfig.WhatsApp.TriggerWords)
+		chatService.UpdateStreaming(streamSettings(newConfig, logger))
+		chatService.UpdatePersonas(newConfig.WhatsApp.GroupPersonas)
+		chatService.UpdateDirectMessages(newConfig.WhatsApp.DirectMessages)
+		messagingService.UpdateDirectMessages(newConfig.WhatsApp.DirectMessages)
+		waClient.UpdateFFmpegPath(newConfig.Speech.FFmpegPath)
+		waClient.UpdateDocuments(newConfig.Documents.Enabled)
+		waClient.UpdateTranscription(newConfig.Transcription.Enabled)
+		chatService.UpdateVisionModel(visionModel(newConfig))
+		chatService.UpdateTranscriber(newTranscriber(newConfig.Transcription, logger))
+		chatService.UpdateSpeech(newSpeechSynthesizer(newConfig.Speech, logger), newConfig.Speech.Modifier)
+		chatService.UpdateDocuments(newDocumentService(newConfig.Documents, documentRepo, logger))
+		chatService.UpdateContextBuilder(newContextBuilder(newConfig))
+		summaryService.UpdateConfig(newConfig.Summary)
+		summaryService.UpdateEnabled(ctx, newConfig.Summary.Enabled)
+		chatService.SetSummaryRepository(enabledSummaries(newConfig.Summary, summaryRepo))
+		rateLimiter.UpdateConfig(newConfig.RateLimit)
+		llmQueue.UpdateConfig(newConfig.LLM.Queue)
+		knowledgeService.UpdateConfig(newConfig.Knowledge, llmQueue.Embedder(newEmbedder(newConfig, logger)))
+		if newConfig.Tools.Enabled {
+			chatService.UpdateTools(newToolRegistry(newConfig.Tools, schedulerService, auditService, chatService), newConfig.Tools.MaxIterations)
+		} else {
+			chatService.UpdateTools(nil, 0)
+		}
 
 		// Sync group manager with new allowed groups
 		if err := groupMgr.SyncWithConfig(); err != nil {
@@ -215,6 +303,217 @@ func main() {
 	logger.Info("Shutdown complete")
 }
 
+// newMessageRepository creates the message repository selected by storage.type
+func newMessageRepository(cfg domain.StorageConfig, logger *slog.Logger) (domain.MessageRepository, error) {
+	switch cfg.Type {
+	case "sqlite":
+		path := cfg.Path
+		if path == "" {
+			path = "/data/messages.db"
+		}
+
+		var maxAge time.Duration
+		if cfg.MaxAge != "" {
+			parsed, err := time.ParseDuration(cfg.MaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("invalid storage max_age: %w", err)
+			}
+			maxAge = parsed
+		}
+
+		logger.Info("Using SQLite message storage",
+			"path", path,
+			"max_messages_per_group", cfg.MaxMessagesPerGroup,
+			"max_age", maxAge)
+		return storage.NewSQLiteRepository(path, cfg.MaxMessagesPerGroup, maxAge)
+	default:
+		logger.Info("Using in-memory message storage")
+		return storage.NewMemoryRepository(), nil
+	}
+}
+
+// enabledSummaries returns the summaries to use in prompts, or nil when they are disabled
+func enabledSummaries(cfg domain.SummaryConfig, summaryRepo domain.SummaryRepository) domain.SummaryRepository {
+	if !cfg.Enabled {
+		return nil
+	}
+	return summaryRepo
+}
+
+// newSummaryRepository stores summaries alongside messages when they are persisted
+func newSummaryRepository(messageRepo domain.MessageRepository) domain.SummaryRepository {
+	if sqliteRepo, ok := messageRepo.(*storage.SQLiteRepository); ok {
+		return sqliteRepo.Summaries()
+	}
+	return storage.NewMemorySummaryRepository()
+}
+
+// newDocumentRepository stores documents alongside messages when they are persisted
+func newDocumentRepository(messageRepo domain.MessageRepository) domain.DocumentRepository {
+	if sqliteRepo, ok := messageRepo.(*storage.SQLiteRepository); ok {
+		return sqliteRepo.Documents()
+	}
+	return storage.NewMemoryDocumentRepository()
+}
+
+// newKnowledgeRepository stores the knowledge base alongside messages when they are persisted,
+// and in its own database otherwise, so ingested documents survive restarts either way
+func newKnowledgeRepository(messageRepo domain.MessageRepository) (domain.KnowledgeRepository, error) {
+	if sqliteRepo, ok := messageRepo.(*storage.SQLiteRepository); ok {
+		return sqliteRepo.Knowledge(), nil
+	}
+
+	knowledgeDB, err := storage.NewSQLiteRepository("/data/knowledge.db", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return knowledgeDB.Knowledge(), nil
+}
+
+// newEmbedder creates the Ollama embedder for the knowledge base, or nil when it is disabled
+func newEmbedder(cfg *domain.Config, logger *slog.Logger) domain.Embedder {
+	if !cfg.Knowledge.Enabled {
+		return nil
+	}
+
+	url := cfg.Knowledge.URL
+	if url == "" {
+		url = cfg.Ollama.URL
+	}
+
+	timeout := 30 * time.Second
+	if cfg.Knowledge.Timeout != "" {
+		timeout = parseTimeout(cfg.Knowledge.Timeout, logger)
+	}
+
+	logger.Info("Using Ollama embeddings for the knowledge base", "url", url, "model", cfg.Knowledge.EmbeddingModel)
+	return llm.NewOllamaEmbedder(url, cfg.Knowledge.EmbeddingModel, timeout)
+}
+
+// newDocumentService creates the document question answering service, or nil when it is disabled
+func newDocumentService(cfg domain.DocumentsConfig, documents domain.DocumentRepository, logger *slog.Logger) *services.DocumentService {
+	if !cfg.Enabled {
+		return nil
+	}
+	return services.NewDocumentService(documents, document.NewExtractor(cfg.PDFToTextPath), cfg, logger)
+}
+
+// newToolRegistry creates the tool registry with the enabled built-in tools
+func newToolRegistry(cfg domain.ToolsConfig, scheduler *services.SchedulerService, audit *services.AuditService, chatService *services.ChatService) *services.ToolRegistry {
+	registry := services.NewToolRegistry()
+
+	builtIns := []services.Tool{
+		&services.ServerTimeTool{},
+		services.NewCreateScheduleTool(scheduler, audit, chatService.WebhookConfigs),
+	}
+	for _, tool := range builtIns {
+		if len(cfg.BuiltIns) == 0 || slices.Contains(cfg.BuiltIns, tool.Definition().Name) {
+			registry.Register(tool)
+		}
+	}
+
+	return registry
+}
+
+// newLLMProvider creates the LLM provider selected by llm.provider
+func newLLMProvider(cfg *domain.Config, logger *slog.Logger) (domain.LLMProvider, error) {
+	switch cfg.LLM.Provider {
+	case "openai":
+		openAI := cfg.LLM.OpenAI
+		timeout := parseTimeout(openAI.Timeout, logger)
+
+		logger.Info("Using OpenAI-compatible LLM provider", "base_url", openAI.BaseURL, "model", openAI.Model)
+		return llm.NewOpenAIProvider(openAI.BaseURL, openAI.APIKey, openAI.Model, openAI.Temperature, timeout)
+	default:
+		timeout := parseTimeout(cfg.Ollama.Timeout, logger)
+
+		logger.Info("Using Ollama LLM provider", "url", cfg.Ollama.URL, "model", cfg.Ollama.Model)
+		return llm.NewOllamaProvider(cfg.Ollama.URL, cfg.Ollama.Model, cfg.Ollama.Temperature, timeout)
+	}
+}
+
+// parseTimeout parses an LLM timeout, falling back to 30s
+func parseTimeout(value string, logger *slog.Logger) time.Duration {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error("Invalid timeout format, using default 30s", "error", err)
+		return 30 * time.Second
+	}
+	return timeout
+}
+
+// newTranscriber creates the voice note transcriber, or nil when transcription is disabled
+func newTranscriber(cfg domain.TranscriptionConfig, logger *slog.Logger) domain.Transcriber {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	timeout := 60 * time.Second
+	if cfg.Timeout != "" {
+		timeout = parseTimeout(cfg.Timeout, logger)
+	}
+
+	logger.Info("Using Whisper transcription", "url", cfg.URL, "model", cfg.Model, "language", cfg.Language)
+	return transcription.NewWhisperClient(cfg.URL, cfg.Model, cfg.Language, timeout)
+}
+
+// newSpeechSynthesizer creates the text-to-speech backend, or nil when voice replies are disabled
+func newSpeechSynthesizer(cfg domain.SpeechConfig, logger *slog.Logger) domain.SpeechSynthesizer {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	timeout := 60 * time.Second
+	if cfg.Timeout != "" {
+		timeout = parseTimeout(cfg.Timeout, logger)
+	}
+
+	logger.Info("Using TTS voice replies", "url", cfg.URL, "voice", cfg.Voice)
+	return speech.NewTTSClient(cfg.URL, cfg.Voice, cfg.FFmpegPath, timeout)
+}
+
+// newContextBuilder creates the context builder from the Ollama context budget settings
+func newContextBuilder(cfg *domain.Config) *services.ContextBuilder {
+	defaultModel := cfg.Ollama.Model
+	if cfg.LLM.Provider == "openai" {
+		defaultModel = cfg.LLM.OpenAI.Model
+	}
+
+	return services.NewContextBuilder(
+		defaultModel,
+		cfg.Ollama.ContextTokens,
+		cfg.Ollama.ModelContextTokens,
+		cfg.Ollama.MaxMessageTokens,
+	)
+}
+
+// visionModel returns the vision model of the active LLM provider
+func visionModel(cfg *domain.Config) string {
+	if cfg.LLM.Provider == "openai" {
+		return cfg.LLM.OpenAI.VisionModel
+	}
+	return cfg.Ollama.VisionModel
+}
+
+// streamSettings returns the streaming settings of the active LLM provider
+func streamSettings(cfg *domain.Config, logger *slog.Logger) (bool, time.Duration) {
+	enabled, value := cfg.Ollama.Stream, cfg.Ollama.StreamEditInterval
+	if cfg.LLM.Provider == "openai" {
+		enabled, value = cfg.LLM.OpenAI.Stream, cfg.LLM.OpenAI.StreamEditInterval
+	}
+
+	if value == "" {
+		return enabled, 0
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error("Invalid stream_edit_interval format, using default", "error", err)
+		return enabled, 0
+	}
+	return enabled, interval
+}
+
 // setupLogger creates and configures the logger
 func setupLogger(level string) *slog.Logger {
 	var logLevel slog.Level
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
+)
+
+// maxAuditLimit caps the entries returned by a single audit request
+const maxAuditLimit = 1000
+
+// AuditHandlers contains audit log HTTP handlers
+type AuditHandlers struct {
+	audit *services.AuditService
+}
+
+// NewAuditHandlers creates new audit handlers
+func NewAuditHandlers(audit *services.AuditService) *AuditHandlers {
+	return &AuditHandlers{
+		audit: audit,
+	}
+}
+
+// GetAuditLog returns audit entries, newest first. Supported filters are actor, action
+// (exact, or a prefix such as "webhook."), target, since and until (RFC 3339) and limit.
+func (h *AuditHandlers) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			http.Error(w, "until must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 || filter.Limit > maxAuditLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.audit.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if entries == nil {
+		entries = make([]*domain.AuditEntry, 0)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
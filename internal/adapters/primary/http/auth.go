@@ -0,0 +1,289 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// roleRanks orders the API roles; a role may do everything a lower ranked role may
+var roleRanks = map[string]int{
+	domain.RoleViewer:   1,
+	domain.RoleOperator: 2,
+	domain.RoleAdmin:    3,
+}
+
+// principal is the authenticated caller of an API request
+type principal struct {
+	name      string
+	role      string
+	method    string // "token", "basic", "oidc" or "anonymous"
+	anonymous bool
+	address   string // client address, behind trusted proxies the forwarded one
+}
+
+// principalKey is the request context key of the principal
+type principalKey struct{}
+
+// principalFrom returns the caller of a request, or nil outside authenticated routes
+func principalFrom(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalKey{}).(*principal)
+	return p
+}
+
+// actorOf names the caller of a request for the audit log. Anonymous callers are told
+// apart by their address.
+func actorOf(r *http.Request) string {
+	caller := principalFrom(r.Context())
+	if caller == nil {
+		return "unknown"
+	}
+	if caller.anonymous {
+		return caller.name + " (" + caller.address + ")"
+	}
+	return caller.name
+}
+
+// clientAddress returns the address of the client. X-Forwarded-For is only believed when the
+// request comes from a trusted proxy; its entries are read from the right, skipping further
+// trusted proxies, as anything left of them may be made up by the client.
+func clientAddress(r *http.Request, trustedProxies []netip.Prefix) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !trusted(peer, trustedProxies) {
+		return peer
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		if !trusted(hop, trustedProxies) {
+			return hop
+		}
+		peer = hop
+	}
+	return peer
+}
+
+// trusted reports whether an address belongs to a trusted proxy
+func trusted(address string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses proxy IPs and CIDRs, skipping invalid entries
+func parseTrustedProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// authenticator checks the credentials of API requests: static bearer tokens, basic auth
+// users and OIDC bearer tokens
+type authenticator struct {
+	tokens         []domain.APIToken
+	users          []domain.APIUser
+	oidc           *oidcVerifier
+	anonymousAdmin bool
+	trustedProxies []netip.Prefix
+	logger         *slog.Logger
+	mu             sync.RWMutex
+}
+
+// update replaces the accepted credentials
+func (a *authenticator) update(cfg domain.APIConfig) {
+	var oidc *oidcVerifier
+	if cfg.OIDC.Issuer != "" {
+		oidc = newOIDCVerifier(cfg.OIDC)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens = cfg.Tokens
+	a.users = cfg.Users
+	a.oidc = oidc
+	a.anonymousAdmin = cfg.AllowAnonymous
+	a.trustedProxies = parseTrustedProxies(cfg.TrustedProxies)
+}
+
+// open reports whether no credentials are configured, leaving the admin API unauthenticated
+func (a *authenticator) open() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens) == 0 && len(a.users) == 0 && a.oidc == nil
+}
+
+// authenticate returns the caller of a request, or nil if its credentials are missing or wrong
+func (a *authenticator) authenticate(r *http.Request) *principal {
+	a.mu.RLock()
+	tokens, users, oidc := a.tokens, a.users, a.oidc
+	a.mu.RUnlock()
+
+	if name, password, ok := r.BasicAuth(); ok {
+		for _, user := range users {
+			if user.Name == name && checkPassword(user, password) {
+				return &principal{name: user.Name, role: roleOrDefault(user.Role, domain.RoleViewer), method: "basic"}
+			}
+		}
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	for _, candidate := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate.Token)) == 1 {
+			return &principal{name: candidate.Name, role: roleOrDefault(candidate.Role, domain.RoleOperator), method: "token"}
+		}
+	}
+	if oidc != nil && strings.Count(token, ".") == 2 {
+		p, err := oidc.verify(r.Context(), token)
+		if err != nil {
+			a.logger.Debug("Rejected OIDC token", "error", err)
+			return nil
+		}
+		return p
+	}
+	return nil
+}
+
+// require returns a middleware that admits callers with at least the given role. While no
+// credentials are configured, everyone is admitted anonymously: as a viewer, or as an admin
+// if allow_anonymous is set.
+func (a *authenticator) require(role string) func(http.HandlerFunc) http.HandlerFunc {
+	return a.middleware(role, true)
+}
+
+// requireCredentials is like require but never admits anonymous callers
+func (a *authenticator) requireCredentials(role string) func(http.HandlerFunc) http.HandlerFunc {
+	return a.middleware(role, false)
+}
+
+func (a *authenticator) middleware(role string, allowAnonymous bool) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var caller *principal
+			if allowAnonymous && a.open() {
+				caller = a.anonymous()
+			} else {
+				caller = a.authenticate(r)
+			}
+
+			if caller == nil {
+				a.challenge(w)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			a.mu.RLock()
+			caller.address = clientAddress(r, a.trustedProxies)
+			a.mu.RUnlock()
+			if roleRanks[caller.role] < roleRanks[role] && caller.anonymous {
+				http.Error(w, "Forbidden: configure api credentials or set api.allow_anonymous", http.StatusForbidden)
+				return
+			}
+			if roleRanks[caller.role] < roleRanks[role] {
+				http.Error(w, "Forbidden: requires the "+role+" role", http.StatusForbidden)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, caller))
+			if r.Method == http.MethodGet {
+				next(w, r)
+				return
+			}
+
+			// Record who changed what
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(recorder, r)
+			a.logger.Info("API change",
+				"actor", caller.name,
+				"role", caller.role,
+				"auth", caller.method,
+				"address", caller.address,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", recorder.status)
+		}
+	}
+}
+
+// anonymous returns the caller of a request while no credentials are configured
+func (a *authenticator) anonymous() *principal {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	role := domain.RoleViewer
+	if a.anonymousAdmin {
+		role = domain.RoleAdmin
+	}
+	return &principal{name: "anonymous", role: role, method: "anonymous", anonymous: true}
+}
+
+// challenge asks browsers for basic auth credentials when users are configured
+func (a *authenticator) challenge(w http.ResponseWriter) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(a.users) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="whatsapp-llm-bot", charset="UTF-8"`)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="whatsapp-llm-bot"`)
+}
+
+// checkPassword compares a basic auth password with a user's bcrypt hash or plain password
+func checkPassword(user domain.APIUser, password string) bool {
+	if user.PasswordHash != "" {
+		return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+	}
+	return user.Password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) == 1
+}
+
+// roleOrDefault returns role, or fallback if it is empty
+func roleOrDefault(role, fallback string) string {
+	if role == "" {
+		return fallback
+	}
+	return role
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
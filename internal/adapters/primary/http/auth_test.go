@@ -0,0 +1,338 @@
+package http
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// serve calls a handler protected by the given middleware and returns the response status
+func serve(middleware func(http.HandlerFunc) http.HandlerFunc, method string, setup func(*http.Request)) (int, *principal) {
+	var caller *principal
+	handler := middleware(func(w http.ResponseWriter, r *http.Request) {
+		caller = principalFrom(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(method, "/api/test", nil)
+	if setup != nil {
+		setup(req)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code, caller
+}
+
+func TestAuthenticator_OpenWithoutCredentials(t *testing.T) {
+	auth := &authenticator{logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+	auth.update(domain.APIConfig{})
+
+	if code, caller := serve(auth.require(domain.RoleViewer), "GET", nil); code != http.StatusNoContent || !caller.anonymous {
+		t.Errorf("Expected anonymous read access, got %d %+v", code, caller)
+	}
+	for _, role := range []string{domain.RoleOperator, domain.RoleAdmin} {
+		if code, _ := serve(auth.require(role), "POST", nil); code != http.StatusForbidden {
+			t.Errorf("Expected anonymous %s access to fail closed, got %d", role, code)
+		}
+	}
+	if code, _ := serve(auth.requireCredentials(domain.RoleOperator), "POST", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected the messaging API to stay closed, got %d", code)
+	}
+
+	auth.update(domain.APIConfig{AllowAnonymous: true})
+	if code, caller := serve(auth.require(domain.RoleAdmin), "POST", nil); code != http.StatusNoContent || caller.role != domain.RoleAdmin {
+		t.Errorf("Expected anonymous admin access with allow_anonymous, got %d %+v", code, caller)
+	}
+}
+
+func TestActorOf_Anonymous(t *testing.T) {
+	auth := &authenticator{logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+	auth.update(domain.APIConfig{})
+
+	var actors []string
+	handler := auth.require(domain.RoleViewer)(func(w http.ResponseWriter, r *http.Request) {
+		actors = append(actors, actorOf(r))
+	})
+
+	request := func(remoteAddr, forwardedFor string) {
+		req := httptest.NewRequest("GET", "/api/test", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		handler(httptest.NewRecorder(), req)
+	}
+
+	request("192.0.2.10:51234", "")
+	// A client cannot choose the address recorded for it
+	request("192.0.2.10:51234", "203.0.113.7")
+
+	// Behind a trusted proxy the forwarded address is used, but not what the client prepended
+	auth.update(domain.APIConfig{TrustedProxies: []string{"10.0.0.0/8"}})
+	request("10.0.0.1:443", "198.51.100.1, 203.0.113.7")
+	request("10.0.0.1:443", "203.0.113.7, 10.0.0.2")
+
+	want := []string{
+		"anonymous (192.0.2.10)",
+		"anonymous (192.0.2.10)",
+		"anonymous (203.0.113.7)",
+		"anonymous (203.0.113.7)",
+	}
+	if strings.Join(actors, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected actors %v, got %v", want, actors)
+	}
+}
+
+func TestAuthenticator_Roles(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &authenticator{logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+	auth.update(domain.APIConfig{
+		Tokens: []domain.APIToken{{Name: "grafana", Token: "t0ken"}},
+		Users: []domain.APIUser{
+			{Name: "alice", PasswordHash: string(hash), Role: domain.RoleAdmin},
+			{Name: "bob", Password: "pw"},
+		},
+	})
+
+	tests := []struct {
+		name  string
+		role  string
+		setup func(*http.Request)
+		want  int
+	}{
+		{"no credentials", domain.RoleViewer, nil, http.StatusUnauthorized},
+		{"wrong password", domain.RoleViewer, func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, http.StatusUnauthorized},
+		{"admin with bcrypt hash", domain.RoleAdmin, func(r *http.Request) { r.SetBasicAuth("alice", "hunter2") }, http.StatusNoContent},
+		{"viewer reads", domain.RoleViewer, func(r *http.Request) { r.SetBasicAuth("bob", "pw") }, http.StatusNoContent},
+		{"viewer cannot operate", domain.RoleOperator, func(r *http.Request) { r.SetBasicAuth("bob", "pw") }, http.StatusForbidden},
+		{"token defaults to operator", domain.RoleOperator, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusNoContent},
+		{"token is not admin", domain.RoleAdmin, func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusForbidden},
+		{"unknown token", domain.RoleViewer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := serve(auth.require(tt.role), "POST", tt.setup); code != tt.want {
+				t.Errorf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_OIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A local stand-in for the OIDC provider
+	var issuer string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer provider.Close()
+	issuer = provider.URL
+
+	sign := func(claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	auth := &authenticator{logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+	auth.update(domain.APIConfig{OIDC: domain.OIDCConfig{Issuer: issuer, Audience: "bot"}})
+
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	token := sign(map[string]interface{}{"iss": issuer, "aud": "bot", "exp": exp, "preferred_username": "carol", "roles": []string{"viewer", "operator"}})
+	code, caller := serve(auth.require(domain.RoleOperator), "POST", bearer(token))
+	if code != http.StatusNoContent || caller.name != "carol" || caller.role != domain.RoleOperator {
+		t.Errorf("Expected carol as operator, got %d %+v", code, caller)
+	}
+
+	viewerToken := sign(map[string]interface{}{"iss": issuer, "aud": []string{"bot"}, "exp": exp, "sub": "dave"})
+	if code, _ := serve(auth.require(domain.RoleOperator), "POST", bearer(viewerToken)); code != http.StatusForbidden {
+		t.Errorf("Expected tokens without roles to be viewers, got %d", code)
+	}
+
+	rejected := map[string]string{
+		"expired":        sign(map[string]interface{}{"iss": issuer, "aud": "bot", "exp": float64(time.Now().Add(-time.Hour).Unix())}),
+		"wrong audience": sign(map[string]interface{}{"iss": issuer, "aud": "other", "exp": exp}),
+		"no audience":    sign(map[string]interface{}{"iss": issuer, "exp": exp}),
+		"wrong issuer":   sign(map[string]interface{}{"iss": "https://evil.example", "aud": "bot", "exp": exp}),
+		"tampered":       token[:len(token)-4] + "AAAA",
+	}
+	for name, token := range rejected {
+		if code, _ := serve(auth.require(domain.RoleViewer), "GET", bearer(token)); code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, code)
+		}
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	server := NewServer(0, nil, nil, nil, nil, nil, nil, nil, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	server.UpdateAPIConfig(domain.APIConfig{CORSOrigins: []string{"https://grafana.example"}})
+
+	handler := server.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for origin, want := range map[string]string{
+		"https://grafana.example": "https://grafana.example",
+		"https://evil.example":    "",
+	} {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestServer_CORSPreflight(t *testing.T) {
+	server := NewServer(0, nil, nil, nil, nil, nil, nil, nil, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	server.UpdateAPIConfig(domain.APIConfig{CORSOrigins: []string{"https://grafana.example"}})
+
+	// Preflight requests reach the CORS headers even though the route only allows POST
+	req := httptest.NewRequest("OPTIONS", "/api/webhooks", nil)
+	req.Header.Set("Origin", "https://grafana.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	server.routes().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected preflight status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://grafana.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the listed origin", got)
+	}
+}
+
+func TestServer_CrossOriginChanges(t *testing.T) {
+	server := NewServer(0, nil, nil, nil, nil, nil, nil, nil, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	server.UpdateAPIConfig(domain.APIConfig{CORSOrigins: []string{"https://grafana.example", "*"}})
+
+	handler := server.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		origin string
+		bearer bool
+		want   int
+	}{
+		{"read from anywhere", "GET", "https://evil.example", false, http.StatusNoContent},
+		{"admin UI", "POST", "http://bot.local", false, http.StatusNoContent},
+		{"listed origin", "POST", "https://grafana.example", false, http.StatusNoContent},
+		{"no origin", "POST", "", false, http.StatusNoContent},
+		{"other origin", "POST", "https://evil.example", false, http.StatusForbidden},
+		{"other origin with a bearer token", "DELETE", "https://evil.example", true, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://bot.local/api/webhooks", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.bearer {
+			req.Header.Set("Authorization", "Bearer t0ken")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHandlers_RequireJSON(t *testing.T) {
+	handlers := NewHandlers(nil, nil, nil, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	req := httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(`{"sub_trigger":"@x","url":"https://evil.example"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	handlers.AddWebhook(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a form-encodable body to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestOIDCVerifier_KeyFetchDoesNotBlock(t *testing.T) {
+	// The provider holds the key request until released
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{}})
+	}))
+	defer provider.Close()
+	defer close(release)
+
+	verifier := newOIDCVerifier(domain.OIDCConfig{Issuer: provider.URL, Audience: "bot", JWKSURL: provider.URL + "/keys"})
+	go verifier.key(context.Background(), "k1")
+	<-requested
+
+	// The lock is free while the keys are fetched, and waiting requests give up with their context
+	locked := make(chan struct{})
+	go func() {
+		verifier.mu.Lock()
+		verifier.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the verifier not to hold its lock during the key fetch")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := verifier.key(ctx, "k1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the waiting request to time out, got %v", err)
+	}
+}
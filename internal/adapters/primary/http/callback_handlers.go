@@ -0,0 +1,63 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
+)
+
+// maxCallbackSize caps the result an async webhook posts to its callback
+const maxCallbackSize = 64 << 20
+
+// CallbackHandlers contains the HTTP handlers async webhooks post their results to
+type CallbackHandlers struct {
+	chat *services.ChatService
+}
+
+// NewCallbackHandlers creates new callback handlers
+func NewCallbackHandlers(chat *services.ChatService) *CallbackHandlers {
+	return &CallbackHandlers{
+		chat: chat,
+	}
+}
+
+// CompleteCallback receives an async webhook's result and replies with it to the message
+// that triggered the webhook. The body has the same format as a synchronous webhook response
+// and must be signed with the webhook's secret, like the bot signs its calls.
+func (h *CallbackHandlers) CompleteCallback(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackSize))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	err = h.chat.CompleteCallback(r.Context(), id, services.CallbackResult{
+		ContentType:        r.Header.Get("Content-Type"),
+		ContentDisposition: r.Header.Get("Content-Disposition"),
+		Timestamp:          r.Header.Get(domain.WebhookTimestampHeader),
+		Signature:          r.Header.Get(domain.WebhookSignatureHeader),
+		Body:               body,
+	})
+	switch {
+	case errors.Is(err, services.ErrCallbackNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, services.ErrCallbackUnauthorized):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, services.ErrInvalidCallback):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
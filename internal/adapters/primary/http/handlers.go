@@ -3,9 +3,12 @@ package http
 import (
 	"encoding/json"
 	"log/slog"
+	"mime"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
 )
 
 // Handlers contains HTTP request handlers
@@ -13,15 +16,17 @@ type Handlers struct {
 	whatsapp    domain.WhatsAppClient
 	groupMgr    domain.GroupManager
 	configStore domain.ConfigStore
+	audit       *services.AuditService
 	logger      *slog.Logger
 }
 
 // NewHandlers creates new HTTP handlers
-func NewHandlers(whatsapp domain.WhatsAppClient, groupMgr domain.GroupManager, configStore domain.ConfigStore, logger *slog.Logger) *Handlers {
+func NewHandlers(whatsapp domain.WhatsAppClient, groupMgr domain.GroupManager, configStore domain.ConfigStore, audit *services.AuditService, logger *slog.Logger) *Handlers {
 	return &Handlers{
 		whatsapp:    whatsapp,
 		groupMgr:    groupMgr,
 		configStore: configStore,
+		audit:       audit,
 		logger:      logger,
 	}
 }
@@ -87,6 +92,10 @@ func (h *Handlers) GetAllowedGroups(w http.ResponseWriter, r *http.Request) {
 
 // UpdateAllowedGroups updates the allowed groups list
 func (h *Handlers) UpdateAllowedGroups(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
 	var req struct {
 		AllowedGroups []string `json:"allowed_groups"`
 	}
@@ -96,11 +105,13 @@ func (h *Handlers) UpdateAllowedGroups(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := h.groupMgr.GetAllowedGroups()
 	if err := h.groupMgr.UpdateAllowedGroups(req.AllowedGroups); err != nil {
 		h.logger.Error("Failed to update allowed groups", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r.Context(), actorOf(r), services.AuditAllowedGroupsUpdate, "allowed_groups", before, req.AllowedGroups)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -109,6 +120,15 @@ func (h *Handlers) UpdateAllowedGroups(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// requireJSON rejects request bodies that are not JSON, which forms on other sites cannot send
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
 // GetStatus returns bot status and connection state
 func (h *Handlers) GetStatus(w http.ResponseWriter, r *http.Request) {
 	authStatus, err := h.whatsapp.GetAuthStatus(r.Context())
@@ -163,14 +183,23 @@ func (h *Handlers) GetWebhooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	webhooks := make([]domain.WebhookConfig, 0, len(cfg.Webhooks))
+	for _, webhook := range cfg.Webhooks {
+		webhooks = append(webhooks, maskWebhook(webhook))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"webhooks": cfg.Webhooks,
+		"webhooks": webhooks,
 	})
 }
 
 // AddWebhook adds a new webhook configuration
 func (h *Handlers) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+
 	var webhook domain.WebhookConfig
 	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -182,6 +211,10 @@ func (h *Handlers) AddWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "sub_trigger and url are required", http.StatusBadRequest)
 		return
 	}
+	if webhook.BearerToken != "" && webhook.BasicUsername != "" {
+		http.Error(w, "use either bearer_token or basic auth, not both", http.StatusBadRequest)
+		return
+	}
 
 	h.logger.Debug("Adding webhook", "sub_trigger", webhook.SubTrigger, "url", webhook.URL)
 
@@ -207,15 +240,40 @@ func (h *Handlers) AddWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r.Context(), actorOf(r), services.AuditWebhookAdd, webhook.SubTrigger, nil, maskWebhook(webhook))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": "Webhook added successfully",
-		"webhook": webhook,
+		"webhook": maskWebhook(webhook),
 	})
 }
 
+// maskedSecret replaces webhook credentials in API responses
+const maskedSecret = "********"
+
+// maskWebhook returns a copy of the webhook with its secret, credentials and header values masked
+func maskWebhook(webhook domain.WebhookConfig) domain.WebhookConfig {
+	if webhook.Secret != "" {
+		webhook.Secret = maskedSecret
+	}
+	if webhook.BearerToken != "" {
+		webhook.BearerToken = maskedSecret
+	}
+	if webhook.BasicPassword != "" {
+		webhook.BasicPassword = maskedSecret
+	}
+	if len(webhook.Headers) > 0 {
+		headers := make(map[string]string, len(webhook.Headers))
+		for name := range webhook.Headers {
+			headers[name] = maskedSecret
+		}
+		webhook.Headers = headers
+	}
+	return webhook
+}
+
 // DeleteWebhook removes a webhook configuration
 func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	// Get sub_trigger from query parameters
@@ -233,17 +291,18 @@ func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Find and remove webhook
-	found := false
+	var removed *domain.WebhookConfig
 	newWebhooks := make([]domain.WebhookConfig, 0)
 	for _, webhook := range cfg.Webhooks {
 		if webhook.SubTrigger != subTrigger {
 			newWebhooks = append(newWebhooks, webhook)
 		} else {
-			found = true
+			masked := maskWebhook(webhook)
+			removed = &masked
 		}
 	}
 
-	if !found {
+	if removed == nil {
 		http.Error(w, "Webhook not found", http.StatusNotFound)
 		return
 	}
@@ -256,6 +315,7 @@ func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.audit.Record(r.Context(), actorOf(r), services.AuditWebhookDelete, subTrigger, removed, nil)
 	h.logger.Debug("Webhook deleted", "sub_trigger", subTrigger)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -264,3 +324,119 @@ func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		"message": "Webhook deleted successfully",
 	})
 }
+
+// GetGroupPersona returns the persona configured for a group
+func (h *Handlers) GetGroupPersona(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	cfg, err := h.configStore.Load()
+	if err != nil {
+		h.logger.Error("Failed to load config", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	persona, exists := cfg.WhatsApp.GroupPersonas[groupJID]
+	if !exists {
+		http.Error(w, "Persona not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jid":     groupJID,
+		"persona": persona,
+	})
+}
+
+// UpdateGroupPersona creates or replaces the persona for a group
+func (h *Handlers) UpdateGroupPersona(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var persona domain.GroupPersona
+	if err := json.NewDecoder(r.Body).Decode(&persona); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate persona
+	if persona.Temperature != nil && (*persona.Temperature < 0 || *persona.Temperature > 2) {
+		http.Error(w, "temperature must be between 0 and 2", http.StatusBadRequest)
+		return
+	}
+	if persona.ContextLength < 0 {
+		http.Error(w, "context_length cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := h.configStore.Load()
+	if err != nil {
+		h.logger.Error("Failed to load config", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var before interface{}
+	if existing, exists := cfg.WhatsApp.GroupPersonas[groupJID]; exists {
+		before = existing
+	}
+	if cfg.WhatsApp.GroupPersonas == nil {
+		cfg.WhatsApp.GroupPersonas = make(map[string]domain.GroupPersona)
+	}
+	cfg.WhatsApp.GroupPersonas[groupJID] = persona
+
+	if err := h.configStore.Save(cfg); err != nil {
+		h.logger.Error("Failed to save config", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.audit.Record(r.Context(), actorOf(r), services.AuditPersonaUpdate, groupJID, before, persona)
+	h.logger.Debug("Group persona updated", "jid", groupJID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Persona updated successfully",
+		"persona": persona,
+	})
+}
+
+// DeleteGroupPersona removes the persona for a group, restoring the defaults
+func (h *Handlers) DeleteGroupPersona(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	cfg, err := h.configStore.Load()
+	if err != nil {
+		h.logger.Error("Failed to load config", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	before, exists := cfg.WhatsApp.GroupPersonas[groupJID]
+	if !exists {
+		http.Error(w, "Persona not found", http.StatusNotFound)
+		return
+	}
+
+	delete(cfg.WhatsApp.GroupPersonas, groupJID)
+
+	if err := h.configStore.Save(cfg); err != nil {
+		h.logger.Error("Failed to save config", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.audit.Record(r.Context(), actorOf(r), services.AuditPersonaDelete, groupJID, before, nil)
+	h.logger.Debug("Group persona deleted", "jid", groupJID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Persona deleted successfully",
+	})
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
+)
+
+// maxKnowledgeUploadSize caps a single knowledge base upload
+const maxKnowledgeUploadSize = 4 << 20
+
+// KnowledgeHandlers contains knowledge base HTTP handlers
+type KnowledgeHandlers struct {
+	knowledge *services.KnowledgeService
+}
+
+// NewKnowledgeHandlers creates new knowledge base handlers
+func NewKnowledgeHandlers(knowledge *services.KnowledgeService) *KnowledgeHandlers {
+	return &KnowledgeHandlers{
+		knowledge: knowledge,
+	}
+}
+
+// knowledgeRequest is the JSON body for adding a knowledge document
+type knowledgeRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// GetDocuments returns the documents in a group's knowledge base
+func (h *KnowledgeHandlers) GetDocuments(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	documents, err := h.knowledge.ListDocuments(r.Context(), groupJID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(documents)
+}
+
+// AddDocuments adds documents to a group's knowledge base. It accepts a JSON body
+// {"title", "content"} or a multipart form with one or more Markdown/text "file" fields.
+func (h *KnowledgeHandlers) AddDocuments(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+	r.Body = http.MaxBytesReader(w, r.Body, maxKnowledgeUploadSize)
+
+	var requests []knowledgeRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		parsed, err := readKnowledgeFiles(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = parsed
+	} else {
+		var req knowledgeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+	}
+
+	documents := make([]*domain.KnowledgeDocument, 0, len(requests))
+	for _, req := range requests {
+		if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Content) == "" {
+			http.Error(w, "title and content are required", http.StatusBadRequest)
+			return
+		}
+
+		document, err := h.knowledge.AddDocument(r.Context(), groupJID, req.Title, req.Content)
+		if errors.Is(err, services.ErrKnowledgeDisabled) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		documents = append(documents, document)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(documents)
+}
+
+// DeleteDocument removes a document from a group's knowledge base
+func (h *KnowledgeHandlers) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.knowledge.DeleteDocument(r.Context(), vars["jid"], vars["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchKnowledge returns the chunks that would be added to the prompt for a question
+func (h *KnowledgeHandlers) SearchKnowledge(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	question := r.URL.Query().Get("q")
+	if question == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	chunks, err := h.knowledge.Search(r.Context(), groupJID, question)
+	if errors.Is(err, services.ErrKnowledgeDisabled) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chunks)
+}
+
+// readKnowledgeFiles reads the uploaded files of a multipart form, titled by file name
+func readKnowledgeFiles(r *http.Request) ([]knowledgeRequest, error) {
+	if err := r.ParseMultipartForm(maxKnowledgeUploadSize); err != nil {
+		return nil, errors.New("invalid multipart form")
+	}
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return nil, errors.New("no file uploaded")
+	}
+
+	requests := make([]knowledgeRequest, 0, len(files))
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(data) {
+			return nil, errors.New(header.Filename + " is not a text file")
+		}
+
+		requests = append(requests, knowledgeRequest{
+			Title:   strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename)),
+			Content: string(data),
+		})
+	}
+
+	return requests, nil
+}
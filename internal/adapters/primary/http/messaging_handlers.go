@@ -0,0 +1,162 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
+)
+
+// maxMediaUploadSize caps a file sent through the messaging API
+const maxMediaUploadSize = 64 << 20
+
+// IdempotencyKeyHeader lets clients retry a send without posting the message twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// MessagingHandlers contains the HTTP handlers other systems use to send messages
+type MessagingHandlers struct {
+	messaging *services.MessagingService
+}
+
+// NewMessagingHandlers creates new messaging handlers
+func NewMessagingHandlers(messaging *services.MessagingService) *MessagingHandlers {
+	return &MessagingHandlers{
+		messaging: messaging,
+	}
+}
+
+// sendMessageRequest is the JSON body for sending a message
+type sendMessageRequest struct {
+	ChatJID       string   `json:"chat_jid"`
+	Text          string   `json:"text"`
+	Image         string   `json:"image,omitempty"` // base64, sent with text as the caption
+	MimeType      string   `json:"mime_type,omitempty"`
+	ReplyTo       string   `json:"reply_to,omitempty"` // message ID to quote
+	ReplyToSender string   `json:"reply_to_sender,omitempty"`
+	Mentions      []string `json:"mentions,omitempty"` // phone numbers or JIDs
+}
+
+// SendMessage sends a text message, or an image with a caption, to a chat
+func (h *MessagingHandlers) SendMessage(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUploadSize)
+
+	var req sendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message := &domain.OutgoingMessage{
+		ChatJID:          req.ChatJID,
+		Text:             req.Text,
+		ReplyToMessageID: req.ReplyTo,
+		ReplyToSender:    req.ReplyToSender,
+		Mentions:         req.Mentions,
+	}
+
+	if req.Image != "" {
+		data, err := base64.StdEncoding.DecodeString(req.Image)
+		if err != nil {
+			http.Error(w, "image must be base64 encoded", http.StatusBadRequest)
+			return
+		}
+		mimeType := req.MimeType
+		if mimeType == "" {
+			mimeType = http.DetectContentType(data)
+		}
+		if !strings.HasPrefix(mimeType, "image/") {
+			http.Error(w, "image is not an image", http.StatusBadRequest)
+			return
+		}
+		message.Media = &domain.Media{Type: domain.MediaTypeImage, MimeType: mimeType, Data: data}
+	}
+
+	h.send(w, r, message)
+}
+
+// SendGroupMedia sends an uploaded file to a group. It accepts a multipart form with a "file"
+// field and optional "caption", "reply_to" and "reply_to_sender" fields.
+func (h *MessagingHandlers) SendGroupMedia(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaUploadSize)
+
+	if err := r.ParseMultipartForm(maxMediaUploadSize); err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "No file uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	h.send(w, r, &domain.OutgoingMessage{
+		ChatJID: groupJID,
+		Text:    r.FormValue("caption"),
+		Media: &domain.Media{
+			Type:     uploadMediaType(mimeType),
+			MimeType: mimeType,
+			Data:     data,
+			FileName: filepath.Base(header.Filename),
+		},
+		ReplyToMessageID: r.FormValue("reply_to"),
+		ReplyToSender:    r.FormValue("reply_to_sender"),
+	})
+}
+
+// send sends a message and writes the result, or the earlier result for a repeated idempotency key
+func (h *MessagingHandlers) send(w http.ResponseWriter, r *http.Request, message *domain.OutgoingMessage) {
+	result, err := h.messaging.Send(r.Context(), actorOf(r), r.Header.Get(IdempotencyKeyHeader), message)
+	switch {
+	case errors.Is(err, services.ErrInvalidOutgoingMessage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, services.ErrChatNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, services.ErrIdempotencyKeyInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.Duplicate {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// uploadMediaType returns the WhatsApp media type an uploaded file is sent as; files
+// without one, such as text, are sent as documents
+func uploadMediaType(mimeType string) domain.MediaType {
+	if mediaType, ok := domain.MediaTypeOf(mimeType); ok {
+		return mediaType
+	}
+	return domain.MediaTypeDocument
+}
@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// oidcKeyRefreshInterval limits how often unknown key IDs trigger a JWKS refresh
+	oidcKeyRefreshInterval = time.Minute
+
+	// oidcClockSkew is the tolerance for the expiry and not-before times of tokens
+	oidcClockSkew = time.Minute
+)
+
+// oidcVerifier verifies RS256 ID and access tokens of an OpenID Connect provider against its
+// published keys. A local stand-in such as Dex or mock-oauth2-server works for development.
+type oidcVerifier struct {
+	issuer      string
+	audience    string
+	jwksURL     string
+	roleClaim   string
+	defaultRole string
+	client      *http.Client
+	keys        map[string]*rsa.PublicKey
+	fetched     time.Time
+	refreshing  chan struct{} // closed when the running key refresh is done, nil if none runs
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+// newOIDCVerifier creates a verifier for an OIDC provider; keys are fetched on first use
+func newOIDCVerifier(cfg domain.OIDCConfig) *oidcVerifier {
+	return &oidcVerifier{
+		issuer:      strings.TrimRight(cfg.Issuer, "/"),
+		audience:    cfg.Audience,
+		jwksURL:     cfg.JWKSURL,
+		roleClaim:   roleOrDefault(cfg.RoleClaim, "roles"),
+		defaultRole: roleOrDefault(cfg.DefaultRole, domain.RoleViewer),
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+}
+
+// verify checks a token's signature, issuer, audience and lifetime and returns its subject
+func (v *oidcVerifier) verify(ctx context.Context, token string) (*principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != v.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !containsClaim(claims["aud"], v.audience) {
+		return nil, errors.New("token is not issued for this audience")
+	}
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+
+	return &principal{name: oidcSubject(claims), role: v.role(claims[v.roleClaim]), method: "oidc"}, nil
+}
+
+// role returns the highest known role in a role claim, which may be a string or a list
+func (v *oidcVerifier) role(claim interface{}) string {
+	var values []string
+	switch claim := claim.(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	role := ""
+	for _, value := range values {
+		if roleRanks[value] > roleRanks[role] {
+			role = value
+		}
+	}
+	return roleOrDefault(role, v.defaultRole)
+}
+
+// key returns the provider's public key with the given ID, refreshing the key set if it is unknown.
+// The keys are fetched without holding the lock; concurrent requests wait for the same refresh.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	for {
+		v.mu.Lock()
+		if key, ok := v.keys[kid]; ok {
+			v.mu.Unlock()
+			return key, nil
+		}
+		if refreshing := v.refreshing; refreshing != nil {
+			v.mu.Unlock()
+			select {
+			case <-refreshing:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if !v.fetched.IsZero() && v.now().Sub(v.fetched) < oidcKeyRefreshInterval {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		refreshing := make(chan struct{})
+		v.refreshing = refreshing
+		v.mu.Unlock()
+
+		keys, err := v.fetchKeys(ctx)
+
+		v.mu.Lock()
+		v.fetched = v.now()
+		if err == nil {
+			v.keys = keys
+		}
+		key, ok := v.keys[kid]
+		v.refreshing = nil
+		close(refreshing)
+		v.mu.Unlock()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return key, nil
+	}
+}
+
+// fetchKeys downloads the provider's RSA signing keys, discovering the JWKS URL if needed
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	jwksURL := v.jwksURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("provider does not publish jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// getJSON fetches and decodes a JSON document
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// containsClaim reports whether a string or list claim contains value
+func containsClaim(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, item := range claim {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oidcSubject returns the most readable name of a token's subject
+func oidcSubject(claims map[string]interface{}) string {
+	for _, claim := range []string{"preferred_username", "email", "sub"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -12,12 +12,14 @@ import (
 // ScheduleHandlers contains schedule-related HTTP handlers
 type ScheduleHandlers struct {
 	scheduler *services.SchedulerService
+	audit     *services.AuditService
 }
 
 // NewScheduleHandlers creates new schedule handlers
-func NewScheduleHandlers(scheduler *services.SchedulerService) *ScheduleHandlers {
+func NewScheduleHandlers(scheduler *services.SchedulerService, audit *services.AuditService) *ScheduleHandlers {
 	return &ScheduleHandlers{
 		scheduler: scheduler,
+		audit:     audit,
 	}
 }
 
@@ -65,6 +67,7 @@ func (h *ScheduleHandlers) CreateSchedule(w http.ResponseWriter, r *http.Request
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r.Context(), actorOf(r), services.AuditScheduleCreate, schedule.ID, nil, schedule)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -82,11 +85,18 @@ func (h *ScheduleHandlers) UpdateSchedule(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	before, err := h.scheduler.GetSchedule(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	schedule.ID = id
 	if err := h.scheduler.UpdateSchedule(r.Context(), &schedule); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r.Context(), actorOf(r), services.AuditScheduleUpdate, id, before, schedule)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(schedule)
@@ -97,10 +107,17 @@ func (h *ScheduleHandlers) DeleteSchedule(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	before, err := h.scheduler.GetSchedule(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	if err := h.scheduler.DeleteSchedule(r.Context(), id); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r.Context(), actorOf(r), services.AuditScheduleDelete, id, before, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -5,28 +5,46 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	server           *http.Server
-	handlers         *Handlers
-	scheduleHandlers *ScheduleHandlers
-	presenceHandlers *PresenceHandlers
-	logger           *slog.Logger
+	server            *http.Server
+	handlers          *Handlers
+	scheduleHandlers  *ScheduleHandlers
+	presenceHandlers  *PresenceHandlers
+	summaryHandlers   *SummaryHandlers
+	knowledgeHandlers *KnowledgeHandlers
+	callbackHandlers  *CallbackHandlers
+	messagingHandlers *MessagingHandlers
+	auditHandlers     *AuditHandlers
+	auth              *authenticator
+	corsOrigins       []string
+	corsMu            sync.RWMutex
+	logger            *slog.Logger
 }
 
 // NewServer creates a new HTTP server
-func NewServer(port int, handlers *Handlers, scheduleHandlers *ScheduleHandlers, presenceHandlers *PresenceHandlers, logger *slog.Logger) *Server {
+func NewServer(port int, handlers *Handlers, scheduleHandlers *ScheduleHandlers, presenceHandlers *PresenceHandlers, summaryHandlers *SummaryHandlers, knowledgeHandlers *KnowledgeHandlers, callbackHandlers *CallbackHandlers, messagingHandlers *MessagingHandlers, auditHandlers *AuditHandlers, logger *slog.Logger) *Server {
 	return &Server{
-		handlers:         handlers,
-		scheduleHandlers: scheduleHandlers,
-		presenceHandlers: presenceHandlers,
-		logger:           logger,
+		handlers:          handlers,
+		scheduleHandlers:  scheduleHandlers,
+		presenceHandlers:  presenceHandlers,
+		summaryHandlers:   summaryHandlers,
+		knowledgeHandlers: knowledgeHandlers,
+		callbackHandlers:  callbackHandlers,
+		messagingHandlers: messagingHandlers,
+		auditHandlers:     auditHandlers,
+		auth:              &authenticator{logger: logger},
+		logger:            logger,
 		server: &http.Server{
 			Addr:         fmt.Sprintf(":%d", port),
 			ReadTimeout:  15 * time.Second,
@@ -38,40 +56,96 @@ func NewServer(port int, handlers *Handlers, scheduleHandlers *ScheduleHandlers,
 
 // Start starts the HTTP server
 func (s *Server) Start(ctx context.Context) error {
+	s.server.Handler = s.routes()
+
+	s.logger.Info("Starting HTTP server", "addr", s.server.Addr)
+
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("HTTP server error", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// routes returns the handler of all routes. CORS wraps the router, as the router answers
+// preflight requests to method-restricted routes with 405 before its middleware runs.
+func (s *Server) routes() http.Handler {
 	router := mux.NewRouter()
 
+	// Roles required by the API routes
+	viewer := s.auth.require(domain.RoleViewer)
+	operator := s.auth.require(domain.RoleOperator)
+	admin := s.auth.require(domain.RoleAdmin)
+
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/groups", s.handlers.GetGroups).Methods("GET")
-	api.HandleFunc("/groups/participants", s.handlers.GetGroupParticipants).Methods("GET")
-	api.HandleFunc("/config/allowed-groups", s.handlers.GetAllowedGroups).Methods("GET")
-	api.HandleFunc("/config/allowed-groups", s.handlers.UpdateAllowedGroups).Methods("POST")
-	api.HandleFunc("/webhooks", s.handlers.GetWebhooks).Methods("GET")
-	api.HandleFunc("/webhooks", s.handlers.AddWebhook).Methods("POST")
-	api.HandleFunc("/webhooks", s.handlers.DeleteWebhook).Methods("DELETE")
-	api.HandleFunc("/status", s.handlers.GetStatus).Methods("GET")
-	api.HandleFunc("/auth/qr", s.handlers.GetQRCode).Methods("GET")
+	api.HandleFunc("/groups", viewer(s.handlers.GetGroups)).Methods("GET")
+	api.HandleFunc("/groups/participants", viewer(s.handlers.GetGroupParticipants)).Methods("GET")
+	api.HandleFunc("/groups/{jid}/persona", viewer(s.handlers.GetGroupPersona)).Methods("GET")
+	api.HandleFunc("/groups/{jid}/persona", admin(s.handlers.UpdateGroupPersona)).Methods("PUT")
+	api.HandleFunc("/groups/{jid}/persona", admin(s.handlers.DeleteGroupPersona)).Methods("DELETE")
+	api.HandleFunc("/config/allowed-groups", viewer(s.handlers.GetAllowedGroups)).Methods("GET")
+	api.HandleFunc("/config/allowed-groups", admin(s.handlers.UpdateAllowedGroups)).Methods("POST")
+	api.HandleFunc("/webhooks", viewer(s.handlers.GetWebhooks)).Methods("GET")
+	api.HandleFunc("/webhooks", admin(s.handlers.AddWebhook)).Methods("POST")
+	api.HandleFunc("/webhooks", admin(s.handlers.DeleteWebhook)).Methods("DELETE")
+	api.HandleFunc("/status", viewer(s.handlers.GetStatus)).Methods("GET")
+	api.HandleFunc("/auth/qr", admin(s.handlers.GetQRCode)).Methods("GET")
 	api.HandleFunc("/health", s.handlers.HealthCheck).Methods("GET")
 
 	// Schedule routes
 	if s.scheduleHandlers != nil {
-		api.HandleFunc("/schedules", s.scheduleHandlers.GetSchedules).Methods("GET")
-		api.HandleFunc("/schedules", s.scheduleHandlers.CreateSchedule).Methods("POST")
-		api.HandleFunc("/schedules/{id}", s.scheduleHandlers.GetSchedule).Methods("GET")
-		api.HandleFunc("/schedules/{id}", s.scheduleHandlers.UpdateSchedule).Methods("PUT")
-		api.HandleFunc("/schedules/{id}", s.scheduleHandlers.DeleteSchedule).Methods("DELETE")
-		api.HandleFunc("/schedules/{id}/executions", s.scheduleHandlers.GetScheduleExecutions).Methods("GET")
-		api.HandleFunc("/server-time", s.scheduleHandlers.GetServerTime).Methods("GET")
+		api.HandleFunc("/schedules", viewer(s.scheduleHandlers.GetSchedules)).Methods("GET")
+		api.HandleFunc("/schedules", operator(s.scheduleHandlers.CreateSchedule)).Methods("POST")
+		api.HandleFunc("/schedules/{id}", viewer(s.scheduleHandlers.GetSchedule)).Methods("GET")
+		api.HandleFunc("/schedules/{id}", operator(s.scheduleHandlers.UpdateSchedule)).Methods("PUT")
+		api.HandleFunc("/schedules/{id}", operator(s.scheduleHandlers.DeleteSchedule)).Methods("DELETE")
+		api.HandleFunc("/schedules/{id}/executions", viewer(s.scheduleHandlers.GetScheduleExecutions)).Methods("GET")
+		api.HandleFunc("/server-time", viewer(s.scheduleHandlers.GetServerTime)).Methods("GET")
 	}
 
 	// Presence tracking routes
 	if s.presenceHandlers != nil {
-		api.HandleFunc("/presence", s.presenceHandlers.GetAllPresences).Methods("GET")
-		api.HandleFunc("/presence/stats", s.presenceHandlers.GetPresenceStats).Methods("GET")
-		api.HandleFunc("/presence/{jid}", s.presenceHandlers.GetPresence).Methods("GET")
-		api.HandleFunc("/presence/subscribe", s.presenceHandlers.SubscribeToContact).Methods("POST")
-		api.HandleFunc("/presence/subscribe/bulk", s.presenceHandlers.BulkSubscribe).Methods("POST")
-		api.HandleFunc("/presence/{jid}", s.presenceHandlers.UnsubscribeFromContact).Methods("DELETE")
+		api.HandleFunc("/presence", viewer(s.presenceHandlers.GetAllPresences)).Methods("GET")
+		api.HandleFunc("/presence/stats", viewer(s.presenceHandlers.GetPresenceStats)).Methods("GET")
+		api.HandleFunc("/presence/{jid}", viewer(s.presenceHandlers.GetPresence)).Methods("GET")
+		api.HandleFunc("/presence/subscribe", operator(s.presenceHandlers.SubscribeToContact)).Methods("POST")
+		api.HandleFunc("/presence/subscribe/bulk", operator(s.presenceHandlers.BulkSubscribe)).Methods("POST")
+		api.HandleFunc("/presence/{jid}", operator(s.presenceHandlers.UnsubscribeFromContact)).Methods("DELETE")
+	}
+
+	// Conversation summary routes
+	if s.summaryHandlers != nil {
+		api.HandleFunc("/groups/{jid}/summary", viewer(s.summaryHandlers.GetSummary)).Methods("GET")
+		api.HandleFunc("/groups/{jid}/summary", operator(s.summaryHandlers.RefreshSummary)).Methods("POST")
+		api.HandleFunc("/groups/{jid}/summary", operator(s.summaryHandlers.ResetSummary)).Methods("DELETE")
+	}
+
+	// Knowledge base routes
+	if s.knowledgeHandlers != nil {
+		api.HandleFunc("/knowledge/{jid}", viewer(s.knowledgeHandlers.GetDocuments)).Methods("GET")
+		api.HandleFunc("/knowledge/{jid}", operator(s.knowledgeHandlers.AddDocuments)).Methods("POST")
+		api.HandleFunc("/knowledge/{jid}/search", viewer(s.knowledgeHandlers.SearchKnowledge)).Methods("GET")
+		api.HandleFunc("/knowledge/{jid}/{id}", operator(s.knowledgeHandlers.DeleteDocument)).Methods("DELETE")
+	}
+
+	// Async webhook results, signed with the webhook secret
+	if s.callbackHandlers != nil {
+		api.HandleFunc("/callbacks/{id}", s.callbackHandlers.CompleteCallback).Methods("POST")
+	}
+
+	// Messaging API for other systems; always requires credentials
+	if s.messagingHandlers != nil {
+		sender := s.auth.requireCredentials(domain.RoleOperator)
+		api.HandleFunc("/messages", sender(s.messagingHandlers.SendMessage)).Methods("POST")
+		api.HandleFunc("/groups/{jid}/media", sender(s.messagingHandlers.SendGroupMedia)).Methods("POST")
+	}
+
+	// Audit log of admin changes
+	if s.auditHandlers != nil {
+		api.HandleFunc("/audit", viewer(s.auditHandlers.GetAuditLog)).Methods("GET")
 	}
 
 	// Prometheus metrics endpoint
@@ -84,25 +158,28 @@ func (s *Server) Start(ctx context.Context) error {
 	router.HandleFunc("/groups", s.serveGroupsUI).Methods("GET")
 	router.HandleFunc("/webhooks", s.serveWebhooksUI).Methods("GET")
 	router.HandleFunc("/presence", s.servePresenceUI).Methods("GET")
+	router.HandleFunc("/audit", s.serveAuditUI).Methods("GET")
 	router.HandleFunc("/", s.serveAdminUI).Methods("GET")
 
-	// Add CORS middleware
-	router.Use(corsMiddleware)
-
 	// Add logging middleware
 	router.Use(s.loggingMiddleware)
 
-	s.server.Handler = router
+	return s.corsMiddleware(router)
+}
 
-	s.logger.Info("Starting HTTP server", "addr", s.server.Addr)
+// UpdateAPIConfig updates the accepted credentials and CORS origins
+func (s *Server) UpdateAPIConfig(cfg domain.APIConfig) {
+	s.auth.update(cfg)
 
-	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Error("HTTP server error", "error", err)
-		}
-	}()
+	s.corsMu.Lock()
+	s.corsOrigins = cfg.CORSOrigins
+	s.corsMu.Unlock()
 
-	return nil
+	if s.auth.open() && cfg.AllowAnonymous {
+		s.logger.Warn("No API credentials configured and allow_anonymous set, the admin API is open to anyone who can reach it")
+	} else if s.auth.open() {
+		s.logger.Warn("No API credentials configured, the admin API is read-only until credentials or allow_anonymous are set")
+	}
 }
 
 // Stop gracefully stops the HTTP server
@@ -141,12 +218,30 @@ func (s *Server) servePresenceUI(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "web/templates/presence_new.html")
 }
 
-// corsMiddleware adds CORS headers
-func corsMiddleware(next http.Handler) http.Handler {
+// serveAuditUI serves the audit log UI page
+func (s *Server) serveAuditUI(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, "web/templates/audit_new.html")
+}
+
+// corsMiddleware adds CORS headers for the configured origins and refuses changing
+// requests from other origins, so pages elsewhere cannot use the browser's credentials
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if !s.originAllowed(r) {
+			http.Error(w, "Forbidden: cross-origin request", http.StatusForbidden)
+			return
+		}
+
+		if allowed := s.corsAllowed(r.Header.Get("Origin")); allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			if allowed != "*" {
+				// Only listed origins may use the browser's stored credentials
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
+		}
+		w.Header().Add("Vary", "Origin")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -157,6 +252,49 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// corsAllowed returns the Access-Control-Allow-Origin value for a browser origin:
+// the origin itself if it is configured, "*" if any origin is, or "" if it may not call the API
+func (s *Server) corsAllowed(origin string) string {
+	if origin == "" {
+		return ""
+	}
+
+	s.corsMu.RLock()
+	defer s.corsMu.RUnlock()
+
+	wildcard := false
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" {
+			wildcard = true
+		} else if strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return origin
+		}
+	}
+	if wildcard {
+		return "*"
+	}
+	return ""
+}
+
+// originAllowed reports whether a request may change data: reads, requests from the
+// admin UI itself or a listed origin, and requests with a bearer token, which browsers
+// never attach on their own
+func (s *Server) originAllowed(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && (strings.EqualFold(parsed.Host, r.Host) || strings.EqualFold(parsed.Host, r.Header.Get("X-Forwarded-Host"))) {
+		return true
+	}
+	return s.corsAllowed(origin) == origin
+}
+
 // loggingMiddleware logs HTTP requests
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
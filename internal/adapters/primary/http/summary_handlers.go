@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/services"
+)
+
+// SummaryHandlers contains conversation summary HTTP handlers
+type SummaryHandlers struct {
+	summaries *services.SummaryService
+}
+
+// NewSummaryHandlers creates new summary handlers
+func NewSummaryHandlers(summaries *services.SummaryService) *SummaryHandlers {
+	return &SummaryHandlers{
+		summaries: summaries,
+	}
+}
+
+// GetSummary returns the conversation summary for a group
+func (h *SummaryHandlers) GetSummary(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	summary, err := h.summaries.GetSummary(r.Context(), groupJID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if summary == nil {
+		http.Error(w, "No summary for group", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// RefreshSummary summarizes pending history for a group immediately
+func (h *SummaryHandlers) RefreshSummary(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	summary, err := h.summaries.SummarizeGroup(r.Context(), groupJID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if summary == nil {
+		http.Error(w, "Not enough history to summarize", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// ResetSummary deletes the conversation summary for a group
+func (h *SummaryHandlers) ResetSummary(w http.ResponseWriter, r *http.Request) {
+	groupJID := mux.Vars(r)["jid"]
+
+	if err := h.summaries.ResetSummary(r.Context(), groupJID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -21,21 +22,33 @@ import (
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 )
 
+const (
+	// maxMediaDownloadSize caps attachments downloaded for the LLM
+	maxMediaDownloadSize = 16 * 1024 * 1024
+
+	// mediaDownloadTimeout bounds a single attachment download
+	mediaDownloadTimeout = 60 * time.Second
+)
+
 // Client implements WhatsAppClient interface
 type Client struct {
-	client            *whatsmeow.Client
-	sessionPath       string
-	allowedGroups     map[string]bool
-	messageHandlers   []func(*domain.Message)
-	presenceHandlers  []func(*domain.PresenceEvent)
-	mu                sync.RWMutex
-	qrChan            chan string
-	logger            waLog.Logger
-	botLIDCache       map[string]string // groupJID -> botLID mapping
-	cacheMu           sync.RWMutex
-	presenceEnabled   bool
-	subscribedContacts map[string]bool
-	subscribeMu       sync.RWMutex
+	client               *whatsmeow.Client
+	sessionPath          string
+	allowedGroups        map[string]bool
+	messageHandlers      []func(*domain.Message)
+	presenceHandlers     []func(*domain.PresenceEvent)
+	mu                   sync.RWMutex
+	qrChan               chan string
+	logger               waLog.Logger
+	botLIDCache          map[string]string // groupJID -> botLID mapping
+	groupNames           map[string]string // groupJID -> group subject
+	cacheMu              sync.RWMutex
+	presenceEnabled      bool
+	subscribedContacts   map[string]bool
+	subscribeMu          sync.RWMutex
+	ffmpegPath           string // converts GIFs to MP4
+	documentsEnabled     bool   // download shared PDFs and text files
+	transcriptionEnabled bool   // download voice notes to transcribe them
 }
 
 // NewClient creates a new WhatsApp client
@@ -51,8 +64,10 @@ func NewClient(sessionPath string, allowedGroups []string, logger waLog.Logger)
 		qrChan:             make(chan string, 1),
 		logger:             logger,
 		botLIDCache:        make(map[string]string),
+		groupNames:         make(map[string]string),
 		presenceEnabled:    false,
 		subscribedContacts: make(map[string]bool),
+		ffmpegPath:         "ffmpeg",
 	}, nil
 }
 
@@ -151,13 +166,19 @@ func (c *Client) SendMessage(ctx context.Context, groupJID, message string) erro
 
 // SendReply sends a message as a reply to another message in a WhatsApp group
 func (c *Client) SendReply(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) error {
+	_, err := c.SendReplyWithID(ctx, groupJID, message, replyToMessageID, quotedSender)
+	return err
+}
+
+// SendReplyWithID sends a reply and returns the ID of the sent message so it can be edited later
+func (c *Client) SendReplyWithID(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) (string, error) {
 	if c.client == nil {
-		return fmt.Errorf("client not initialized")
+		return "", fmt.Errorf("client not initialized")
 	}
 
 	jid, err := types.ParseJID(groupJID)
 	if err != nil {
-		return fmt.Errorf("invalid JID: %w", err)
+		return "", fmt.Errorf("invalid JID: %w", err)
 	}
 
 	// Parse the quoted sender JID to ensure it's in the correct format
@@ -183,16 +204,56 @@ func (c *Client) SendReply(ctx context.Context, groupJID, message, replyToMessag
 
 	c.logger.Infof("Sending reply to message %s from %s in group %s", replyToMessageID, quotedSender, groupJID)
 
+	resp, err := c.client.SendMessage(ctx, jid, msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+// SendMentions sends a message that mentions the given JIDs, optionally as a reply.
+// The text should contain "@<number>" for each mention so WhatsApp highlights it.
+func (c *Client) SendMentions(ctx context.Context, groupJID, message string, mentions []string, replyToMessageID, quotedSender string) error {
+	if c.client == nil {
+		return fmt.Errorf("client not initialized")
+	}
+
+	jid, err := types.ParseJID(groupJID)
+	if err != nil {
+		return fmt.Errorf("invalid JID: %w", err)
+	}
+
+	contextInfo := c.replyContextInfo(replyToMessageID, quotedSender)
+	if contextInfo == nil {
+		contextInfo = &waProto.ContextInfo{}
+	}
+	for _, mention := range mentions {
+		mentionJID, err := types.ParseJID(mention)
+		if err != nil {
+			c.logger.Warnf("Skipping invalid mention %q: %v", mention, err)
+			continue
+		}
+		contextInfo.MentionedJID = append(contextInfo.MentionedJID, mentionJID.String())
+	}
+
+	msg := &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:        proto.String(message),
+			ContextInfo: contextInfo,
+		},
+	}
+
 	_, err = c.client.SendMessage(ctx, jid, msg)
 	if err != nil {
-		return fmt.Errorf("failed to send reply: %w", err)
+		return fmt.Errorf("failed to send message: %w", err)
 	}
 
 	return nil
 }
 
-// SendImage sends an image to a WhatsApp group
-func (c *Client) SendImage(ctx context.Context, groupJID string, imageData []byte, mimeType, caption, replyToMessageID, quotedSender string) error {
+// SendReaction reacts to a message with an emoji; an empty emoji removes the reaction
+func (c *Client) SendReaction(ctx context.Context, groupJID, messageID, sender, emoji string) error {
 	if c.client == nil {
 		return fmt.Errorf("client not initialized")
 	}
@@ -202,60 +263,216 @@ func (c *Client) SendImage(ctx context.Context, groupJID string, imageData []byt
 		return fmt.Errorf("invalid JID: %w", err)
 	}
 
-	// Upload image to WhatsApp servers
-	uploaded, err := c.client.Upload(ctx, imageData, whatsmeow.MediaImage)
+	senderJID, err := types.ParseJID(sender)
 	if err != nil {
-		return fmt.Errorf("failed to upload image: %w", err)
+		return fmt.Errorf("invalid sender JID: %w", err)
 	}
 
-	// Create image message
-	imageMsg := &waProto.ImageMessage{
-		URL:           proto.String(uploaded.URL),
-		DirectPath:    proto.String(uploaded.DirectPath),
-		MediaKey:      uploaded.MediaKey,
-		Mimetype:      proto.String(mimeType),
-		FileEncSHA256: uploaded.FileEncSHA256,
-		FileSHA256:    uploaded.FileSHA256,
-		FileLength:    proto.Uint64(uint64(len(imageData))),
+	_, err = c.client.SendMessage(ctx, jid, c.client.BuildReaction(jid, senderJID, messageID, emoji))
+	if err != nil {
+		return fmt.Errorf("failed to send reaction: %w", err)
 	}
 
-	// Add caption if provided
-	if caption != "" {
-		imageMsg.Caption = proto.String(caption)
+	return nil
+}
+
+// EditMessage replaces the text of a message previously sent by the bot
+func (c *Client) EditMessage(ctx context.Context, groupJID, messageID, message string) error {
+	if c.client == nil {
+		return fmt.Errorf("client not initialized")
 	}
 
-	msg := &waProto.Message{
-		ImageMessage: imageMsg,
+	jid, err := types.ParseJID(groupJID)
+	if err != nil {
+		return fmt.Errorf("invalid JID: %w", err)
+	}
+
+	edit := c.client.BuildEdit(jid, messageID, &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text: proto.String(message),
+		},
+	})
+
+	_, err = c.client.SendMessage(ctx, jid, edit)
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	return nil
+}
+
+// SendImage sends an image to a WhatsApp group
+func (c *Client) SendImage(ctx context.Context, groupJID string, imageData []byte, mimeType, caption, replyToMessageID, quotedSender string) error {
+	media := &domain.Media{Type: domain.MediaTypeImage, MimeType: mimeType, Data: imageData}
+	return c.SendMedia(ctx, groupJID, media, caption, replyToMessageID, quotedSender)
+}
+
+// SendAudio sends OGG/Opus audio as a voice note (PTT) to a WhatsApp chat
+func (c *Client) SendAudio(ctx context.Context, groupJID string, audioData []byte, mimeType, replyToMessageID, quotedSender string) error {
+	if mimeType == "" {
+		mimeType = "audio/ogg"
 	}
+	media := &domain.Media{Type: domain.MediaTypeAudio, MimeType: mimeType, Data: audioData}
+	return c.SendMedia(ctx, groupJID, media, "", replyToMessageID, quotedSender)
+}
 
-	// If replying to a message, wrap in ExtendedTextMessage with ContextInfo
-	if replyToMessageID != "" && quotedSender != "" {
-		// Parse the quoted sender JID
-		quotedSenderJID, err := types.ParseJID(quotedSender)
+// SendMedia uploads and sends an image, video, audio, document or sticker to a WhatsApp chat.
+// The caption is ignored for audio and stickers, which cannot carry one.
+func (c *Client) SendMedia(ctx context.Context, groupJID string, media *domain.Media, caption, replyToMessageID, quotedSender string) error {
+	if c.client == nil {
+		return fmt.Errorf("client not initialized")
+	}
+
+	jid, err := types.ParseJID(groupJID)
+	if err != nil {
+		return fmt.Errorf("invalid JID: %w", err)
+	}
+
+	// WhatsApp only animates GIFs sent as MP4 videos with GIF playback
+	gifPlayback := media.Type == domain.MediaTypeVideo && media.MimeType == "image/gif"
+	if gifPlayback {
+		c.mu.RLock()
+		ffmpegPath := c.ffmpegPath
+		c.mu.RUnlock()
+
+		mp4, err := gifToMP4(ctx, ffmpegPath, media.Data)
 		if err != nil {
-			c.logger.Warnf("Failed to parse quoted sender JID: %v, using as-is", err)
-		} else {
-			quotedSender = quotedSenderJID.String()
+			return err
 		}
+		media = &domain.Media{Type: domain.MediaTypeVideo, MimeType: "video/mp4", Data: mp4, FileName: media.FileName, MessageID: media.MessageID}
+	}
+
+	// Upload media to WhatsApp servers; stickers are encrypted as images
+	uploadType := whatsmeow.MediaDocument
+	switch media.Type {
+	case domain.MediaTypeImage, domain.MediaTypeSticker:
+		uploadType = whatsmeow.MediaImage
+	case domain.MediaTypeVideo:
+		uploadType = whatsmeow.MediaVideo
+	case domain.MediaTypeAudio:
+		uploadType = whatsmeow.MediaAudio
+	}
 
-		// Add context info for reply
-		imageMsg.ContextInfo = &waProto.ContextInfo{
-			StanzaID:      proto.String(replyToMessageID),
-			Participant:   proto.String(quotedSender),
-			QuotedMessage: &waProto.Message{},
+	uploaded, err := c.client.Upload(ctx, media.Data, uploadType)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", media.Type, err)
+	}
+
+	contextInfo := c.replyContextInfo(replyToMessageID, quotedSender)
+	var optionalCaption *string
+	if caption != "" {
+		optionalCaption = proto.String(caption)
+	}
+
+	mimeType := media.MimeType
+	msg := &waProto.Message{}
+	switch media.Type {
+	case domain.MediaTypeImage:
+		msg.ImageMessage = &waProto.ImageMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(media.Data))),
+			Caption:       optionalCaption,
+			ContextInfo:   contextInfo,
+		}
+	case domain.MediaTypeVideo:
+		msg.VideoMessage = &waProto.VideoMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(media.Data))),
+			Caption:       optionalCaption,
+			GifPlayback:   proto.Bool(gifPlayback),
+			ContextInfo:   contextInfo,
+		}
+	case domain.MediaTypeAudio:
+		// Ogg/Opus audio plays inline as a voice note; other formats, including Ogg/Vorbis,
+		// are sent as audio files
+		ptt := isOpus(mimeType, media.Data)
+		if ptt {
+			mimeType = "audio/ogg; codecs=opus"
+		}
+		msg.AudioMessage = &waProto.AudioMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(media.Data))),
+			PTT:           proto.Bool(ptt),
+			ContextInfo:   contextInfo,
+		}
+	case domain.MediaTypeSticker:
+		msg.StickerMessage = &waProto.StickerMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(media.Data))),
+			IsAnimated:    proto.Bool(isAnimatedWebP(media.Data)),
+			ContextInfo:   contextInfo,
+		}
+	default:
+		fileName := media.FileName
+		if fileName == "" {
+			fileName = "file"
+		}
+		msg.DocumentMessage = &waProto.DocumentMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(media.Data))),
+			FileName:      proto.String(fileName),
+			Title:         proto.String(fileName),
+			Caption:       optionalCaption,
+			ContextInfo:   contextInfo,
 		}
 	}
 
-	c.logger.Infof("Sending image (%s, %d bytes) to group %s", mimeType, len(imageData), groupJID)
+	c.logger.Infof("Sending %s (%s, %d bytes) to chat %s", media.Type, mimeType, len(media.Data), groupJID)
 
 	_, err = c.client.SendMessage(ctx, jid, msg)
 	if err != nil {
-		return fmt.Errorf("failed to send image: %w", err)
+		return fmt.Errorf("failed to send %s: %w", media.Type, err)
 	}
 
 	return nil
 }
 
+// replyContextInfo returns the context info that quotes the replied-to message, or nil when not replying
+func (c *Client) replyContextInfo(replyToMessageID, quotedSender string) *waProto.ContextInfo {
+	if replyToMessageID == "" || quotedSender == "" {
+		return nil
+	}
+
+	// Parse the quoted sender JID
+	quotedSenderJID, err := types.ParseJID(quotedSender)
+	if err != nil {
+		c.logger.Warnf("Failed to parse quoted sender JID: %v, using as-is", err)
+	} else {
+		quotedSender = quotedSenderJID.String()
+	}
+
+	return &waProto.ContextInfo{
+		StanzaID:      proto.String(replyToMessageID),
+		Participant:   proto.String(quotedSender),
+		QuotedMessage: &waProto.Message{},
+	}
+}
+
 // GetGroups returns all groups the bot is part of
 func (c *Client) GetGroups(ctx context.Context) ([]*domain.Group, error) {
 	if c.client == nil {
@@ -290,6 +507,10 @@ func (c *Client) GetGroups(ctx context.Context) ([]*domain.Group, error) {
 		// If still empty, use a fallback based on JID
 		if groupName == "" {
 			groupName = "Group " + group.JID.User
+		} else {
+			c.cacheMu.Lock()
+			c.groupNames[group.JID.String()] = groupName
+			c.cacheMu.Unlock()
 		}
 
 		result = append(result, &domain.Group{
@@ -451,25 +672,40 @@ func (c *Client) SubscribeToGroupParticipants(groupJID string) error {
 func (c *Client) eventHandler(evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
-		// Only process group messages
-		if !v.Info.IsGroup {
-			return
-		}
-
-		groupJID := v.Info.Chat.String()
-
-		// Check if group is allowed
-		c.mu.RLock()
-		isAllowed := c.allowedGroups[groupJID]
-		c.mu.RUnlock()
-
-		if !isAllowed {
-			return
+		var groupJID, chatJID string
+		if v.Info.IsGroup {
+			groupJID = v.Info.Chat.String()
+			chatJID = groupJID
+
+			// Check if group is allowed
+			c.mu.RLock()
+			isAllowed := c.allowedGroups[groupJID]
+			c.mu.RUnlock()
+
+			if !isAllowed {
+				return
+			}
+		} else {
+			// Direct chat: the contact allowlist is enforced by the chat service.
+			// Skip our own messages (sent from the phone or echoed back) and status broadcasts.
+			if v.Info.IsFromMe || v.Info.Chat.Server == types.BroadcastServer {
+				return
+			}
+			chatJID = c.directChatJID(v.Info)
 		}
 
 		// Extract message content
 		var content string
 		var isReplyToBot bool
+		var quotedID, quotedSender, quotedContent string
+		var attachment *pendingMedia       // downloaded before the message is dispatched
+		var photo *pendingMedia            // downloaded when the bot answers
+		var quotedAttachment *pendingMedia // downloaded when the bot answers
+
+		c.mu.RLock()
+		documentsEnabled := c.documentsEnabled
+		transcriptionEnabled := c.transcriptionEnabled
+		c.mu.RUnlock()
 
 		// Check ExtendedTextMessage first (for replies and formatted text)
 		if v.Message.GetExtendedTextMessage() != nil {
@@ -488,8 +724,11 @@ func (c *Client) eventHandler(evt interface{}) {
 					botJID := c.client.Store.ID.String()
 					botUser := c.client.Store.ID.User // e.g., "919539383208"
 
-					// Try to get bot's LID for this group
-					botLID := c.getBotLID(groupJID)
+					// Try to get bot's LID for this group; direct chats have no group to look it up for
+					var botLID string
+					if v.Info.IsGroup {
+						botLID = c.getBotLID(groupJID)
+					}
 
 					c.logger.Debugf("Reply detected - Quoted: '%s', Bot JID: '%s', Bot User: '%s', Bot LID: '%s'",
 						quotedParticipant, botJID, botUser, botLID)
@@ -501,9 +740,9 @@ func (c *Client) eventHandler(evt interface{}) {
 						// 2. LID match (129468098179230@lid)
 						// 3. Prefix matches for device IDs
 						if quotedParticipant == botJID ||
-						   quotedParticipant == botLID ||
-						   strings.HasPrefix(quotedParticipant, botJID) ||
-						   strings.HasPrefix(botJID, quotedParticipant) {
+							quotedParticipant == botLID ||
+							strings.HasPrefix(quotedParticipant, botJID) ||
+							strings.HasPrefix(botJID, quotedParticipant) {
 							isReplyToBot = true
 							c.logger.Infof("✓ Message is a reply to bot from %s", v.Info.Sender.String())
 						} else {
@@ -512,12 +751,50 @@ func (c *Client) eventHandler(evt interface{}) {
 					}
 				}
 			}
+
+			// A question about a quoted photo or document, e.g. a reply "@bot what is this?"
+			quoted := extMsg.GetContextInfo().GetQuotedMessage()
+			if quoted != nil {
+				quotedID = extMsg.GetContextInfo().GetStanzaID()
+				quotedSender = extMsg.GetContextInfo().GetParticipant()
+				quotedContent = messageText(quoted)
+			}
+			if quoted.GetImageMessage() != nil {
+				quotedAttachment = imageAttachment(quoted.GetImageMessage())
+			} else if docMsg := documentMessage(quoted); docMsg != nil && documentsEnabled {
+				quotedAttachment = documentAttachment(docMsg, extMsg.GetContextInfo().GetStanzaID())
+			}
 		} else if v.Message.GetConversation() != "" {
 			content = v.Message.GetConversation()
 			c.logger.Debugf("Regular conversation message, content: %s", content)
+		} else if imgMsg := v.Message.GetImageMessage(); imgMsg != nil {
+			// Photos are only interesting when the caption can address the bot
+			content = imgMsg.GetCaption()
+			photo = imageAttachment(imgMsg)
+			c.logger.Debugf("Image message, caption: %s", content)
+		} else if audioMsg := v.Message.GetAudioMessage(); audioMsg != nil && audioMsg.GetPTT() {
+			// Voice notes have no text; the chat service transcribes them if transcription is enabled
+			if !transcriptionEnabled {
+				return
+			}
+			attachment = &pendingMedia{
+				message:   audioMsg,
+				mediaType: domain.MediaTypeAudio,
+				mimeType:  audioMsg.GetMimetype(),
+				size:      audioMsg.GetFileLength(),
+			}
+			c.logger.Debugf("Voice note, duration: %ds", audioMsg.GetSeconds())
+		} else if docMsg := documentMessage(v.Message); docMsg != nil {
+			// Documents are kept for later questions, so they are dispatched even without a caption.
+			// Without document support only the caption is of interest.
+			content = docMsg.GetCaption()
+			if documentsEnabled {
+				attachment = documentAttachment(docMsg, v.Info.ID)
+			}
+			c.logger.Debugf("Document message: %s (%s)", docMsg.GetFileName(), docMsg.GetMimetype())
 		}
 
-		if content == "" {
+		if content == "" && attachment == nil {
 			return
 		}
 
@@ -525,22 +802,33 @@ func (c *Client) eventHandler(evt interface{}) {
 		msg := &domain.Message{
 			ID:           v.Info.ID,
 			GroupJID:     groupJID,
+			ChatJID:      chatJID,
+			IsDirect:     !v.Info.IsGroup,
 			Sender:       v.Info.Sender.String(),
+			SenderName:   v.Info.PushName,
 			Content:      content,
 			Timestamp:    v.Info.Timestamp,
 			IsFromBot:    false,
 			IsReplyToBot: isReplyToBot,
-		}
 
-		// Call all registered handlers
-		c.mu.RLock()
-		handlers := c.messageHandlers
-		c.mu.RUnlock()
-
-		for _, handler := range handlers {
-			go handler(msg)
+			QuotedMessageID: quotedID,
+			QuotedSender:    quotedSender,
+			QuotedContent:   quotedContent,
+		}
+		if photo != nil {
+			msg.LoadMedia = func(ctx context.Context) (*domain.Media, error) {
+				return c.downloadMedia(ctx, photo)
+			}
+		}
+		if quotedAttachment != nil {
+			msg.LoadQuotedMedia = func(ctx context.Context) (*domain.Media, error) {
+				return c.downloadMedia(ctx, quotedAttachment)
+			}
 		}
 
+		// Download media off the event loop, then call all registered handlers
+		go c.dispatchMessage(msg, attachment)
+
 	case *events.Connected:
 		c.logger.Infof("Connected to WhatsApp")
 
@@ -587,6 +875,30 @@ func (c *Client) handlePresence(evt *events.Presence) {
 	}
 }
 
+// UpdateFFmpegPath sets the ffmpeg binary used to convert GIFs; empty uses "ffmpeg"
+func (c *Client) UpdateFFmpegPath(path string) {
+	if path == "" {
+		path = "ffmpeg"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ffmpegPath = path
+}
+
+// UpdateDocuments sets whether shared documents are downloaded for question answering
+func (c *Client) UpdateDocuments(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documentsEnabled = enabled
+}
+
+// UpdateTranscription sets whether voice notes are downloaded for transcription
+func (c *Client) UpdateTranscription(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transcriptionEnabled = enabled
+}
+
 // UpdateAllowedGroups updates the list of allowed groups
 func (c *Client) UpdateAllowedGroups(groups []string) {
 	c.mu.Lock()
@@ -598,6 +910,168 @@ func (c *Client) UpdateAllowedGroups(groups []string) {
 	}
 }
 
+// dispatchMessage attaches the media, if any, and passes the message to all handlers
+func (c *Client) dispatchMessage(msg *domain.Message, attachment *pendingMedia) {
+	if attachment != nil {
+		media, err := c.downloadMedia(context.Background(), attachment)
+		if err != nil {
+			c.logger.Warnf("Failed to download %s %s: %v", attachment.mediaType, msg.ID, err)
+			if msg.Content == "" {
+				// Nothing left to answer
+				return
+			}
+		} else {
+			msg.Media = media
+		}
+	}
+
+	if msg.GroupJID != "" {
+		msg.GroupName = c.groupName(msg.GroupJID)
+	}
+
+	c.mu.RLock()
+	handlers := c.messageHandlers
+	c.mu.RUnlock()
+
+	for _, handler := range handlers {
+		go handler(msg)
+	}
+}
+
+// groupName returns the subject of a group, looking it up once and caching it
+func (c *Client) groupName(groupJID string) string {
+	c.cacheMu.RLock()
+	name, ok := c.groupNames[groupJID]
+	c.cacheMu.RUnlock()
+	if ok {
+		return name
+	}
+
+	jid, err := types.ParseJID(groupJID)
+	if err != nil || c.client == nil {
+		return ""
+	}
+
+	groupInfo, err := c.client.GetGroupInfo(jid)
+	if err != nil || groupInfo == nil {
+		c.logger.Debugf("Failed to get name of group %s: %v", groupJID, err)
+		return ""
+	}
+
+	c.cacheMu.Lock()
+	c.groupNames[groupJID] = groupInfo.Name
+	c.cacheMu.Unlock()
+	return groupInfo.Name
+}
+
+// messageText returns the text or caption of a message
+func messageText(message *waProto.Message) string {
+	switch {
+	case message.GetConversation() != "":
+		return message.GetConversation()
+	case message.GetExtendedTextMessage() != nil:
+		return message.GetExtendedTextMessage().GetText()
+	case message.GetImageMessage() != nil:
+		return message.GetImageMessage().GetCaption()
+	case message.GetVideoMessage() != nil:
+		return message.GetVideoMessage().GetCaption()
+	case documentMessage(message) != nil:
+		return documentMessage(message).GetCaption()
+	}
+	return ""
+}
+
+// pendingMedia is an attachment that still has to be downloaded
+type pendingMedia struct {
+	message   whatsmeow.DownloadableMessage
+	mediaType domain.MediaType
+	mimeType  string
+	fileName  string
+	messageID string // ID of the message that carried the attachment
+	size      uint64
+}
+
+// imageAttachment describes an image message for download
+func imageAttachment(image *waProto.ImageMessage) *pendingMedia {
+	return &pendingMedia{
+		message:   image,
+		mediaType: domain.MediaTypeImage,
+		mimeType:  image.GetMimetype(),
+		size:      image.GetFileLength(),
+	}
+}
+
+// documentMessage returns the document in a message, including captioned documents
+func documentMessage(message *waProto.Message) *waProto.DocumentMessage {
+	if docMsg := message.GetDocumentMessage(); docMsg != nil {
+		return docMsg
+	}
+	return message.GetDocumentWithCaptionMessage().GetMessage().GetDocumentMessage()
+}
+
+// documentAttachment describes a document message for download, or returns nil
+// for file types the bot cannot read
+func documentAttachment(docMsg *waProto.DocumentMessage, messageID string) *pendingMedia {
+	if !isReadableDocument(docMsg.GetMimetype(), docMsg.GetFileName()) {
+		return nil
+	}
+
+	return &pendingMedia{
+		message:   docMsg,
+		mediaType: domain.MediaTypeDocument,
+		mimeType:  docMsg.GetMimetype(),
+		fileName:  docMsg.GetFileName(),
+		messageID: messageID,
+		size:      docMsg.GetFileLength(),
+	}
+}
+
+// isReadableDocument reports whether a document is a PDF or text file
+func isReadableDocument(mimeType, fileName string) bool {
+	if mimeType == "application/pdf" || strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf", ".txt", ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// downloadMedia downloads and decrypts an attachment
+func (c *Client) downloadMedia(ctx context.Context, attachment *pendingMedia) (*domain.Media, error) {
+	if attachment.size > maxMediaDownloadSize {
+		return nil, fmt.Errorf("%s too large: %d bytes", attachment.mediaType, attachment.size)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mediaDownloadTimeout)
+	defer cancel()
+
+	data, err := c.client.Download(ctx, attachment.message)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Media{
+		Type:      attachment.mediaType,
+		MimeType:  attachment.mimeType,
+		Data:      data,
+		FileName:  attachment.fileName,
+		MessageID: attachment.messageID,
+	}, nil
+}
+
+// directChatJID returns the contact's phone number JID for a direct chat,
+// preferring it over the privacy-preserving LID address when both are known
+func (c *Client) directChatJID(info types.MessageInfo) string {
+	chat := info.Chat.ToNonAD()
+	if chat.Server == types.HiddenUserServer && info.SenderAlt.Server == types.DefaultUserServer {
+		return info.SenderAlt.ToNonAD().String()
+	}
+	return chat.String()
+}
+
 // getBotLID gets the bot's LID (Linked ID) for a specific group
 func (c *Client) getBotLID(groupJID string) string {
 	if c.client == nil || c.client.Store == nil {
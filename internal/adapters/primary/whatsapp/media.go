@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gifToMP4 converts a GIF to an MP4 video, which WhatsApp plays as a looping GIF. ffmpeg
+// writes to a file, as MP4 needs a seekable output to put the index first.
+func gifToMP4(ctx context.Context, ffmpegPath string, gif []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "gif")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "in.gif")
+	output := filepath.Join(dir, "out.mp4")
+	if err := os.WriteFile(input, gif, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write GIF: %w", err)
+	}
+
+	// H.264 needs even dimensions and yuv420p for phones to play it
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", input,
+		"-an", "-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-vf", "scale=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-movflags", "+faststart",
+		output,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert GIF with ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.ReadFile(output)
+}
+
+// isAnimatedWebP reports whether a WebP image is animated: its extended header (VP8X)
+// has the animation flag set
+func isAnimatedWebP(data []byte) bool {
+	const animationFlag = 0x02
+	if len(data) < 21 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" || string(data[12:16]) != "VP8X" {
+		return false
+	}
+	return data[20]&animationFlag != 0
+}
+
+// isOpus reports whether audio is Ogg/Opus, which WhatsApp plays as a voice note: by the
+// codecs parameter of the MIME type, or by the OpusHead packet of the first Ogg page
+func isOpus(mimeType string, data []byte) bool {
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || (mediaType != "audio/ogg" && mediaType != "audio/opus") {
+		return false
+	}
+	if strings.EqualFold(params["codecs"], "opus") {
+		return true
+	}
+	return len(data) >= 36 && string(data[0:4]) == "OggS" && string(data[28:36]) == "OpusHead"
+}
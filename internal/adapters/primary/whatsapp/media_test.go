@@ -0,0 +1,65 @@
+package whatsapp
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+// webp returns a WebP header with an extended (VP8X) chunk carrying the given flags
+func webp(flags byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00")
+	return append(data, flags, 0, 0, 0)
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	if !isAnimatedWebP(webp(0x02)) {
+		t.Error("Expected the animation flag to be detected")
+	}
+	if isAnimatedWebP(webp(0x10)) {
+		t.Error("Expected a still WebP with alpha not to be animated")
+	}
+	if isAnimatedWebP([]byte("RIFF\x00\x00\x00\x00WEBPVP8 ")) {
+		t.Error("Expected a simple WebP not to be animated")
+	}
+}
+
+func TestIsOpus(t *testing.T) {
+	opusPage := append([]byte("OggS"), make([]byte, 24)...)
+	opusPage = append(opusPage, "OpusHead"...)
+	vorbisPage := append([]byte("OggS"), make([]byte, 24)...)
+	vorbisPage = append(vorbisPage, "\x01vorbis\x00"...)
+
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		want     bool
+	}{
+		{"codecs parameter", "audio/ogg; codecs=opus", nil, true},
+		{"opus stream", "audio/ogg", opusPage, true},
+		{"vorbis stream", "audio/ogg", vorbisPage, false},
+		{"mp3", "audio/mpeg", opusPage, false},
+	}
+	for _, tt := range tests {
+		if got := isOpus(tt.mimeType, tt.data); got != tt.want {
+			t.Errorf("%s: isOpus() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGIFToMP4(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+
+	// A 1x1 GIF
+	gif := []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+	mp4, err := gifToMP4(context.Background(), "ffmpeg", gif)
+	if err != nil {
+		t.Fatalf("gifToMP4() error = %v", err)
+	}
+	if len(mp4) < 12 || string(mp4[4:8]) != "ftyp" {
+		t.Errorf("Expected an MP4 file, got %d bytes", len(mp4))
+	}
+}
@@ -0,0 +1,85 @@
+package document
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// Extractor implements TextExtractor for PDF, plain text and Markdown files.
+// PDF text is extracted with pdftotext from poppler-utils.
+type Extractor struct {
+	pdfToTextPath string
+}
+
+// NewExtractor creates a new text extractor
+func NewExtractor(pdfToTextPath string) *Extractor {
+	if pdfToTextPath == "" {
+		pdfToTextPath = "pdftotext"
+	}
+
+	return &Extractor{pdfToTextPath: pdfToTextPath}
+}
+
+// Extract returns the plain text of a document
+func (e *Extractor) Extract(ctx context.Context, document *domain.Media) (string, error) {
+	if document == nil || len(document.Data) == 0 {
+		return "", fmt.Errorf("no document to extract")
+	}
+
+	switch formatOf(document.MimeType, document.FileName) {
+	case "pdf":
+		return e.extractPDF(ctx, document.Data)
+	case "text":
+		if !utf8.Valid(document.Data) {
+			return "", fmt.Errorf("document is not valid UTF-8 text")
+		}
+		return strings.TrimSpace(string(document.Data)), nil
+	default:
+		return "", fmt.Errorf("unsupported document type %q", document.MimeType)
+	}
+}
+
+// extractPDF runs pdftotext, keeping the page layout's paragraph breaks
+func (e *Extractor) extractPDF(ctx context.Context, data []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, e.pdfToTextPath, "-enc", "UTF-8", "-q", "-", "-")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to extract PDF text with pdftotext: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// pdftotext separates pages with form feeds
+	text := strings.ReplaceAll(stdout.String(), "\f", "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+// formatOf classifies a file as "pdf" or "text", or "" if unsupported
+func formatOf(mimeType, fileName string) string {
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	switch {
+	case mimeType == "application/pdf":
+		return "pdf"
+	case mimeType == "text/plain", mimeType == "text/markdown", mimeType == "text/x-markdown":
+		return "text"
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		return "pdf"
+	case ".txt", ".md", ".markdown":
+		return "text"
+	}
+
+	return ""
+}
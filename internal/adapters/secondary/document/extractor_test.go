@@ -0,0 +1,54 @@
+package document
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestExtractor_Extract(t *testing.T) {
+	extractor := NewExtractor("")
+
+	text, err := extractor.Extract(context.Background(), &domain.Media{
+		Type:     domain.MediaTypeDocument,
+		MimeType: "application/octet-stream",
+		FileName: "notes.md",
+		Data:     []byte("# Trip\n\nFlight leaves at 9.\n"),
+	})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if text != "# Trip\n\nFlight leaves at 9." {
+		t.Errorf("Unexpected text: %q", text)
+	}
+
+	_, err = extractor.Extract(context.Background(), &domain.Media{
+		Type:     domain.MediaTypeDocument,
+		MimeType: "application/zip",
+		FileName: "photos.zip",
+		Data:     []byte("PK"),
+	})
+	if err == nil {
+		t.Error("Expected error for unsupported document type")
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		fileName string
+		want     string
+	}{
+		{"application/pdf", "", "pdf"},
+		{"text/plain; charset=utf-8", "", "text"},
+		{"application/octet-stream", "README.MD", "text"},
+		{"application/vnd.ms-excel", "budget.xls", ""},
+	}
+
+	for _, tt := range tests {
+		if got := formatOf(tt.mimeType, tt.fileName); got != tt.want {
+			t.Errorf("formatOf(%q, %q) = %q, want %q", tt.mimeType, tt.fileName, got, tt.want)
+		}
+	}
+}
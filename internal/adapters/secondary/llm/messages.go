@@ -0,0 +1,17 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// messageText returns the text sent to the model for a chat message.
+// Group chats have many human speakers sharing the user role, so their
+// display name is prefixed to keep them apart.
+func messageText(msg domain.ChatMessage) string {
+	if msg.Role == domain.ChatRoleUser && msg.SenderName != "" {
+		return fmt.Sprintf("%s: %s", msg.SenderName, msg.Content)
+	}
+	return msg.Content
+}
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 // OllamaProvider implements LLMProvider interface
 type OllamaProvider struct {
 	llm         *ollama.LLM
+	httpClient  *http.Client // for requests langchaingo does not support, e.g. tools
+	url         string
 	model       string
 	temperature float64
 	timeout     time.Duration
@@ -31,6 +34,8 @@ func NewOllamaProvider(url, model string, temperature float64, timeout time.Dura
 
 	return &OllamaProvider{
 		llm:         llm,
+		httpClient:  &http.Client{},
+		url:         strings.TrimRight(url, "/"),
 		model:       model,
 		temperature: temperature,
 		timeout:     timeout,
@@ -43,26 +48,53 @@ func (p *OllamaProvider) Generate(ctx context.Context, request *domain.LLMReques
 	ctx, cancel := context.WithTimeout(ctx, p.timeout)
 	defer cancel()
 
-	// Build prompt with context
-	prompt := p.buildPrompt(request)
+	// langchaingo's Ollama client cannot pass tools or their results, so talk to /api/chat
+	// directly; this includes the final round, where tools are withheld but results remain
+	if len(request.Tools) > 0 || hasToolHistory(request) {
+		return p.generateWithTools(ctx, request)
+	}
 
-	// Generate response
-	response, err := llms.GenerateFromSinglePrompt(
-		ctx,
-		p.llm,
-		prompt,
-		llms.WithTemperature(p.temperature),
-		llms.WithModel(p.model),
-	)
+	// Generate response from role-tagged messages
+	response, err := p.llm.GenerateContent(ctx, p.buildMessages(request), p.callOptions(request)...)
+	if err != nil {
+		return &domain.LLMResponse{
+			Error: fmt.Errorf("failed to generate response: %w", err),
+		}, err
+	}
+
+	return p.toResponse(response)
+}
+
+// GenerateStream generates a response from the LLM, passing partial output to onChunk as it arrives
+func (p *OllamaProvider) GenerateStream(ctx context.Context, request *domain.LLMRequest, onChunk func(chunk string) error) (*domain.LLMResponse, error) {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
 
+	// Generate response, forwarding each streamed chunk
+	options := append(p.callOptions(request), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+		return onChunk(string(chunk))
+	}))
+
+	response, err := p.llm.GenerateContent(ctx, p.buildMessages(request), options...)
 	if err != nil {
 		return &domain.LLMResponse{
 			Error: fmt.Errorf("failed to generate response: %w", err),
 		}, err
 	}
 
+	return p.toResponse(response)
+}
+
+// toResponse extracts the generated text from a langchaingo response
+func (p *OllamaProvider) toResponse(response *llms.ContentResponse) (*domain.LLMResponse, error) {
+	if len(response.Choices) == 0 {
+		err := fmt.Errorf("failed to generate response: no choices returned")
+		return &domain.LLMResponse{Error: err}, err
+	}
+
 	return &domain.LLMResponse{
-		Content: response,
+		Content: response.Choices[0].Content,
 		Error:   nil,
 	}, nil
 }
@@ -83,37 +115,44 @@ func (p *OllamaProvider) IsAvailable(ctx context.Context) bool {
 	return err == nil
 }
 
-// buildPrompt constructs a prompt with conversation context
-func (p *OllamaProvider) buildPrompt(request *domain.LLMRequest) string {
-	var builder strings.Builder
-
-	// Add system instruction
-	builder.WriteString("You are a helpful AI assistant in a WhatsApp group chat. ")
-	builder.WriteString("Provide concise, friendly, and helpful responses. ")
-	builder.WriteString("Keep your answers brief and to the point.\n\n")
-
-	// Add conversation context if available
-	if len(request.Context) > 0 {
-		builder.WriteString("Recent conversation:\n")
-		// Only include last 5 messages for context
-		start := 0
-		if len(request.Context) > 5 {
-			start = len(request.Context) - 5
+// callOptions returns the model and temperature to use, honouring request overrides
+func (p *OllamaProvider) callOptions(request *domain.LLMRequest) []llms.CallOption {
+	model := p.model
+	if request.Model != "" {
+		model = request.Model
+	}
+
+	temperature := p.temperature
+	if request.Temperature != nil {
+		temperature = *request.Temperature
+	}
+
+	return []llms.CallOption{
+		llms.WithTemperature(temperature),
+		llms.WithModel(model),
+	}
+}
+
+// buildMessages converts the request into langchaingo chat messages
+func (p *OllamaProvider) buildMessages(request *domain.LLMRequest) []llms.MessageContent {
+	messages := make([]llms.MessageContent, 0, len(request.Messages)+1)
+
+	if request.SystemPrompt != "" {
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem, request.SystemPrompt))
+	}
+
+	for _, msg := range request.Messages {
+		role := llms.ChatMessageTypeHuman
+		if msg.Role == domain.ChatRoleAssistant {
+			role = llms.ChatMessageTypeAI
 		}
 
-		for i := start; i < len(request.Context); i++ {
-			msg := request.Context[i]
-			if msg.IsFromBot {
-				builder.WriteString(fmt.Sprintf("Assistant: %s\n", msg.Content))
-			} else {
-				builder.WriteString(fmt.Sprintf("User: %s\n", msg.Content))
-			}
+		parts := []llms.ContentPart{llms.TextPart(messageText(msg))}
+		for _, image := range msg.Images {
+			parts = append(parts, llms.BinaryPart(image.MimeType, image.Data))
 		}
-		builder.WriteString("\n")
+		messages = append(messages, llms.MessageContent{Role: role, Parts: parts})
 	}
 
-	// Add current prompt
-	builder.WriteString(fmt.Sprintf("User: %s\nAssistant:", request.Prompt))
-
-	return builder.String()
+	return messages
 }
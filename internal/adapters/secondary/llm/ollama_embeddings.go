@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaEmbedder implements Embedder using the Ollama /api/embeddings endpoint
+type OllamaEmbedder struct {
+	httpClient *http.Client
+	url        string
+	model      string
+}
+
+// ollamaEmbeddingRequest is the request body for /api/embeddings
+type ollamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// ollamaEmbeddingResponse is the response body for /api/embeddings
+type ollamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NewOllamaEmbedder creates a new Ollama embedder for an embedding model, e.g. "nomic-embed-text"
+func NewOllamaEmbedder(url, model string, timeout time.Duration) *OllamaEmbedder {
+	return &OllamaEmbedder{
+		httpClient: &http.Client{Timeout: timeout},
+		url:        strings.TrimRight(url, "/"),
+		model:      model,
+	}
+}
+
+// Embed returns the embedding vector of the text
+func (e *OllamaEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	jsonData, err := json.Marshal(ollamaEmbeddingRequest{Model: e.model, Prompt: text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url+"/api/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed text: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to embed text: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var embedResp ollamaEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if embedResp.Error != "" {
+		return nil, fmt.Errorf("failed to embed text: %s", embedResp.Error)
+	}
+	if len(embedResp.Embedding) == 0 {
+		return nil, fmt.Errorf("model %s returned an empty embedding", e.model)
+	}
+
+	return embedResp.Embedding, nil
+}
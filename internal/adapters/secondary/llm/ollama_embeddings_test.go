@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOllamaEmbedder_Embed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		var req ollamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Model != "nomic-embed-text" || req.Prompt != "opening hours" {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"embedding":[0.5,-0.25,1]}`)
+	}))
+	defer server.Close()
+
+	embedder := NewOllamaEmbedder(server.URL+"/", "nomic-embed-text", 5*time.Second)
+	embedding, err := embedder.Embed(context.Background(), "opening hours")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(embedding) != 3 || embedding[0] != 0.5 || embedding[1] != -0.25 {
+		t.Errorf("Unexpected embedding %v", embedding)
+	}
+}
+
+func TestOllamaEmbedder_EmbedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	embedder := NewOllamaEmbedder(server.URL, "missing", 5*time.Second)
+	if _, err := embedder.Embed(context.Background(), "hello"); err == nil {
+		t.Fatal("Expected error for missing model")
+	}
+}
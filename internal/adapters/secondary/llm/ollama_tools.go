@@ -0,0 +1,150 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// ollamaChatMessage is a single chat message in the Ollama /api/chat format
+type ollamaChatMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	Images    [][]byte         `json:"images,omitempty"` // base64-encoded by encoding/json
+}
+
+// ollamaToolCall is a function call requested by the model.
+// Unlike OpenAI, Ollama returns arguments as a JSON object and assigns no call IDs.
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChatRequest is the request body for /api/chat
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []ollamaChatMessage `json:"messages"`
+	Tools    []openAITool        `json:"tools,omitempty"` // same shape as OpenAI function tools
+	Stream   bool                `json:"stream"`
+	Options  map[string]float64  `json:"options,omitempty"`
+}
+
+// ollamaChatResponse is the non-streaming response body for /api/chat
+type ollamaChatResponse struct {
+	Message ollamaChatMessage `json:"message"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// generateWithTools sends a chat request with tool definitions, if any, to /api/chat
+func (p *OllamaProvider) generateWithTools(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	payload := ollamaChatRequest{
+		Model:    p.model,
+		Messages: p.buildChatMessages(request),
+		Tools:    buildOpenAITools(request.Tools),
+		Options:  map[string]float64{"temperature": p.temperature},
+	}
+	if request.Model != "" {
+		payload.Model = request.Model
+	}
+	if request.Temperature != nil {
+		payload.Options["temperature"] = *request.Temperature
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal request: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		err = fmt.Errorf("failed to create request: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to generate response: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err = fmt.Errorf("failed to generate response: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		err = fmt.Errorf("failed to decode response: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+	if chatResp.Error != "" {
+		err = fmt.Errorf("failed to generate response: %s", chatResp.Error)
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	toolCalls := make([]domain.ToolCall, 0, len(chatResp.Message.ToolCalls))
+	for i, call := range chatResp.Message.ToolCalls {
+		toolCalls = append(toolCalls, domain.ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      call.Function.Name,
+			Arguments: string(call.Function.Arguments),
+		})
+	}
+
+	return &domain.LLMResponse{
+		Content:   chatResp.Message.Content,
+		ToolCalls: toolCalls,
+		Error:     nil,
+	}, nil
+}
+
+// hasToolHistory reports whether the conversation contains tool calls or their results
+func hasToolHistory(request *domain.LLMRequest) bool {
+	for _, msg := range request.Messages {
+		if msg.Role == domain.ChatRoleTool || len(msg.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildChatMessages converts the request into Ollama /api/chat messages
+func (p *OllamaProvider) buildChatMessages(request *domain.LLMRequest) []ollamaChatMessage {
+	messages := make([]ollamaChatMessage, 0, len(request.Messages)+1)
+
+	if request.SystemPrompt != "" {
+		messages = append(messages, ollamaChatMessage{Role: "system", Content: request.SystemPrompt})
+	}
+
+	for _, msg := range request.Messages {
+		chatMsg := ollamaChatMessage{Role: string(msg.Role), Content: messageText(msg)}
+		for _, image := range msg.Images {
+			chatMsg.Images = append(chatMsg.Images, image.Data)
+		}
+		for _, call := range msg.ToolCalls {
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = json.RawMessage(call.Arguments)
+			if len(toolCall.Function.Arguments) == 0 {
+				toolCall.Function.Arguments = json.RawMessage("{}")
+			}
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, toolCall)
+		}
+		messages = append(messages, chatMsg)
+	}
+
+	return messages
+}
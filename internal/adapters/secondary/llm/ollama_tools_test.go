@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestOllamaProvider_GenerateWithTools(t *testing.T) {
+	var requests []ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(req.Tools) > 0 {
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_time","arguments":{"zone":"UTC"}}}]}}`)
+			return
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"It is noon."}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(server.URL, "llama3", 0.7, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOllamaProvider() error = %v", err)
+	}
+
+	request := &domain.LLMRequest{
+		SystemPrompt: "Be brief.",
+		Messages:     []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: "What time is it?"}},
+		Tools:        []domain.ToolDefinition{{Name: "get_time", Description: "Current time"}},
+	}
+	response, err := provider.Generate(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].Name != "get_time" || response.ToolCalls[0].Arguments != `{"zone":"UTC"}` {
+		t.Fatalf("Unexpected tool calls %+v", response.ToolCalls)
+	}
+	if first := requests[0]; first.Model != "llama3" || first.Stream || len(first.Messages) != 2 || first.Messages[0].Role != "system" {
+		t.Errorf("Unexpected request %+v", first)
+	}
+
+	// The last round withholds the tools but must still send the tool history to /api/chat
+	request.Messages = append(request.Messages,
+		domain.ChatMessage{Role: domain.ChatRoleAssistant, ToolCalls: response.ToolCalls},
+		domain.ChatMessage{Role: domain.ChatRoleTool, Content: "12:00", ToolCallID: response.ToolCalls[0].ID},
+	)
+	request.Tools = nil
+	response, err = provider.Generate(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if response.Content != "It is noon." {
+		t.Errorf("Expected the final answer, got %q", response.Content)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests to /api/chat, got %d", len(requests))
+	}
+	messages := requests[1].Messages
+	if len(messages) != 4 || messages[2].ToolCalls[0].Function.Name != "get_time" || messages[3].Role != "tool" || messages[3].Content != "12:00" {
+		t.Errorf("Unexpected tool history %+v", messages)
+	}
+}
+
+func TestOllamaProvider_GenerateWithToolsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model does not support tools"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	provider, err := NewOllamaProvider(server.URL, "tiny", 0.7, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOllamaProvider() error = %v", err)
+	}
+
+	request := &domain.LLMRequest{
+		Messages: []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: "hi"}},
+		Tools:    []domain.ToolDefinition{{Name: "get_time"}},
+	}
+	if _, err := provider.Generate(context.Background(), request); err == nil {
+		t.Fatal("Expected error for a model without tool support")
+	}
+}
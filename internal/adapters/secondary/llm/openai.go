@@ -0,0 +1,340 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// OpenAIProvider implements LLMProvider for any server speaking the OpenAI
+// /v1/chat/completions API (OpenAI, vLLM, llama.cpp server, LocalAI, ...)
+type OpenAIProvider struct {
+	httpClient  *http.Client
+	baseURL     string
+	apiKey      string
+	model       string
+	temperature float64
+	timeout     time.Duration
+}
+
+// openAIMessage is a single chat message in the OpenAI wire format
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    openAIContent    `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIContent is message content: plain text, or text and images as content parts
+type openAIContent struct {
+	Text   string
+	Images []*domain.Media
+}
+
+// MarshalJSON encodes text-only content as a string and multimodal content as parts
+func (c openAIContent) MarshalJSON() ([]byte, error) {
+	if len(c.Images) == 0 {
+		return json.Marshal(c.Text)
+	}
+
+	parts := []map[string]interface{}{{"type": "text", "text": c.Text}}
+	for _, image := range c.Images {
+		url := fmt.Sprintf("data:%s;base64,%s", image.MimeType, base64.StdEncoding.EncodeToString(image.Data))
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": url},
+		})
+	}
+	return json.Marshal(parts)
+}
+
+// UnmarshalJSON decodes string content; responses never contain content parts
+func (c *openAIContent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &c.Text)
+}
+
+// openAIToolCall is a function call requested by the model
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAITool describes a callable function
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// openAIChatRequest is the request body for /chat/completions
+type openAIChatRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
+	Tools       []openAITool    `json:"tools,omitempty"`
+}
+
+// openAIChatResponse is the response body for /chat/completions
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIProvider creates a new OpenAI-compatible LLM provider.
+// baseURL should include the API version prefix, e.g. "http://localhost:8000/v1".
+func NewOpenAIProvider(baseURL, apiKey, model string, temperature float64, timeout time.Duration) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	return &OpenAIProvider{
+		httpClient: &http.Client{
+			// No timeout here - we'll use context timeout instead
+			Timeout: 0,
+		},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		apiKey:      apiKey,
+		model:       model,
+		temperature: temperature,
+		timeout:     timeout,
+	}, nil
+}
+
+// Generate generates a response from the LLM
+func (p *OpenAIProvider) Generate(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.doChat(ctx, request, false)
+	if err != nil {
+		return &domain.LLMResponse{Error: err}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		err = fmt.Errorf("failed to decode response: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		err = fmt.Errorf("failed to generate response: no choices returned")
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	message := chatResp.Choices[0].Message
+	toolCalls := make([]domain.ToolCall, 0, len(message.ToolCalls))
+	for _, call := range message.ToolCalls {
+		toolCalls = append(toolCalls, domain.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+
+	return &domain.LLMResponse{
+		Content:   message.Content.Text,
+		ToolCalls: toolCalls,
+		Error:     nil,
+	}, nil
+}
+
+// GenerateStream generates a response from the LLM, passing partial output to onChunk as it arrives
+func (p *OpenAIProvider) GenerateStream(ctx context.Context, request *domain.LLMRequest, onChunk func(chunk string) error) (*domain.LLMResponse, error) {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.doChat(ctx, request, true)
+	if err != nil {
+		return &domain.LLMResponse{Error: err}, err
+	}
+	defer resp.Body.Close()
+
+	// Server-sent events: each event is a "data: {json}" line, terminated by "data: [DONE]"
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var event openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			err = fmt.Errorf("failed to decode stream event: %w", err)
+			return &domain.LLMResponse{Error: err}, err
+		}
+		if event.Error != nil {
+			err = fmt.Errorf("failed to generate response: %s", event.Error.Message)
+			return &domain.LLMResponse{Error: err}, err
+		}
+
+		if len(event.Choices) == 0 || event.Choices[0].Delta.Content.Text == "" {
+			continue
+		}
+
+		chunk := event.Choices[0].Delta.Content.Text
+		content.WriteString(chunk)
+		if err := onChunk(chunk); err != nil {
+			return &domain.LLMResponse{Error: err}, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		err = fmt.Errorf("failed to read stream: %w", err)
+		return &domain.LLMResponse{Error: err}, err
+	}
+
+	return &domain.LLMResponse{
+		Content: content.String(),
+		Error:   nil,
+	}, nil
+}
+
+// IsAvailable checks if the LLM service is available
+func (p *OpenAIProvider) IsAvailable(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return false
+	}
+	p.setAuth(req)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// doChat sends a chat completion request and returns the successful HTTP response
+func (p *OpenAIProvider) doChat(ctx context.Context, request *domain.LLMRequest, stream bool) (*http.Response, error) {
+	payload := openAIChatRequest{
+		Model:       p.model,
+		Messages:    p.buildMessages(request),
+		Temperature: p.temperature,
+		Stream:      stream,
+		Tools:       buildOpenAITools(request.Tools),
+	}
+
+	// Apply per-request overrides
+	if request.Model != "" {
+		payload.Model = request.Model
+	}
+	if request.Temperature != nil {
+		payload.Temperature = *request.Temperature
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	p.setAuth(req)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to generate response: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// setAuth adds the bearer token if an API key is configured
+func (p *OpenAIProvider) setAuth(req *http.Request) {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+}
+
+// buildMessages converts the request into OpenAI chat messages
+func (p *OpenAIProvider) buildMessages(request *domain.LLMRequest) []openAIMessage {
+	messages := make([]openAIMessage, 0, len(request.Messages)+1)
+
+	if request.SystemPrompt != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: openAIContent{Text: request.SystemPrompt}})
+	}
+
+	for _, msg := range request.Messages {
+		wireMsg := openAIMessage{
+			Role:       string(msg.Role),
+			Content:    openAIContent{Text: messageText(msg), Images: msg.Images},
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			wireCall := openAIToolCall{ID: call.ID, Type: "function"}
+			wireCall.Function.Name = call.Name
+			wireCall.Function.Arguments = call.Arguments
+			wireMsg.ToolCalls = append(wireMsg.ToolCalls, wireCall)
+		}
+		messages = append(messages, wireMsg)
+	}
+
+	return messages
+}
+
+// buildOpenAITools converts tool definitions into OpenAI function tools
+func buildOpenAITools(definitions []domain.ToolDefinition) []openAITool {
+	tools := make([]openAITool, 0, len(definitions))
+	for _, definition := range definitions {
+		tool := openAITool{Type: "function"}
+		tool.Function.Name = definition.Name
+		tool.Function.Description = definition.Description
+		tool.Function.Parameters = definition.Parameters
+		tools = append(tools, tool)
+	}
+	return tools
+}
@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestOpenAIProvider_Generate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.Model != "test-model" {
+			t.Errorf("Expected model test-model, got %s", req.Model)
+		}
+		if len(req.Messages) != 3 || req.Messages[0].Role != "system" || req.Messages[1].Role != "assistant" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+		}
+		if len(req.Messages) == 3 && req.Messages[2].Content.Text != "Alice: Hello" {
+			t.Errorf("Expected sender name prefix, got %q", req.Messages[2].Content.Text)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hi there"}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(server.URL+"/v1", "secret", "test-model", 0.5, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() error = %v", err)
+	}
+
+	response, err := provider.Generate(context.Background(), &domain.LLMRequest{
+		SystemPrompt: "Be nice.",
+		Messages: []domain.ChatMessage{
+			{Role: domain.ChatRoleAssistant, Content: "Earlier answer"},
+			{Role: domain.ChatRoleUser, SenderName: "Alice", Content: "Hello"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if response.Content != "Hi there" {
+		t.Errorf("Expected %q, got %q", "Hi there", response.Content)
+	}
+}
+
+func TestOpenAIProvider_GenerateStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(server.URL, "", "test-model", 0.5, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() error = %v", err)
+	}
+
+	var chunks []string
+	response, err := provider.GenerateStream(context.Background(), &domain.LLMRequest{Messages: []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: "Hello"}}}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("Expected 3 chunks, got %d", len(chunks))
+	}
+	if response.Content != "Hello!" {
+		t.Errorf("Expected %q, got %q", "Hello!", response.Content)
+	}
+}
+
+func TestOpenAIProvider_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(server.URL, "", "test-model", 0.5, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() error = %v", err)
+	}
+
+	response, err := provider.Generate(context.Background(), &domain.LLMRequest{Messages: []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: "Hello"}}})
+	if err == nil {
+		t.Fatal("Expected error for non-200 status")
+	}
+	if response == nil || response.Error == nil {
+		t.Error("Expected response.Error to be set")
+	}
+}
+
+func TestOpenAIProvider_ToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if len(req.Tools) != 1 || req.Tools[0].Function.Name != "get_server_time" {
+			t.Errorf("Expected get_server_time tool, got %+v", req.Tools)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_server_time","arguments":"{}"}}]}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(server.URL, "", "test-model", 0.5, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() error = %v", err)
+	}
+
+	response, err := provider.Generate(context.Background(), &domain.LLMRequest{
+		Messages: []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: "What time is it?"}},
+		Tools:    []domain.ToolDefinition{{Name: "get_server_time", Description: "Current time"}},
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].ID != "call_1" || response.ToolCalls[0].Name != "get_server_time" {
+		t.Errorf("Unexpected tool calls: %+v", response.ToolCalls)
+	}
+}
+
+func TestOpenAIContent_MarshalJSON(t *testing.T) {
+	text, err := json.Marshal(openAIContent{Text: "Hello"})
+	if err != nil || string(text) != `"Hello"` {
+		t.Errorf("Expected plain string content, got %s (%v)", text, err)
+	}
+
+	multimodal, err := json.Marshal(openAIContent{
+		Text:   "What is this?",
+		Images: []*domain.Media{{Type: domain.MediaTypeImage, MimeType: "image/png", Data: []byte("png")}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var parts []map[string]interface{}
+	if err := json.Unmarshal(multimodal, &parts); err != nil {
+		t.Fatalf("Expected content parts, got %s", multimodal)
+	}
+	if len(parts) != 2 || parts[1]["type"] != "image_url" {
+		t.Fatalf("Unexpected parts: %s", multimodal)
+	}
+	url := parts[1]["image_url"].(map[string]interface{})["url"]
+	if url != "data:image/png;base64,cG5n" {
+		t.Errorf("Unexpected image URL %v", url)
+	}
+}
@@ -0,0 +1,117 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// maxAudioSize caps the synthesized audio read from the server
+const maxAudioSize = 16 << 20
+
+// TTSClient implements SpeechSynthesizer against a local text-to-speech HTTP server.
+// It works with the Piper HTTP server and the Coqui TTS server (/api/tts): both
+// accept the text as a "text" query parameter and answer with WAV audio, which is
+// converted to OGG/Opus with ffmpeg. Servers that already return OGG are used as-is.
+type TTSClient struct {
+	httpClient *http.Client
+	url        string
+	voice      string
+	ffmpegPath string
+}
+
+// NewTTSClient creates a new TTS client for the given endpoint URL
+func NewTTSClient(endpoint, voice, ffmpegPath string, timeout time.Duration) *TTSClient {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	return &TTSClient{
+		httpClient: &http.Client{Timeout: timeout},
+		url:        endpoint,
+		voice:      voice,
+		ffmpegPath: ffmpegPath,
+	}
+}
+
+// Synthesize converts the text to an OGG/Opus voice note
+func (c *TTSClient) Synthesize(ctx context.Context, text string) (*domain.Media, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("no text to synthesize")
+	}
+
+	endpoint, err := url.Parse(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TTS url: %w", err)
+	}
+
+	query := endpoint.Query()
+	query.Set("text", text)
+	if c.voice != "" {
+		// Piper reads "voice", Coqui reads "speaker_id"
+		query.Set("voice", c.voice)
+		query.Set("speaker_id", c.voice)
+	}
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAudioSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TTS server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "audio/ogg") {
+		return &domain.Media{Type: domain.MediaTypeAudio, MimeType: "audio/ogg; codecs=opus", Data: data}, nil
+	}
+
+	ogg, err := c.convertToOpus(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Media{Type: domain.MediaTypeAudio, MimeType: "audio/ogg; codecs=opus", Data: ogg}, nil
+}
+
+// convertToOpus transcodes audio to mono OGG/Opus, the format WhatsApp plays as a voice note
+func (c *TTSClient) convertToOpus(ctx context.Context, audio []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", "pipe:0",
+		"-ac", "1", "-c:a", "libopus", "-b:a", "32k", "-application", "voip",
+		"-f", "ogg", "pipe:1",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(audio)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to convert audio with ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
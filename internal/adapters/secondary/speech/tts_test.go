@@ -0,0 +1,46 @@
+package speech
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTTSClient_Synthesize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("text"); got != "Good morning!" {
+			t.Errorf("Expected text %q, got %q", "Good morning!", got)
+		}
+		if got := r.URL.Query().Get("speaker_id"); got != "p225" {
+			t.Errorf("Expected speaker_id p225, got %q", got)
+		}
+
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("ogg-bytes"))
+	}))
+	defer server.Close()
+
+	client := NewTTSClient(server.URL+"/api/tts", "p225", "", 5*time.Second)
+	media, err := client.Synthesize(context.Background(), " Good morning! ")
+	if err != nil {
+		t.Fatalf("Synthesize failed: %v", err)
+	}
+
+	if string(media.Data) != "ogg-bytes" || media.MimeType != "audio/ogg; codecs=opus" {
+		t.Errorf("Unexpected media: %s %q", media.MimeType, media.Data)
+	}
+}
+
+func TestTTSClient_SynthesizeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "voice not found", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewTTSClient(server.URL, "", "", 5*time.Second)
+	if _, err := client.Synthesize(context.Background(), "Hello"); err == nil {
+		t.Fatal("Expected error for failed request")
+	}
+}
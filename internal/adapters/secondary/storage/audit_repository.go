@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// defaultAuditLimit caps the entries returned by a single audit query
+const defaultAuditLimit = 100
+
+// AuditRepository implements domain.AuditRepository using SQLite. The table rejects updates
+// and deletes, so entries can only be appended.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(dbPath string) (*AuditRepository, error) {
+	// Ensure the directory exists
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	repo := &AuditRepository{db: db}
+	if err := repo.initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return repo, nil
+}
+
+// initialize creates the audit table and the triggers that keep it append-only
+func (r *AuditRepository) initialize() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		before_state TEXT,
+		after_state TEXT,
+		diff TEXT,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_created ON audit_log(created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_log(actor, created_at DESC);
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;
+	`
+
+	_, err := r.db.Exec(schema)
+	return err
+}
+
+// Append adds an entry to the audit log
+func (r *AuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (actor, action, target, before_state, after_state, diff, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		entry.Actor,
+		entry.Action,
+		entry.Target,
+		entry.Before,
+		entry.After,
+		entry.Diff,
+		entry.Timestamp.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// List returns the audit entries matching the filter, newest first
+func (r *AuditRepository) List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if strings.HasSuffix(filter.Action, ".") {
+		conditions = append(conditions, "action LIKE ? ESCAPE '\\'")
+		args = append(args, escapeLike(filter.Action)+"%")
+	} else if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.Target != "" {
+		conditions = append(conditions, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	query := `SELECT id, actor, action, target, before_state, after_state, diff, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*domain.AuditEntry
+	for rows.Next() {
+		entry := &domain.AuditEntry{}
+		var before, after, diff sql.NullString
+
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &before, &after, &diff, &entry.Timestamp); err != nil {
+			return nil, err
+		}
+
+		entry.Before = before.String
+		entry.After = after.String
+		entry.Diff = diff.String
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Close closes the database connection
+func (r *AuditRepository) Close() error {
+	return r.db.Close()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
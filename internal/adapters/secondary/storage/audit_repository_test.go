@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestAuditRepository_AppendAndFilter(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewAuditRepository(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("NewAuditRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	base := time.Now().Add(-time.Hour)
+	entries := []*domain.AuditEntry{
+		{Actor: "alice", Action: "webhook.add", Target: "#family", After: `{"sub_trigger":"#family"}`, Diff: "created", Timestamp: base},
+		{Actor: "bob", Action: "allowed_groups.update", Target: "allowed_groups", Timestamp: base.Add(time.Minute)},
+		{Actor: "bob", Action: "webhook.delete", Target: "#family", Before: `{"sub_trigger":"#family"}`, Diff: "deleted", Timestamp: base.Add(2 * time.Minute)},
+	}
+	for _, entry := range entries {
+		if err := repo.Append(ctx, entry); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if entry.ID == 0 {
+			t.Error("Expected Append() to set the entry ID")
+		}
+	}
+
+	all, err := repo.List(ctx, domain.AuditFilter{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(all) != 3 || all[0].Action != "webhook.delete" || all[2].Action != "webhook.add" {
+		t.Fatalf("Expected all entries newest first, got %+v", all)
+	}
+	if all[0].Before != `{"sub_trigger":"#family"}` || all[0].Diff != "deleted" {
+		t.Errorf("Expected states to round-trip, got %+v", all[0])
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.AuditFilter
+		want   int
+	}{
+		{"actor", domain.AuditFilter{Actor: "bob"}, 2},
+		{"exact action", domain.AuditFilter{Action: "webhook.delete"}, 1},
+		{"action prefix", domain.AuditFilter{Action: "webhook."}, 2},
+		{"target", domain.AuditFilter{Target: "#family"}, 2},
+		{"since", domain.AuditFilter{Since: base.Add(30 * time.Second)}, 2},
+		{"until", domain.AuditFilter{Until: base.Add(30 * time.Second)}, 1},
+		{"limit", domain.AuditFilter{Limit: 1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("List() returned %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditRepository_AppendOnly(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewAuditRepository(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("NewAuditRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	if err := repo.Append(ctx, &domain.AuditEntry{Actor: "alice", Action: "webhook.delete", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	if _, err := repo.db.Exec(`UPDATE audit_log SET actor = 'mallory'`); err == nil {
+		t.Error("Expected updating the audit log to fail")
+	}
+	if _, err := repo.db.Exec(`DELETE FROM audit_log`); err == nil {
+		t.Error("Expected deleting from the audit log to fail")
+	}
+}
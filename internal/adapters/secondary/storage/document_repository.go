@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MemoryDocumentRepository implements DocumentRepository using in-memory storage
+type MemoryDocumentRepository struct {
+	documents map[string][]*domain.Document // chatJID -> documents, oldest first
+	mu        sync.RWMutex
+}
+
+// NewMemoryDocumentRepository creates a new in-memory document repository
+func NewMemoryDocumentRepository() *MemoryDocumentRepository {
+	return &MemoryDocumentRepository{
+		documents: make(map[string][]*domain.Document),
+	}
+}
+
+// Save stores a document, replacing one with the same ID in the chat
+func (r *MemoryDocumentRepository) Save(ctx context.Context, document *domain.Document) error {
+	if document.ChatJID == "" || document.ID == "" {
+		return fmt.Errorf("chat JID and document ID are required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *document
+	documents := r.documents[document.ChatJID]
+	for i, existing := range documents {
+		if existing.ID == document.ID {
+			documents[i] = &copied
+			return nil
+		}
+	}
+
+	documents = append(documents, &copied)
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].CreatedAt.Before(documents[j].CreatedAt)
+	})
+	r.documents[document.ChatJID] = documents
+	return nil
+}
+
+// Get retrieves a document, or nil if it does not exist
+func (r *MemoryDocumentRepository) Get(ctx context.Context, chatJID, documentID string) (*domain.Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, document := range r.documents[chatJID] {
+		if document.ID == documentID {
+			copied := *document
+			return &copied, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetByChatJID retrieves the last N documents shared in a chat, newest first
+func (r *MemoryDocumentRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	documents := r.documents[chatJID]
+	result := []*domain.Document{}
+	for i := len(documents) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		copied := *documents[i]
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+// SQLiteDocumentRepository implements DocumentRepository using the message database.
+// Obtain one via SQLiteRepository.Documents so the schema is migrated.
+type SQLiteDocumentRepository struct {
+	db *sql.DB
+}
+
+// Save stores a document and its chunks, replacing one with the same ID in the chat
+func (r *SQLiteDocumentRepository) Save(ctx context.Context, document *domain.Document) error {
+	if document.ChatJID == "" || document.ID == "" {
+		return fmt.Errorf("chat JID and document ID are required")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO documents (chat_jid, id, file_name, sender, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_jid, id) DO UPDATE SET
+			file_name = excluded.file_name,
+			sender = excluded.sender,
+			created_at = excluded.created_at
+	`
+	if _, err := tx.ExecContext(ctx, query,
+		document.ChatJID,
+		document.ID,
+		document.FileName,
+		document.Sender,
+		document.CreatedAt.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to save document: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM document_chunks WHERE chat_jid = ? AND document_id = ?`, document.ChatJID, document.ID); err != nil {
+		return fmt.Errorf("failed to replace document chunks: %w", err)
+	}
+
+	for i, chunk := range document.Chunks {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO document_chunks (chat_jid, document_id, chunk_index, content) VALUES (?, ?, ?, ?)`,
+			document.ChatJID, document.ID, i, chunk,
+		); err != nil {
+			return fmt.Errorf("failed to save document chunk: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit document: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a document, or nil if it does not exist
+func (r *SQLiteDocumentRepository) Get(ctx context.Context, chatJID, documentID string) (*domain.Document, error) {
+	query := `
+		SELECT chat_jid, id, file_name, sender, created_at
+		FROM documents WHERE chat_jid = ? AND id = ?
+	`
+
+	document := &domain.Document{}
+	err := r.db.QueryRowContext(ctx, query, chatJID, documentID).Scan(
+		&document.ChatJID,
+		&document.ID,
+		&document.FileName,
+		&document.Sender,
+		&document.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	if err := r.loadChunks(ctx, document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// GetByChatJID retrieves the last N documents shared in a chat, newest first
+func (r *SQLiteDocumentRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Document, error) {
+	query := `
+		SELECT chat_jid, id, file_name, sender, created_at
+		FROM documents WHERE chat_jid = ?
+		ORDER BY created_at DESC LIMIT ?
+	`
+
+	// A negative LIMIT means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, chatJID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get documents: %w", err)
+	}
+
+	documents := []*domain.Document{}
+	for rows.Next() {
+		document := &domain.Document{}
+		if err := rows.Scan(
+			&document.ChatJID,
+			&document.ID,
+			&document.FileName,
+			&document.Sender,
+			&document.CreatedAt,
+		); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, document := range documents {
+		if err := r.loadChunks(ctx, document); err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// loadChunks reads the chunks of a document in order
+func (r *SQLiteDocumentRepository) loadChunks(ctx context.Context, document *domain.Document) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT content FROM document_chunks WHERE chat_jid = ? AND document_id = ? ORDER BY chunk_index`,
+		document.ChatJID, document.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get document chunks: %w", err)
+	}
+	defer rows.Close()
+
+	document.Chunks = nil
+	for rows.Next() {
+		var chunk string
+		if err := rows.Scan(&chunk); err != nil {
+			return err
+		}
+		document.Chunks = append(document.Chunks, chunk)
+	}
+
+	return rows.Err()
+}
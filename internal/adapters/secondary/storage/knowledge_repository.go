@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MemoryKnowledgeRepository implements KnowledgeRepository using in-memory storage
+type MemoryKnowledgeRepository struct {
+	documents map[string]*domain.KnowledgeDocument // documentID -> document
+	chunks    map[string][]*domain.KnowledgeChunk  // documentID -> chunks
+	mu        sync.RWMutex
+}
+
+// NewMemoryKnowledgeRepository creates a new in-memory knowledge repository
+func NewMemoryKnowledgeRepository() *MemoryKnowledgeRepository {
+	return &MemoryKnowledgeRepository{
+		documents: make(map[string]*domain.KnowledgeDocument),
+		chunks:    make(map[string][]*domain.KnowledgeChunk),
+	}
+}
+
+// SaveDocument stores a document and its embedded chunks, replacing a document with the same ID
+func (r *MemoryKnowledgeRepository) SaveDocument(ctx context.Context, document *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	if document.ID == "" || document.GroupJID == "" {
+		return fmt.Errorf("document ID and group JID are required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *document
+	copied.Chunks = len(chunks)
+	r.documents[document.ID] = &copied
+	r.chunks[document.ID] = chunks
+	return nil
+}
+
+// ListDocuments returns the documents in a group's knowledge base, oldest first
+func (r *MemoryKnowledgeRepository) ListDocuments(ctx context.Context, groupJID string) ([]*domain.KnowledgeDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	documents := []*domain.KnowledgeDocument{}
+	for _, document := range r.documents {
+		if document.GroupJID == groupJID {
+			copied := *document
+			documents = append(documents, &copied)
+		}
+	}
+
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].CreatedAt.Before(documents[j].CreatedAt)
+	})
+	return documents, nil
+}
+
+// DeleteDocument removes a document and its chunks from a group's knowledge base
+func (r *MemoryKnowledgeRepository) DeleteDocument(ctx context.Context, groupJID, documentID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	document, exists := r.documents[documentID]
+	if !exists || document.GroupJID != groupJID {
+		return fmt.Errorf("knowledge document not found: %s", documentID)
+	}
+
+	delete(r.documents, documentID)
+	delete(r.chunks, documentID)
+	return nil
+}
+
+// Search returns the group's chunks most similar to the embedding
+func (r *MemoryKnowledgeRepository) Search(ctx context.Context, groupJID string, embedding []float32, limit int) ([]*domain.KnowledgeChunk, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var candidates []*domain.KnowledgeChunk
+	for documentID, document := range r.documents {
+		if document.GroupJID != groupJID {
+			continue
+		}
+		for _, chunk := range r.chunks[documentID] {
+			copied := *chunk
+			copied.Title = document.Title
+			candidates = append(candidates, &copied)
+		}
+	}
+
+	return rankChunks(candidates, embedding, limit), nil
+}
+
+// SQLiteKnowledgeRepository implements KnowledgeRepository using the message database.
+// Embeddings are stored as float32 blobs and compared in memory, which is fast enough
+// for the few thousand chunks of a curated knowledge base.
+// Obtain one via SQLiteRepository.Knowledge so the schema is migrated.
+type SQLiteKnowledgeRepository struct {
+	db *sql.DB
+}
+
+// SaveDocument stores a document and its embedded chunks, replacing a document with the same ID
+func (r *SQLiteKnowledgeRepository) SaveDocument(ctx context.Context, document *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	if document.ID == "" || document.GroupJID == "" {
+		return fmt.Errorf("document ID and group JID are required")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO knowledge_documents (id, group_jid, title, chunks, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			group_jid = excluded.group_jid,
+			title = excluded.title,
+			chunks = excluded.chunks,
+			created_at = excluded.created_at
+	`
+	if _, err := tx.ExecContext(ctx, query,
+		document.ID,
+		document.GroupJID,
+		document.Title,
+		len(chunks),
+		document.CreatedAt.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to save knowledge document: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM knowledge_chunks WHERE document_id = ?`, document.ID); err != nil {
+		return fmt.Errorf("failed to replace knowledge chunks: %w", err)
+	}
+
+	for i, chunk := range chunks {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO knowledge_chunks (document_id, group_jid, chunk_index, content, embedding) VALUES (?, ?, ?, ?, ?)`,
+			document.ID, document.GroupJID, i, chunk.Content, encodeEmbedding(chunk.Embedding),
+		); err != nil {
+			return fmt.Errorf("failed to save knowledge chunk: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit knowledge document: %w", err)
+	}
+
+	return nil
+}
+
+// ListDocuments returns the documents in a group's knowledge base, oldest first
+func (r *SQLiteKnowledgeRepository) ListDocuments(ctx context.Context, groupJID string) ([]*domain.KnowledgeDocument, error) {
+	query := `
+		SELECT id, group_jid, title, chunks, created_at
+		FROM knowledge_documents WHERE group_jid = ?
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := []*domain.KnowledgeDocument{}
+	for rows.Next() {
+		document := &domain.KnowledgeDocument{}
+		if err := rows.Scan(
+			&document.ID,
+			&document.GroupJID,
+			&document.Title,
+			&document.Chunks,
+			&document.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, rows.Err()
+}
+
+// DeleteDocument removes a document and its chunks from a group's knowledge base
+func (r *SQLiteKnowledgeRepository) DeleteDocument(ctx context.Context, groupJID, documentID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM knowledge_documents WHERE id = ? AND group_jid = ?`, documentID, groupJID)
+	if err != nil {
+		return fmt.Errorf("failed to delete knowledge document: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("knowledge document not found: %s", documentID)
+	}
+
+	// Foreign keys are off by default in SQLite, so chunks are removed explicitly
+	if _, err := tx.ExecContext(ctx, `DELETE FROM knowledge_chunks WHERE document_id = ?`, documentID); err != nil {
+		return fmt.Errorf("failed to delete knowledge chunks: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Search returns the group's chunks most similar to the embedding
+func (r *SQLiteKnowledgeRepository) Search(ctx context.Context, groupJID string, embedding []float32, limit int) ([]*domain.KnowledgeChunk, error) {
+	query := `
+		SELECT c.document_id, d.title, c.content, c.embedding
+		FROM knowledge_chunks c
+		JOIN knowledge_documents d ON d.id = c.document_id
+		WHERE c.group_jid = ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, groupJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search knowledge base: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*domain.KnowledgeChunk
+	for rows.Next() {
+		chunk := &domain.KnowledgeChunk{}
+		var blob []byte
+		if err := rows.Scan(&chunk.DocumentID, &chunk.Title, &chunk.Content, &blob); err != nil {
+			return nil, err
+		}
+		chunk.Embedding = decodeEmbedding(blob)
+		candidates = append(candidates, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rankChunks(candidates, embedding, limit), nil
+}
+
+// rankChunks scores chunks by cosine similarity and returns the best ones
+func rankChunks(chunks []*domain.KnowledgeChunk, embedding []float32, limit int) []*domain.KnowledgeChunk {
+	ranked := make([]*domain.KnowledgeChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		// Vectors from a different embedding model cannot be compared
+		if len(chunk.Embedding) != len(embedding) {
+			continue
+		}
+		chunk.Score = cosineSimilarity(chunk.Embedding, embedding)
+		ranked = append(ranked, chunk)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors of equal length
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// encodeEmbedding serializes a vector as little-endian float32s
+func encodeEmbedding(embedding []float32) []byte {
+	blob := make([]byte, 4*len(embedding))
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(blob[4*i:], math.Float32bits(value))
+	}
+	return blob
+}
+
+// decodeEmbedding deserializes a vector written by encodeEmbedding
+func decodeEmbedding(blob []byte) []float32 {
+	embedding := make([]float32, len(blob)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return embedding
+}
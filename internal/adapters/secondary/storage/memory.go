@@ -10,7 +10,7 @@ import (
 
 // MemoryRepository implements MessageRepository using in-memory storage
 type MemoryRepository struct {
-	messages map[string][]*domain.Message // groupJID -> messages
+	messages map[string][]*domain.Message // chatJID -> messages
 	mu       sync.RWMutex
 }
 
@@ -26,20 +26,25 @@ func (r *MemoryRepository) Save(ctx context.Context, message *domain.Message) er
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if message.GroupJID == "" {
-		return fmt.Errorf("group JID is required")
+	chatJID := chatKey(message)
+	if chatJID == "" {
+		return fmt.Errorf("chat JID is required")
 	}
 
-	r.messages[message.GroupJID] = append(r.messages[message.GroupJID], message)
+	// Attachments are not kept in history
+	stored := *message
+	stored.Media = nil
+
+	r.messages[chatJID] = append(r.messages[chatJID], &stored)
 	return nil
 }
 
-// GetByGroupJID retrieves messages for a specific group
-func (r *MemoryRepository) GetByGroupJID(ctx context.Context, groupJID string, limit int) ([]*domain.Message, error) {
+// GetByChatJID retrieves messages for a specific chat
+func (r *MemoryRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Message, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	messages, exists := r.messages[groupJID]
+	messages, exists := r.messages[chatJID]
 	if !exists {
 		return []*domain.Message{}, nil
 	}
@@ -68,3 +73,12 @@ func (r *MemoryRepository) GetAll(ctx context.Context) ([]*domain.Message, error
 
 	return result, nil
 }
+
+// chatKey returns the conversation a message is stored under.
+// Messages created before direct chat support only carry a group JID.
+func chatKey(message *domain.Message) string {
+	if message.ChatJID != "" {
+		return message.ChatJID
+	}
+	return message.GroupJID
+}
@@ -100,6 +100,14 @@ func (r *ScheduleRepository) initialize() error {
 		return fmt.Errorf("use_prompt migration failed: %w", err)
 	}
 
+	// Migration: Add attempts column if it doesn't exist
+	attemptsMigrationSQL := `ALTER TABLE schedule_executions ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;`
+	_, err = r.db.Exec(attemptsMigrationSQL)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		// Ignore "column already exists" error
+		return fmt.Errorf("attempts migration failed: %w", err)
+	}
+
 	return nil
 }
 
@@ -295,8 +303,8 @@ func (r *ScheduleRepository) UpdateLastRun(ctx context.Context, id string, lastR
 // LogExecution logs a schedule execution
 func (r *ScheduleRepository) LogExecution(ctx context.Context, execution *domain.ScheduleExecution) error {
 	query := `
-		INSERT INTO schedule_executions (id, schedule_id, executed_at, success, error, response)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO schedule_executions (id, schedule_id, executed_at, success, error, response, attempts)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -306,6 +314,7 @@ func (r *ScheduleRepository) LogExecution(ctx context.Context, execution *domain
 		execution.Success,
 		execution.Error,
 		execution.Response,
+		execution.Attempts,
 	)
 
 	return err
@@ -314,7 +323,7 @@ func (r *ScheduleRepository) LogExecution(ctx context.Context, execution *domain
 // GetExecutions retrieves execution logs for a schedule
 func (r *ScheduleRepository) GetExecutions(ctx context.Context, scheduleID string, limit int) ([]*domain.ScheduleExecution, error) {
 	query := `
-		SELECT id, schedule_id, executed_at, success, error, response
+		SELECT id, schedule_id, executed_at, success, error, response, attempts
 		FROM schedule_executions WHERE schedule_id = ?
 		ORDER BY executed_at DESC LIMIT ?
 	`
@@ -337,6 +346,7 @@ func (r *ScheduleRepository) GetExecutions(ctx context.Context, scheduleID strin
 			&exec.Success,
 			&errorMsg,
 			&response,
+			&exec.Attempts,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// messageMigrations holds the schema migrations for the message store.
+// Each entry is applied once, in order, and tracked via PRAGMA user_version.
+// Never edit an existing entry; append a new one instead.
+var messageMigrations = []string{
+	// 1: initial schema
+	`
+	CREATE TABLE IF NOT EXISTS messages (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		id TEXT NOT NULL,
+		group_jid TEXT NOT NULL,
+		sender TEXT NOT NULL,
+		content TEXT NOT NULL,
+		timestamp DATETIME NOT NULL,
+		is_from_bot BOOLEAN NOT NULL DEFAULT 0,
+		is_reply_to_bot BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_messages_group_timestamp ON messages(group_jid, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+	`,
+	// 2: sender display names
+	`ALTER TABLE messages ADD COLUMN sender_name TEXT NOT NULL DEFAULT '';`,
+	// 3: rolling conversation summaries
+	`
+	CREATE TABLE IF NOT EXISTS summaries (
+		group_jid TEXT PRIMARY KEY,
+		content TEXT NOT NULL,
+		covered_until DATETIME NOT NULL,
+		message_count INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL
+	);
+	`,
+	// 4: history keyed by chat JID, so direct chats (no group) have their own history
+	`
+	ALTER TABLE messages ADD COLUMN chat_jid TEXT NOT NULL DEFAULT '';
+	UPDATE messages SET chat_jid = group_jid;
+	CREATE INDEX IF NOT EXISTS idx_messages_chat_timestamp ON messages(chat_jid, timestamp);
+	`,
+	// 5: text of shared documents, split into chunks
+	`
+	CREATE TABLE IF NOT EXISTS documents (
+		chat_jid TEXT NOT NULL,
+		id TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		sender TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (chat_jid, id)
+	);
+
+	CREATE TABLE IF NOT EXISTS document_chunks (
+		chat_jid TEXT NOT NULL,
+		document_id TEXT NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		PRIMARY KEY (chat_jid, document_id, chunk_index)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_documents_chat_created ON documents(chat_jid, created_at);
+	`,
+	// 6: per-group knowledge base with embeddings
+	`
+	CREATE TABLE IF NOT EXISTS knowledge_documents (
+		id TEXT PRIMARY KEY,
+		group_jid TEXT NOT NULL,
+		title TEXT NOT NULL,
+		chunks INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS knowledge_chunks (
+		document_id TEXT NOT NULL REFERENCES knowledge_documents(id) ON DELETE CASCADE,
+		group_jid TEXT NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		PRIMARY KEY (document_id, chunk_index)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_knowledge_documents_group ON knowledge_documents(group_jid);
+	CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_group ON knowledge_chunks(group_jid);
+	`,
+}
+
+// SQLiteRepository implements MessageRepository using SQLite
+type SQLiteRepository struct {
+	db                  *sql.DB
+	maxMessagesPerGroup int
+	maxAge              time.Duration
+}
+
+// NewSQLiteRepository creates a new SQLite-backed message repository.
+// maxMessagesPerGroup and maxAge bound how much history is kept; zero disables the limit.
+func NewSQLiteRepository(dbPath string, maxMessagesPerGroup int, maxAge time.Duration) (*SQLiteRepository, error) {
+	// Ensure the directory exists
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	repo := &SQLiteRepository{
+		db:                  db,
+		maxMessagesPerGroup: maxMessagesPerGroup,
+		maxAge:              maxAge,
+	}
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return repo, nil
+}
+
+// migrate applies any pending schema migrations
+func (r *SQLiteRepository) migrate() error {
+	var version int
+	if err := r.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(messageMigrations); i++ {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(messageMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+
+		// PRAGMA does not support placeholders
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version %d: %w", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save stores a message and applies retention limits for its group
+func (r *SQLiteRepository) Save(ctx context.Context, message *domain.Message) error {
+	chatJID := chatKey(message)
+	if chatJID == "" {
+		return fmt.Errorf("chat JID is required")
+	}
+
+	query := `
+		INSERT INTO messages (id, group_jid, chat_jid, sender, sender_name, content, timestamp, is_from_bot, is_reply_to_bot)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		message.ID,
+		message.GroupJID,
+		chatJID,
+		message.Sender,
+		message.SenderName,
+		message.Content,
+		message.Timestamp.UTC(), // stored in UTC so timestamps compare correctly as text
+		message.IsFromBot,
+		message.IsReplyToBot,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+
+	return r.applyRetention(ctx, chatJID)
+}
+
+// applyRetention removes messages that exceed the configured limits
+func (r *SQLiteRepository) applyRetention(ctx context.Context, chatJID string) error {
+	if r.maxAge > 0 {
+		cutoff := time.Now().UTC().Add(-r.maxAge)
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM messages WHERE timestamp < ?`, cutoff); err != nil {
+			return fmt.Errorf("failed to prune old messages: %w", err)
+		}
+	}
+
+	if r.maxMessagesPerGroup > 0 {
+		query := `
+			DELETE FROM messages
+			WHERE chat_jid = ? AND seq NOT IN (
+				SELECT seq FROM messages WHERE chat_jid = ?
+				ORDER BY timestamp DESC, seq DESC LIMIT ?
+			)
+		`
+		if _, err := r.db.ExecContext(ctx, query, chatJID, chatJID, r.maxMessagesPerGroup); err != nil {
+			return fmt.Errorf("failed to prune chat messages: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetByChatJID retrieves the last N messages for a specific chat, oldest first
+func (r *SQLiteRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Message, error) {
+	query := `
+		SELECT id, group_jid, chat_jid, sender, sender_name, content, timestamp, is_from_bot, is_reply_to_bot
+		FROM (
+			SELECT seq, id, group_jid, chat_jid, sender, sender_name, content, timestamp, is_from_bot, is_reply_to_bot
+			FROM messages WHERE chat_jid = ?
+			ORDER BY timestamp DESC, seq DESC LIMIT ?
+		)
+		ORDER BY timestamp ASC, seq ASC
+	`
+
+	// A negative LIMIT means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// GetAll retrieves all messages
+func (r *SQLiteRepository) GetAll(ctx context.Context) ([]*domain.Message, error) {
+	query := `
+		SELECT id, group_jid, chat_jid, sender, sender_name, content, timestamp, is_from_bot, is_reply_to_bot
+		FROM messages ORDER BY chat_jid, timestamp, seq
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanMessages(rows)
+}
+
+// scanMessages is a helper to scan multiple message rows
+func (r *SQLiteRepository) scanMessages(rows *sql.Rows) ([]*domain.Message, error) {
+	messages := []*domain.Message{}
+
+	for rows.Next() {
+		msg := &domain.Message{}
+		err := rows.Scan(
+			&msg.ID,
+			&msg.GroupJID,
+			&msg.ChatJID,
+			&msg.Sender,
+			&msg.SenderName,
+			&msg.Content,
+			&msg.Timestamp,
+			&msg.IsFromBot,
+			&msg.IsReplyToBot,
+		)
+		if err != nil {
+			return nil, err
+		}
+		msg.IsDirect = msg.GroupJID == ""
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// Summaries returns a summary repository backed by the same database
+func (r *SQLiteRepository) Summaries() *SQLiteSummaryRepository {
+	return &SQLiteSummaryRepository{db: r.db}
+}
+
+// Documents returns a document repository backed by the same database
+func (r *SQLiteRepository) Documents() *SQLiteDocumentRepository {
+	return &SQLiteDocumentRepository{db: r.db}
+}
+
+// Knowledge returns a knowledge base repository backed by the same database
+func (r *SQLiteRepository) Knowledge() *SQLiteKnowledgeRepository {
+	return &SQLiteKnowledgeRepository{db: r.db}
+}
+
+// Close closes the database connection
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestSQLiteRepository_PersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "messages.db")
+
+	repo, err := NewSQLiteRepository(dbPath, 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+
+	base := time.Now()
+	for i := 0; i < 3; i++ {
+		err := repo.Save(ctx, &domain.Message{
+			ID:        fmt.Sprintf("msg%d", i),
+			GroupJID:  "group1@g.us",
+			Sender:    "user@s.whatsapp.net",
+			Content:   fmt.Sprintf("message %d", i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	repo.Close()
+
+	// Reopen to simulate a restart
+	repo, err = NewSQLiteRepository(dbPath, 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() reopen error = %v", err)
+	}
+	defer repo.Close()
+
+	messages, err := repo.GetByChatJID(ctx, "group1@g.us", 2)
+	if err != nil {
+		t.Fatalf("GetByChatJID() error = %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Content != "message 1" || messages[1].Content != "message 2" {
+		t.Errorf("Expected last two messages oldest first, got %q, %q", messages[0].Content, messages[1].Content)
+	}
+}
+
+func TestSQLiteRepository_Retention(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "messages.db"), 2, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	now := time.Now()
+	saves := []*domain.Message{
+		{ID: "old", GroupJID: "group1@g.us", Content: "too old", Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "a", GroupJID: "group1@g.us", Content: "a", Timestamp: now.Add(-3 * time.Minute)},
+		{ID: "b", GroupJID: "group1@g.us", Content: "b", Timestamp: now.Add(-2 * time.Minute)},
+		{ID: "c", GroupJID: "group1@g.us", Content: "c", Timestamp: now.Add(-1 * time.Minute)},
+		{ID: "other", GroupJID: "group2@g.us", Content: "other group", Timestamp: now},
+	}
+	for _, msg := range saves {
+		if err := repo.Save(ctx, msg); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	messages, err := repo.GetByChatJID(ctx, "group1@g.us", 0)
+	if err != nil {
+		t.Fatalf("GetByChatJID() error = %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 retained messages, got %d", len(messages))
+	}
+	if messages[0].ID != "b" || messages[1].ID != "c" {
+		t.Errorf("Expected messages b, c to be retained, got %s, %s", messages[0].ID, messages[1].ID)
+	}
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected 3 messages across groups, got %d", len(all))
+	}
+}
+
+func TestSQLiteSummaryRepository(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "messages.db"), 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	summaries := repo.Summaries()
+
+	summary, err := summaries.Get(ctx, "group1@g.us")
+	if err != nil || summary != nil {
+		t.Fatalf("Expected no summary, got %+v, %v", summary, err)
+	}
+
+	now := time.Now()
+	for _, content := range []string{"first", "second"} {
+		err := summaries.Save(ctx, &domain.ConversationSummary{
+			GroupJID:     "group1@g.us",
+			Content:      content,
+			CoveredUntil: now,
+			MessageCount: 10,
+			UpdatedAt:    now,
+		})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	summary, err = summaries.Get(ctx, "group1@g.us")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if summary == nil || summary.Content != "second" || !summary.CoveredUntil.Equal(now) {
+		t.Errorf("Expected updated summary, got %+v", summary)
+	}
+
+	if err := summaries.Delete(ctx, "group1@g.us"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if summary, _ := summaries.Get(ctx, "group1@g.us"); summary != nil {
+		t.Errorf("Expected summary to be deleted, got %+v", summary)
+	}
+}
+
+func TestSQLiteRepository_DirectChatHistory(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "messages.db"), 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	messages := []*domain.Message{
+		{ID: "g1", GroupJID: "group1@g.us", Sender: "user@s.whatsapp.net", Content: "in group", Timestamp: time.Now()},
+		{ID: "d1", ChatJID: "user@s.whatsapp.net", IsDirect: true, Sender: "user@s.whatsapp.net", Content: "in private", Timestamp: time.Now()},
+	}
+	for _, msg := range messages {
+		if err := repo.Save(ctx, msg); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	direct, err := repo.GetByChatJID(ctx, "user@s.whatsapp.net", 10)
+	if err != nil {
+		t.Fatalf("GetByChatJID() error = %v", err)
+	}
+	if len(direct) != 1 || direct[0].Content != "in private" || !direct[0].IsDirect {
+		t.Errorf("Expected only the direct message, got %+v", direct)
+	}
+
+	group, err := repo.GetByChatJID(ctx, "group1@g.us", 10)
+	if err != nil {
+		t.Fatalf("GetByChatJID() error = %v", err)
+	}
+	if len(group) != 1 || group[0].ChatJID != "group1@g.us" || group[0].IsDirect {
+		t.Errorf("Expected group message keyed by group JID, got %+v", group)
+	}
+}
+
+func TestSQLiteDocumentRepository(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "messages.db"), 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	documents := repo.Documents()
+
+	base := time.Now().Add(-time.Hour)
+	for i, name := range []string{"menu.pdf", "trip.md"} {
+		err := documents.Save(ctx, &domain.Document{
+			ID:        name,
+			ChatJID:   "group1@g.us",
+			FileName:  name,
+			Sender:    "user@s.whatsapp.net",
+			Chunks:    []string{name + " part 1", name + " part 2"},
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	// Saving again replaces the chunks
+	if err := documents.Save(ctx, &domain.Document{ID: "trip.md", ChatJID: "group1@g.us", FileName: "trip.md", Chunks: []string{"updated"}, CreatedAt: base.Add(time.Minute)}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	document, err := documents.Get(ctx, "group1@g.us", "trip.md")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if document == nil || len(document.Chunks) != 1 || document.Chunks[0] != "updated" {
+		t.Errorf("Expected replaced document, got %+v", document)
+	}
+
+	if document, _ := documents.Get(ctx, "group2@g.us", "trip.md"); document != nil {
+		t.Errorf("Expected documents to be scoped by chat, got %+v", document)
+	}
+
+	latest, err := documents.GetByChatJID(ctx, "group1@g.us", 10)
+	if err != nil {
+		t.Fatalf("GetByChatJID() error = %v", err)
+	}
+	if len(latest) != 2 || latest[0].ID != "trip.md" || latest[1].Chunks[1] != "menu.pdf part 2" {
+		t.Errorf("Expected newest document first with chunks in order, got %+v", latest)
+	}
+}
+
+func TestSQLiteKnowledgeRepository(t *testing.T) {
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "messages.db"), 0, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	knowledge := repo.Knowledge()
+
+	err = knowledge.SaveDocument(ctx,
+		&domain.KnowledgeDocument{ID: "faq", GroupJID: "group1@g.us", Title: "FAQ", CreatedAt: time.Now()},
+		[]*domain.KnowledgeChunk{
+			{Content: "Opening hours", Embedding: []float32{1, 0, 0}},
+			{Content: "Parking", Embedding: []float32{0, 1, 0}},
+			{Content: "Old model vector", Embedding: []float32{1, 0}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("SaveDocument() error = %v", err)
+	}
+	err = knowledge.SaveDocument(ctx,
+		&domain.KnowledgeDocument{ID: "other", GroupJID: "group2@g.us", Title: "Other", CreatedAt: time.Now()},
+		[]*domain.KnowledgeChunk{{Content: "Opening hours elsewhere", Embedding: []float32{1, 0, 0}}},
+	)
+	if err != nil {
+		t.Fatalf("SaveDocument() error = %v", err)
+	}
+
+	chunks, err := knowledge.Search(ctx, "group1@g.us", []float32{0.9, 0.1, 0}, 5)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].Content != "Opening hours" || chunks[0].Title != "FAQ" || chunks[0].Score <= chunks[1].Score {
+		t.Errorf("Expected group chunks ranked by similarity, got %+v", chunks)
+	}
+
+	documents, err := knowledge.ListDocuments(ctx, "group1@g.us")
+	if err != nil {
+		t.Fatalf("ListDocuments() error = %v", err)
+	}
+	if len(documents) != 1 || documents[0].Chunks != 3 {
+		t.Errorf("Expected one document with 3 chunks, got %+v", documents)
+	}
+
+	if err := knowledge.DeleteDocument(ctx, "group2@g.us", "faq"); err == nil {
+		t.Error("Expected documents of other groups not to be deleted")
+	}
+	if err := knowledge.DeleteDocument(ctx, "group1@g.us", "faq"); err != nil {
+		t.Fatalf("DeleteDocument() error = %v", err)
+	}
+	if chunks, _ := knowledge.Search(ctx, "group1@g.us", []float32{1, 0, 0}, 5); len(chunks) != 0 {
+		t.Errorf("Expected chunks to be deleted, got %+v", chunks)
+	}
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MemorySummaryRepository implements SummaryRepository using in-memory storage
+type MemorySummaryRepository struct {
+	summaries map[string]*domain.ConversationSummary // groupJID -> summary
+	mu        sync.RWMutex
+}
+
+// NewMemorySummaryRepository creates a new in-memory summary repository
+func NewMemorySummaryRepository() *MemorySummaryRepository {
+	return &MemorySummaryRepository{
+		summaries: make(map[string]*domain.ConversationSummary),
+	}
+}
+
+// Get retrieves the summary for a group, or nil if there is none
+func (r *MemorySummaryRepository) Get(ctx context.Context, groupJID string) (*domain.ConversationSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	summary, exists := r.summaries[groupJID]
+	if !exists {
+		return nil, nil
+	}
+
+	copied := *summary
+	return &copied, nil
+}
+
+// Save stores or replaces the summary for a group
+func (r *MemorySummaryRepository) Save(ctx context.Context, summary *domain.ConversationSummary) error {
+	if summary.GroupJID == "" {
+		return fmt.Errorf("group JID is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *summary
+	r.summaries[summary.GroupJID] = &copied
+	return nil
+}
+
+// Delete removes the summary for a group
+func (r *MemorySummaryRepository) Delete(ctx context.Context, groupJID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.summaries, groupJID)
+	return nil
+}
+
+// SQLiteSummaryRepository implements SummaryRepository using the message database.
+// Obtain one via SQLiteRepository.Summaries so the schema is migrated.
+type SQLiteSummaryRepository struct {
+	db *sql.DB
+}
+
+// Get retrieves the summary for a group, or nil if there is none
+func (r *SQLiteSummaryRepository) Get(ctx context.Context, groupJID string) (*domain.ConversationSummary, error) {
+	query := `
+		SELECT group_jid, content, covered_until, message_count, updated_at
+		FROM summaries WHERE group_jid = ?
+	`
+
+	summary := &domain.ConversationSummary{}
+	err := r.db.QueryRowContext(ctx, query, groupJID).Scan(
+		&summary.GroupJID,
+		&summary.Content,
+		&summary.CoveredUntil,
+		&summary.MessageCount,
+		&summary.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// Save stores or replaces the summary for a group
+func (r *SQLiteSummaryRepository) Save(ctx context.Context, summary *domain.ConversationSummary) error {
+	if summary.GroupJID == "" {
+		return fmt.Errorf("group JID is required")
+	}
+
+	query := `
+		INSERT INTO summaries (group_jid, content, covered_until, message_count, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(group_jid) DO UPDATE SET
+			content = excluded.content,
+			covered_until = excluded.covered_until,
+			message_count = excluded.message_count,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		summary.GroupJID,
+		summary.Content,
+		summary.CoveredUntil.UTC(),
+		summary.MessageCount,
+		summary.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save summary: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the summary for a group
+func (r *SQLiteSummaryRepository) Delete(ctx context.Context, groupJID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM summaries WHERE group_jid = ?`, groupJID); err != nil {
+		return fmt.Errorf("failed to delete summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// WhisperClient implements Transcriber against a Whisper-compatible HTTP server.
+// It works with the whisper.cpp server (/inference) and with OpenAI-compatible
+// servers such as faster-whisper-server (/v1/audio/transcriptions): both accept
+// a multipart "file" upload and answer with {"text": "..."}.
+type WhisperClient struct {
+	httpClient *http.Client
+	url        string
+	model      string
+	language   string
+}
+
+// whisperResponse is the JSON reply of the transcription endpoint
+type whisperResponse struct {
+	Text  string `json:"text"`
+	Error string `json:"error"`
+}
+
+// NewWhisperClient creates a new Whisper client for the given endpoint URL
+func NewWhisperClient(url, model, language string, timeout time.Duration) *WhisperClient {
+	return &WhisperClient{
+		httpClient: &http.Client{Timeout: timeout},
+		url:        url,
+		model:      model,
+		language:   language,
+	}
+}
+
+// Transcribe uploads the audio and returns the recognized text
+func (c *WhisperClient) Transcribe(ctx context.Context, audio *domain.Media) (string, error) {
+	if audio == nil || len(audio.Data) == 0 {
+		return "", fmt.Errorf("no audio to transcribe")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", audioFileName(audio))
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(audio.Data); err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	fields := map[string]string{
+		"response_format": "json",
+		"model":           c.model,
+		"language":        c.language,
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(name, value); err != nil {
+			return "", fmt.Errorf("failed to write field %s: %w", name, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result whisperResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("transcription failed: %s", result.Error)
+	}
+
+	return strings.TrimSpace(result.Text), nil
+}
+
+// audioFileName picks an upload name whose extension lets the server detect the format
+func audioFileName(audio *domain.Media) string {
+	if audio.FileName != "" {
+		return audio.FileName
+	}
+
+	mimeType := strings.TrimSpace(strings.Split(audio.MimeType, ";")[0])
+	switch mimeType {
+	case "audio/mpeg":
+		return "audio.mp3"
+	case "audio/mp4", "audio/aac":
+		return "audio.m4a"
+	case "audio/wav", "audio/x-wav":
+		return "audio.wav"
+	default:
+		// WhatsApp voice notes are Opus in an Ogg container
+		return "audio.ogg"
+	}
+}
@@ -0,0 +1,74 @@
+package transcription
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestWhisperClient_Transcribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("Missing file: %v", err)
+		}
+		defer file.Close()
+
+		data, _ := io.ReadAll(file)
+		if string(data) != "opus-bytes" {
+			t.Errorf("Unexpected audio data: %q", data)
+		}
+		if header.Filename != "audio.ogg" {
+			t.Errorf("Expected filename audio.ogg, got %s", header.Filename)
+		}
+		if got := r.FormValue("language"); got != "en" {
+			t.Errorf("Expected language en, got %q", got)
+		}
+		if got := r.FormValue("model"); got != "" {
+			t.Errorf("Expected no model field, got %q", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text": " Sasi, what's the weather tomorrow?\n"}`))
+	}))
+	defer server.Close()
+
+	client := NewWhisperClient(server.URL+"/inference", "", "en", 5*time.Second)
+	text, err := client.Transcribe(context.Background(), &domain.Media{
+		Type:     domain.MediaTypeAudio,
+		MimeType: "audio/ogg; codecs=opus",
+		Data:     []byte("opus-bytes"),
+	})
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+
+	if text != "Sasi, what's the weather tomorrow?" {
+		t.Errorf("Unexpected transcript: %q", text)
+	}
+}
+
+func TestWhisperClient_TranscribeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewWhisperClient(server.URL, "whisper-1", "", 5*time.Second)
+	_, err := client.Transcribe(context.Background(), &domain.Media{
+		Type: domain.MediaTypeAudio,
+		Data: []byte("opus-bytes"),
+	})
+	if err == nil {
+		t.Fatal("Expected error for failed request")
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// TimestampHeader carries the Unix time the request was signed at
+	TimestampHeader = domain.WebhookTimestampHeader
+
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+	SignatureHeader = domain.WebhookSignatureHeader
+
+	// maxSignatureAge is how far the timestamp of a signed request may be from now
+	maxSignatureAge = 5 * time.Minute
+)
+
+// authenticate adds the webhook's custom headers, credentials and body signature to a request
+func authenticate(req *http.Request, webhook *domain.WebhookConfig, body []byte, now time.Time) {
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	switch {
+	case webhook.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+webhook.BearerToken)
+	case webhook.BasicUsername != "":
+		req.SetBasicAuth(webhook.BasicUsername, webhook.BasicPassword)
+	}
+
+	if webhook.Secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" with the webhook secret.
+// Receivers recompute it to verify the request and reject stale timestamps to stop replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature made by Sign with the webhook secret and rejects timestamps
+// more than five minutes away from now, so a captured request cannot be replayed later
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if secret == "" {
+		return errors.New("webhook has no secret")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return errors.New("timestamp out of range")
+	}
+
+	expected := "sha256=" + Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// VerifySignature checks that a body posted to the bot was signed with the webhook's secret
+func (c *Client) VerifySignature(webhook *domain.WebhookConfig, timestamp, signature string, body []byte) error {
+	return Verify(webhook.Secret, timestamp, signature, body, time.Now())
+}
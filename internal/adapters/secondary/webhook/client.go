@@ -1,13 +1,13 @@
 package webhook
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
-	"strings"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
@@ -17,17 +17,23 @@ import (
 type Client struct {
 	httpClient *http.Client
 	timeout    time.Duration
+	retry      retryPolicy
+	breakers   *circuitBreakers
+	mu         sync.RWMutex
 }
 
-// WebhookRequest represents the payload sent to webhook
+// WebhookRequest represents the version 1 payload sent to webhook
 type WebhookRequest struct {
-	Message string `json:"message"`
+	Message     string `json:"message"`
+	CallbackID  string `json:"callback_id,omitempty"`
+	CallbackURL string `json:"callback_url,omitempty"`
 }
 
 // WebhookResponse represents the response from webhook
 type WebhookResponse struct {
-	Response string `json:"response"`
-	Output   string `json:"output"` // Support for "output" field
+	Response string        `json:"response"`
+	Output   string        `json:"output"` // Support for "output" field
+	Parts    []WebhookPart `json:"parts"`  // Multi-part responses, sent in order
 }
 
 // NewClient creates a new webhook client
@@ -37,15 +43,32 @@ func NewClient(timeout time.Duration) *Client {
 			// No timeout here - we'll use context timeout instead for per-request control
 			Timeout: 0,
 		},
-		timeout: timeout,
+		timeout:  timeout,
+		retry:    newRetryPolicy(domain.WebhookRetryConfig{}),
+		breakers: newCircuitBreakers(domain.WebhookRetryConfig{}),
 	}
 }
 
-// Call makes an HTTP POST request to the webhook URL with the message
-func (c *Client) Call(ctx context.Context, url string, message string) (*domain.WebhookResponse, error) {
-	// Create request payload
-	payload := WebhookRequest{
-		Message: message,
+// UpdateRetryConfig updates the retry and circuit breaker settings; zero values fall back to defaults
+func (c *Client) UpdateRetryConfig(cfg domain.WebhookRetryConfig) {
+	c.mu.Lock()
+	c.retry = newRetryPolicy(cfg)
+	c.mu.Unlock()
+
+	c.breakers.configure(cfg)
+}
+
+// Call makes an HTTP POST request to the webhook URL with the request payload,
+// signed and authenticated as the webhook is configured
+func (c *Client) Call(ctx context.Context, webhook *domain.WebhookConfig, request *domain.WebhookRequest) (*domain.WebhookResponse, error) {
+	// Version 1 payloads stay {"message": ...} for existing workflows
+	var payload interface{} = WebhookRequest{
+		Message:     request.Message,
+		CallbackID:  request.CallbackID,
+		CallbackURL: request.CallbackURL,
+	}
+	if request.Version >= domain.WebhookPayloadV2 {
+		payload = request
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -53,35 +76,24 @@ func (c *Client) Call(ctx context.Context, url string, message string) (*domain.
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request with context (allows timeout override)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	// Retry server errors and timeouts with backoff
+	body, header, attempts, err := c.postWithRetry(ctx, webhook, jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, &domain.WebhookError{Attempts: attempts, Err: err}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Execute request
-	resp, err := c.httpClient.Do(req)
+	result, err := c.ParseResponse(ctx, header.Get("Content-Type"), header.Get("Content-Disposition"), body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		return nil, err
 	}
+	result.Attempts = attempts
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Get content type from response header
-	contentType := resp.Header.Get("Content-Type")
+	return result, nil
+}
 
+// ParseResponse reads a webhook result: a file, a JSON object with "parts", "output" or
+// "response", or plain text
+func (c *Client) ParseResponse(ctx context.Context, contentType, contentDisposition string, body []byte) (*domain.WebhookResponse, error) {
 	// Determine response type based on Content-Type header
 	result := &domain.WebhookResponse{
 		ContentType: contentType,
@@ -89,16 +101,23 @@ func (c *Client) Call(ctx context.Context, url string, message string) (*domain.
 	}
 
 	// Handle different content types
-	if strings.HasPrefix(contentType, "image/jpeg") || strings.HasPrefix(contentType, "image/jpg") {
-		result.ContentType = "image/jpeg"
-	} else if strings.HasPrefix(contentType, "image/png") {
-		result.ContentType = "image/png"
+	if media := mediaFromResponse(contentType, contentDisposition, body); media != nil {
+		result.ContentType = media.MimeType
+		result.Media = media
 	} else {
 		// Default to text - try to parse as JSON first
 		var webhookResp WebhookResponse
 		if err := json.Unmarshal(body, &webhookResp); err == nil {
-			// Check for "output" field first, then "response" field
-			if webhookResp.Output != "" {
+			// Check for "parts" first, then the "output" and "response" fields
+			if len(webhookResp.Parts) > 0 {
+				parts, err := c.resolveParts(ctx, webhookResp.Parts)
+				if err != nil {
+					return nil, err
+				}
+				result.ContentType = "multipart"
+				result.Parts = parts
+				result.TextContent = partsText(parts)
+			} else if webhookResp.Output != "" {
 				result.ContentType = "text"
 				result.TextContent = webhookResp.Output
 			} else if webhookResp.Response != "" {
@@ -118,3 +137,41 @@ func (c *Client) Call(ctx context.Context, url string, message string) (*domain.
 
 	return result, nil
 }
+
+// mediaFromResponse returns the file carried by a webhook response, or nil if its
+// Content-Type is text or JSON
+func mediaFromResponse(contentType, contentDisposition string, body []byte) *domain.Media {
+	mimeType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	if mimeType == "image/jpg" {
+		mimeType = "image/jpeg"
+	}
+
+	mediaType, ok := domain.MediaTypeOf(mimeType)
+	if !ok {
+		return nil
+	}
+
+	return &domain.Media{
+		Type:     mediaType,
+		MimeType: mimeType,
+		Data:     body,
+		FileName: responseFileName(mimeType, contentDisposition),
+	}
+}
+
+// responseFileName returns the file name from a Content-Disposition header, or a
+// generic name with the extension of the MIME type
+func responseFileName(mimeType, contentDisposition string) string {
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil && params["filename"] != "" {
+		return filepath.Base(params["filename"])
+	}
+
+	extension := ""
+	if extensions, err := mime.ExtensionsByType(mimeType); err == nil && len(extensions) > 0 {
+		extension = extensions[0]
+	}
+	return "file" + extension
+}
@@ -0,0 +1,432 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestClient_Call_ContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		disposition string
+		body        string
+		wantType    domain.MediaType // empty for text
+		wantFile    string
+		wantText    string
+	}{
+		{name: "json output", contentType: "application/json", body: `{"output":"done"}`, wantText: "done"},
+		{name: "plain text", contentType: "text/plain; charset=utf-8", body: "hello", wantText: "hello"},
+		{name: "jpeg alias", contentType: "image/jpg", body: "jpg", wantType: domain.MediaTypeImage},
+		{name: "webp sticker", contentType: "image/webp", body: "webp", wantType: domain.MediaTypeSticker},
+		{name: "gif", contentType: "image/gif", body: "gif", wantType: domain.MediaTypeVideo, wantFile: "file.gif"},
+		{name: "video", contentType: "video/mp4", body: "mp4", wantType: domain.MediaTypeVideo},
+		{name: "voice note", contentType: "audio/ogg", body: "ogg", wantType: domain.MediaTypeAudio},
+		{name: "pdf", contentType: "application/pdf", disposition: `attachment; filename="../report.pdf"`, body: "%PDF", wantType: domain.MediaTypeDocument, wantFile: "report.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.disposition != "" {
+					w.Header().Set("Content-Disposition", tt.disposition)
+				}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			response, err := NewClient(5*time.Second).Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, &domain.WebhookRequest{Message: "hi"})
+			if err != nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+
+			if tt.wantType == "" {
+				if response.Media != nil || response.ContentType != "text" || response.TextContent != tt.wantText {
+					t.Errorf("Expected text %q, got %+v", tt.wantText, response)
+				}
+				return
+			}
+
+			if response.Media == nil || response.Media.Type != tt.wantType || string(response.Media.Data) != tt.body {
+				t.Fatalf("Expected %s media, got %+v", tt.wantType, response.Media)
+			}
+			if tt.wantFile != "" && response.Media.FileName != tt.wantFile {
+				t.Errorf("Expected file name %q, got %q", tt.wantFile, response.Media.FileName)
+			}
+		})
+	}
+}
+
+func TestClient_Call_Parts(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chart.png" {
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "png")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"parts": [
+			{"type": "media", "url": "%s/chart.png", "caption": "Sales"},
+			{"type": "document", "data": "JVBERg==", "mime_type": "application/pdf", "file_name": "sales.pdf"},
+			{"type": "text", "text": "Thanks @491701234567", "mentions": ["+491701234567"]},
+			{"type": "reaction", "emoji": "👍"},
+			{"type": "ask", "prompt": "Explain the chart"}
+		]}`, server.URL)
+	}))
+	defer server.Close()
+
+	response, err := NewClient(5*time.Second).Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, &domain.WebhookRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+
+	if response.ContentType != "multipart" || len(response.Parts) != 5 {
+		t.Fatalf("Expected 5 parts, got %+v", response)
+	}
+
+	chart := response.Parts[0]
+	if chart.Media == nil || chart.Media.Type != domain.MediaTypeImage || string(chart.Media.Data) != "png" || chart.Text != "Sales" {
+		t.Errorf("Unexpected chart part %+v", chart)
+	}
+	pdf := response.Parts[1].Media
+	if pdf == nil || pdf.Type != domain.MediaTypeDocument || string(pdf.Data) != "%PDF" || pdf.FileName != "sales.pdf" {
+		t.Errorf("Unexpected document part %+v", pdf)
+	}
+	if mentions := response.Parts[2].Mentions; len(mentions) != 1 || mentions[0] != "491701234567@s.whatsapp.net" {
+		t.Errorf("Unexpected mentions %v", mentions)
+	}
+	if response.Parts[3].Emoji != "👍" || response.Parts[4].Text != "Explain the chart" {
+		t.Errorf("Unexpected reaction or ask part %+v %+v", response.Parts[3], response.Parts[4])
+	}
+	if response.TextContent != "Thanks @491701234567" {
+		t.Errorf("Expected text parts as text content, got %q", response.TextContent)
+	}
+}
+
+func TestClient_Call_GIFImagePart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"parts": [{"type": "image", "data": "R0lGODlh", "mime_type": "image/gif"}]}`)
+	}))
+	defer server.Close()
+
+	response, err := NewClient(5*time.Second).Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, &domain.WebhookRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+
+	// Sent as a video so the WhatsApp client converts it to an animated MP4
+	gif := response.Parts[0].Media
+	if gif == nil || gif.Type != domain.MediaTypeVideo || gif.MimeType != "image/gif" {
+		t.Errorf("Expected the GIF as video media, got %+v", gif)
+	}
+}
+
+func TestClient_Call_InvalidPart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"parts": [{"type": "image"}]}`)
+	}))
+	defer server.Close()
+
+	if _, err := NewClient(5*time.Second).Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, &domain.WebhookRequest{Message: "hi"}); err == nil {
+		t.Fatal("Expected error for a media part without data or url")
+	}
+}
+
+func TestClient_Call_PayloadVersions(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, `{"output":"ok"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	request := &domain.WebhookRequest{
+		Version:  domain.WebhookPayloadV1,
+		Message:  "hi",
+		Source:   "message",
+		GroupJID: "group@g.us",
+		Sender:   "user@s.whatsapp.net",
+	}
+
+	if _, err := client.Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, request); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if len(body) != 1 || body["message"] != "hi" {
+		t.Errorf("Expected a version 1 payload with only the message, got %v", body)
+	}
+
+	request.Version = domain.WebhookPayloadV2
+	if _, err := client.Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, request); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if body["message"] != "hi" || body["version"] != float64(2) || body["group_jid"] != "group@g.us" || body["sender"] != "user@s.whatsapp.net" {
+		t.Errorf("Expected a version 2 payload with metadata, got %v", body)
+	}
+}
+
+func TestClient_Call_Authentication(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		fmt.Fprint(w, `{"output":"ok"}`)
+	}))
+	defer server.Close()
+
+	webhook := &domain.WebhookConfig{
+		URL:         server.URL,
+		Secret:      "s3cret",
+		BearerToken: "token",
+		Headers:     map[string]string{"X-Api-Key": "key"},
+	}
+	if _, err := NewClient(5*time.Second).Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"}); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+
+	if got := received.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := received.Header.Get("X-Api-Key"); got != "key" {
+		t.Errorf("X-Api-Key = %q", got)
+	}
+
+	timestamp := received.Header.Get(TimestampHeader)
+	if timestamp == "" {
+		t.Fatal("Expected a signature timestamp")
+	}
+	want := "sha256=" + Sign("s3cret", timestamp, body)
+	if got := received.Header.Get(SignatureHeader); got != want {
+		t.Errorf("Signature = %q, want %q", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"output":"done"}`)
+	timestamp := "1700000000"
+	signature := "sha256=" + Sign("s3cret", timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", "s3cret", timestamp, signature, body, false},
+		{"wrong secret", "other", timestamp, signature, body, true},
+		{"tampered body", "s3cret", timestamp, signature, []byte(`{"output":"evil"}`), true},
+		{"replayed later", "s3cret", "1699990000", "sha256=" + Sign("s3cret", "1699990000", body), body, true},
+		{"missing timestamp", "s3cret", "", signature, body, true},
+		{"no secret", "", timestamp, signature, body, true},
+	}
+	for _, tt := range tests {
+		if err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, now); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Verify() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClient_Call_BasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "n8n" || pass != "pw" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Error("Expected no signature without a secret")
+		}
+		fmt.Fprint(w, `{"output":"ok"}`)
+	}))
+	defer server.Close()
+
+	webhook := &domain.WebhookConfig{URL: server.URL, BasicUsername: "n8n", BasicPassword: "pw"}
+	if _, err := NewClient(5*time.Second).Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"}); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+}
+
+func TestClient_Call_Retry(t *testing.T) {
+	calls := 0
+	signatures := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		signatures[r.Header.Get(SignatureHeader)] = true
+		if calls == 1 {
+			http.Error(w, "rebooting", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"output":"ok"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	client.UpdateRetryConfig(domain.WebhookRetryConfig{InitialBackoff: "1ms"})
+
+	webhook := &domain.WebhookConfig{URL: server.URL, Secret: "s3cret"}
+	response, err := client.Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if response.Attempts != 2 || calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d (%d calls)", response.Attempts, calls)
+	}
+	if len(signatures) == 0 || signatures[""] {
+		t.Error("Expected every attempt to be signed")
+	}
+}
+
+func TestClient_Call_RetryAfterTimeout(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Hang past the attempt's timeout
+			<-release
+			return
+		}
+		fmt.Fprint(w, `{"output":"ok"}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(5 * time.Second)
+	client.UpdateRetryConfig(domain.WebhookRetryConfig{InitialBackoff: "1ms"})
+
+	webhook := &domain.WebhookConfig{URL: server.URL, Timeout: "50ms"}
+	response, err := client.Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if response.Attempts != 2 || response.TextContent != "ok" {
+		t.Errorf("Expected ok after 2 attempts, got %q after %d", response.TextContent, response.Attempts)
+	}
+}
+
+func TestRetryPolicy_ForWebhook(t *testing.T) {
+	global := newRetryPolicy(domain.WebhookRetryConfig{})
+	policy := global.forWebhook(&domain.WebhookConfig{MaxAttempts: 20, InitialBackoff: "1m", MaxBackoff: "10m"})
+	if policy.maxAttempts != 20 || policy.initialBackoff != time.Minute || policy.maxBackoff != 10*time.Minute {
+		t.Errorf("Expected the webhook's retry settings, got %+v", policy)
+	}
+
+	// A webhook that only raises the initial backoff never waits less than it
+	policy = global.forWebhook(&domain.WebhookConfig{InitialBackoff: "5m"})
+	if policy.maxAttempts != defaultMaxAttempts || policy.maxBackoff != 5*time.Minute {
+		t.Errorf("Expected defaults with a 5m max backoff, got %+v", policy)
+	}
+}
+
+func TestClient_Call_NoRetryOnClientError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	client.UpdateRetryConfig(domain.WebhookRetryConfig{InitialBackoff: "1ms"})
+
+	_, err := client.Call(context.Background(), &domain.WebhookConfig{URL: server.URL}, &domain.WebhookRequest{Message: "hi"})
+	var webhookErr *domain.WebhookError
+	if !errors.As(err, &webhookErr) || webhookErr.Attempts != 1 {
+		t.Fatalf("Expected a WebhookError after 1 attempt, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retries for a 400, got %d calls", calls)
+	}
+}
+
+func TestClient_Call_CircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	client.UpdateRetryConfig(domain.WebhookRetryConfig{
+		MaxAttempts:      2,
+		InitialBackoff:   "1ms",
+		FailureThreshold: 2,
+		OpenDuration:     "1h",
+	})
+
+	webhook := &domain.WebhookConfig{URL: server.URL}
+	if _, err := client.Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"}); err == nil {
+		t.Fatal("Expected an error from a failing endpoint")
+	}
+	if calls != 2 {
+		t.Fatalf("Expected 2 calls before the circuit opened, got %d", calls)
+	}
+
+	_, err := client.Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"})
+	if !errors.Is(err, domain.ErrWebhookCircuitOpen) {
+		t.Fatalf("Expected ErrWebhookCircuitOpen, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the open circuit to fail fast, got %d calls", calls)
+	}
+
+	// The circuit lets a trial call through once open_duration has passed
+	client.breakers.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	client.Call(context.Background(), webhook, &domain.WebhookRequest{Message: "hi"})
+	if calls != 3 {
+		t.Errorf("Expected one trial call after the circuit expired, got %d calls", calls-2)
+	}
+}
+
+func TestClient_Call_CallerCancellationKeepsCircuitClosed(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(5 * time.Second)
+	client.UpdateRetryConfig(domain.WebhookRetryConfig{
+		MaxAttempts:      1,
+		FailureThreshold: 2,
+		OpenDuration:     "1h",
+	})
+
+	webhook := &domain.WebhookConfig{URL: server.URL}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := client.Call(ctx, webhook, &domain.WebhookRequest{Message: "hi"})
+		cancel()
+		if errors.Is(err, domain.ErrWebhookCircuitOpen) {
+			t.Fatalf("Call %d: caller timeouts must not open the circuit", i+1)
+		}
+		if err == nil {
+			t.Fatalf("Call %d: expected an error after the caller timed out", i+1)
+		}
+	}
+}
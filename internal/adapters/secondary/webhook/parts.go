@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// maxPartMediaSize caps the size of media downloaded for a response part
+const maxPartMediaSize = 64 << 20
+
+// WebhookPart is one message of a multi-part webhook response, e.g.
+//
+//	{"parts": [
+//	  {"type": "image", "url": "https://example.com/chart.png", "caption": "Sales this week"},
+//	  {"type": "text", "text": "Up 12% thanks to @491701234567", "mentions": ["491701234567"]},
+//	  {"type": "reaction", "emoji": "📈"},
+//	  {"type": "ask", "prompt": "Suggest one way to keep the trend going"}
+//	]}
+//
+// Media parts ("image", "video", "audio", "document", "sticker" or "media" to pick the type
+// from the MIME type) carry either base64 "data" with a "mime_type" or a "url" to download.
+type WebhookPart struct {
+	Type     string   `json:"type"`
+	Text     string   `json:"text,omitempty"`
+	Mentions []string `json:"mentions,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Data     string   `json:"data,omitempty"`
+	MimeType string   `json:"mime_type,omitempty"`
+	FileName string   `json:"file_name,omitempty"`
+	Caption  string   `json:"caption,omitempty"`
+	Emoji    string   `json:"emoji,omitempty"`
+	Prompt   string   `json:"prompt,omitempty"`
+}
+
+// resolveParts converts the parts of a response, downloading or decoding their media
+func (c *Client) resolveParts(ctx context.Context, parts []WebhookPart) ([]domain.WebhookResponsePart, error) {
+	resolved := make([]domain.WebhookResponsePart, 0, len(parts))
+	for i, part := range parts {
+		switch part.Type {
+		case "text", "":
+			resolved = append(resolved, domain.WebhookResponsePart{
+				Type:     domain.WebhookPartText,
+				Text:     part.Text,
+				Mentions: domain.MentionJIDs(part.Mentions),
+			})
+		case "reaction":
+			resolved = append(resolved, domain.WebhookResponsePart{Type: domain.WebhookPartReaction, Emoji: part.Emoji})
+		case "ask":
+			resolved = append(resolved, domain.WebhookResponsePart{Type: domain.WebhookPartAsk, Text: part.Prompt})
+		case "media", "image", "video", "audio", "document", "sticker":
+			media, err := c.partMedia(ctx, part)
+			if err != nil {
+				return nil, fmt.Errorf("part %d: %w", i+1, err)
+			}
+			resolved = append(resolved, domain.WebhookResponsePart{Type: domain.WebhookPartMedia, Text: part.Caption, Media: media})
+		default:
+			return nil, fmt.Errorf("part %d: unknown type %q", i+1, part.Type)
+		}
+	}
+	return resolved, nil
+}
+
+// partMedia returns the media of a part from its base64 data or URL
+func (c *Client) partMedia(ctx context.Context, part WebhookPart) (*domain.Media, error) {
+	var data []byte
+	mimeType := part.MimeType
+	switch {
+	case part.Data != "":
+		decoded, err := base64.StdEncoding.DecodeString(part.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		data = decoded
+	case part.URL != "":
+		downloaded, contentType, err := c.download(ctx, part.URL)
+		if err != nil {
+			return nil, err
+		}
+		data = downloaded
+		if mimeType == "" {
+			mimeType = contentType
+		}
+	default:
+		return nil, fmt.Errorf("%s part needs data or a url", part.Type)
+	}
+
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	media := mediaFromResponse(mimeType, "", data)
+	if media == nil {
+		return nil, fmt.Errorf("unsupported media type %q", mimeType)
+	}
+	if part.FileName != "" {
+		media.FileName = part.FileName
+	}
+	// A GIF sent as an image keeps the video type of its MIME type, so it is converted and animates
+	if part.Type != "media" && !(part.Type == "image" && media.MimeType == "image/gif") {
+		media.Type = domain.MediaType(part.Type)
+	}
+	return media, nil
+}
+
+// download fetches the media referenced by a part and returns it with its Content-Type
+func (c *Client) download(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create media request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("media download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPartMediaSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read media: %w", err)
+	}
+	if len(data) > maxPartMediaSize {
+		return nil, "", fmt.Errorf("media exceeds %d MB", maxPartMediaSize>>20)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// partsText joins the text of a multi-part response, for callers that only handle text
+func partsText(parts []domain.WebhookResponsePart) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Type == domain.WebhookPartText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
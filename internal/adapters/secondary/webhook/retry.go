@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	defaultMaxAttempts      = 3
+	defaultInitialBackoff   = time.Second
+	defaultMaxBackoff       = 30 * time.Second
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = time.Minute
+)
+
+// retryPolicy is how often and how patiently a failed call is retried
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// newRetryPolicy creates a retry policy from config, using defaults for zero or invalid values
+func newRetryPolicy(cfg domain.WebhookRetryConfig) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: parseDuration(cfg.InitialBackoff, defaultInitialBackoff),
+		maxBackoff:     parseDuration(cfg.MaxBackoff, defaultMaxBackoff),
+	}
+	if policy.maxAttempts <= 0 {
+		policy.maxAttempts = defaultMaxAttempts
+	}
+	return policy
+}
+
+// forWebhook applies a webhook's own attempt count and backoff over the global policy
+func (p retryPolicy) forWebhook(webhook *domain.WebhookConfig) retryPolicy {
+	if webhook.MaxAttempts > 0 {
+		p.maxAttempts = webhook.MaxAttempts
+	}
+	p.initialBackoff = parseDuration(webhook.InitialBackoff, p.initialBackoff)
+	p.maxBackoff = parseDuration(webhook.MaxBackoff, p.maxBackoff)
+	if p.maxBackoff < p.initialBackoff {
+		p.maxBackoff = p.initialBackoff
+	}
+	return p
+}
+
+// statusError is returned for a webhook response other than 200 OK
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.code)
+}
+
+// postWithRetry posts the body to the webhook, retrying server errors and timeouts with
+// exponential backoff. Each attempt gets the webhook's timeout; ctx bounds all attempts
+// together. It returns the response body and headers and the number of attempts.
+func (c *Client) postWithRetry(ctx context.Context, webhook *domain.WebhookConfig, body []byte) ([]byte, http.Header, int, error) {
+	c.mu.RLock()
+	policy := c.retry.forWebhook(webhook)
+	c.mu.RUnlock()
+
+	timeout := parseDuration(webhook.Timeout, c.timeout)
+
+	// Fail fast while the endpoint is known to be down
+	if !c.breakers.allow(webhook.URL) {
+		return nil, nil, 0, domain.ErrWebhookCircuitOpen
+	}
+
+	backoff := policy.initialBackoff
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		respBody, header, err := c.post(attemptCtx, webhook, body)
+		cancel()
+		if err == nil {
+			c.breakers.record(webhook.URL, true)
+			return respBody, header, attempt, nil
+		}
+
+		if !retryable(ctx, err) {
+			// A call the caller gave up on says nothing about the endpoint
+			if ctx.Err() != nil {
+				return nil, nil, attempt, err
+			}
+			// The endpoint answered, so it is up even though it rejected the call
+			var statusErr *statusError
+			c.breakers.record(webhook.URL, errors.As(err, &statusErr))
+			return nil, nil, attempt, err
+		}
+
+		c.breakers.record(webhook.URL, false)
+		if attempt >= policy.maxAttempts || !c.breakers.allow(webhook.URL) {
+			return nil, nil, attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, attempt, err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > policy.maxBackoff {
+			backoff = policy.maxBackoff
+		}
+	}
+}
+
+// post makes a single signed POST request and returns the body of a 200 OK response
+func (c *Client) post(ctx context.Context, webhook *domain.WebhookConfig, body []byte) ([]byte, http.Header, error) {
+	// Create HTTP request with context (allows timeout override)
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	authenticate(req, webhook, body, time.Now())
+
+	// Execute request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &statusError{code: resp.StatusCode}
+	}
+
+	// Read response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// retryable reports whether a failed call may succeed if retried: server errors, rate limits,
+// timeouts of a single attempt and connection errors, as long as the caller is still waiting
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500 || statusErr.code == http.StatusTooManyRequests || statusErr.code == http.StatusRequestTimeout
+	}
+	return true
+}
+
+// circuitBreakers tracks consecutive failures per webhook URL. After failureThreshold failures
+// a URL's circuit opens and calls fail fast for openDuration; then one trial call is let through,
+// which closes the circuit on success or reopens it on failure.
+type circuitBreakers struct {
+	failureThreshold int
+	openDuration     time.Duration
+	circuits         map[string]*circuit
+	now              func() time.Time
+	mu               sync.Mutex
+}
+
+// circuit is the state of one URL's circuit breaker
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// newCircuitBreakers creates circuit breakers from config, using defaults for zero or invalid values
+func newCircuitBreakers(cfg domain.WebhookRetryConfig) *circuitBreakers {
+	b := &circuitBreakers{
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+	b.configure(cfg)
+	return b
+}
+
+// configure updates the thresholds, keeping the state of existing circuits
+func (b *circuitBreakers) configure(cfg domain.WebhookRetryConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failureThreshold = cfg.FailureThreshold
+	if b.failureThreshold <= 0 {
+		b.failureThreshold = defaultFailureThreshold
+	}
+	b.openDuration = parseDuration(cfg.OpenDuration, defaultOpenDuration)
+}
+
+// allow reports whether a call to the URL may be made
+func (b *circuitBreakers) allow(url string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[url]
+	if !ok || c.failures < b.failureThreshold {
+		return true
+	}
+	if b.now().Before(c.openUntil) {
+		return false
+	}
+
+	// Half-open: let this call through as a trial and fail fast for others until it is done
+	c.openUntil = b.now().Add(b.openDuration)
+	return true
+}
+
+// record counts a failed call or resets the URL's circuit after a successful one
+func (b *circuitBreakers) record(url string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		delete(b.circuits, url)
+		return
+	}
+
+	c, ok := b.circuits[url]
+	if !ok {
+		c = &circuit{}
+		b.circuits[url] = c
+	}
+	c.failures++
+	if c.failures >= b.failureThreshold {
+		c.openUntil = b.now().Add(b.openDuration)
+	}
+}
+
+// parseDuration parses a config duration, returning fallback if it is empty or invalid
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+		return parsed
+	}
+	return fallback
+}
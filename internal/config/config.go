@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
 	"sync"
@@ -70,7 +72,7 @@ func (s *FileConfigStore) Save(config *domain.Config) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	data, err := yaml.Marshal(config)
+	data, err := yaml.Marshal(s.withoutEnvOverrides(config))
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -206,35 +208,78 @@ func (s *FileConfigStore) Close() error {
 	return nil
 }
 
+// envOverrides maps environment variables to the config fields they override; each
+// field is returned as a pointer to a string, int or float64
+var envOverrides = []struct {
+	name  string
+	field func(config *domain.Config) interface{}
+}{
+	{"APP_PORT", func(c *domain.Config) interface{} { return &c.App.Port }},
+	{"APP_LOG_LEVEL", func(c *domain.Config) interface{} { return &c.App.LogLevel }},
+	{"WHATSAPP_SESSION_PATH", func(c *domain.Config) interface{} { return &c.WhatsApp.SessionPath }},
+	{"STORAGE_TYPE", func(c *domain.Config) interface{} { return &c.Storage.Type }},
+	{"STORAGE_PATH", func(c *domain.Config) interface{} { return &c.Storage.Path }},
+	{"LLM_PROVIDER", func(c *domain.Config) interface{} { return &c.LLM.Provider }},
+	{"OPENAI_BASE_URL", func(c *domain.Config) interface{} { return &c.LLM.OpenAI.BaseURL }},
+	{"OPENAI_API_KEY", func(c *domain.Config) interface{} { return &c.LLM.OpenAI.APIKey }},
+	{"OPENAI_MODEL", func(c *domain.Config) interface{} { return &c.LLM.OpenAI.Model }},
+	{"OLLAMA_URL", func(c *domain.Config) interface{} { return &c.Ollama.URL }},
+	{"OLLAMA_MODEL", func(c *domain.Config) interface{} { return &c.Ollama.Model }},
+	{"OLLAMA_TEMPERATURE", func(c *domain.Config) interface{} { return &c.Ollama.Temperature }},
+	{"TRANSCRIPTION_URL", func(c *domain.Config) interface{} { return &c.Transcription.URL }},
+	{"TTS_URL", func(c *domain.Config) interface{} { return &c.Speech.URL }},
+}
+
 // applyEnvOverrides applies environment variable overrides
 func (s *FileConfigStore) applyEnvOverrides(config *domain.Config) {
-	if val := os.Getenv("APP_PORT"); val != "" {
-		if port, err := strconv.Atoi(val); err == nil {
-			config.App.Port = port
+	for _, override := range envOverrides {
+		val := os.Getenv(override.name)
+		if val == "" {
+			continue
 		}
-	}
 
-	if val := os.Getenv("APP_LOG_LEVEL"); val != "" {
-		config.App.LogLevel = val
-	}
-
-	if val := os.Getenv("WHATSAPP_SESSION_PATH"); val != "" {
-		config.WhatsApp.SessionPath = val
+		switch field := override.field(config).(type) {
+		case *string:
+			*field = val
+		case *int:
+			if parsed, err := strconv.Atoi(val); err == nil {
+				*field = parsed
+			}
+		case *float64:
+			if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+				*field = parsed
+			}
+		}
 	}
+}
 
-	if val := os.Getenv("OLLAMA_URL"); val != "" {
-		config.Ollama.URL = val
+// withoutEnvOverrides returns a copy of config to persist: fields overridden by the
+// environment keep their value from the file, so secrets such as OPENAI_API_KEY are
+// never written to disk
+func (s *FileConfigStore) withoutEnvOverrides(config *domain.Config) *domain.Config {
+	var file domain.Config
+	if data, err := os.ReadFile(s.filePath); err == nil {
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			s.logger.Warn("Failed to parse config file, dropping overridden values", "error", err)
+		}
 	}
 
-	if val := os.Getenv("OLLAMA_MODEL"); val != "" {
-		config.Ollama.Model = val
-	}
+	persisted := *config
+	for _, override := range envOverrides {
+		if os.Getenv(override.name) == "" {
+			continue
+		}
 
-	if val := os.Getenv("OLLAMA_TEMPERATURE"); val != "" {
-		if temp, err := strconv.ParseFloat(val, 64); err == nil {
-			config.Ollama.Temperature = temp
+		switch field := override.field(&persisted).(type) {
+		case *string:
+			*field = *override.field(&file).(*string)
+		case *int:
+			*field = *override.field(&file).(*int)
+		case *float64:
+			*field = *override.field(&file).(*float64)
 		}
 	}
+	return &persisted
 }
 
 // validate validates configuration values
@@ -247,17 +292,247 @@ func (s *FileConfigStore) validate(config *domain.Config) error {
 		return fmt.Errorf("log level cannot be empty")
 	}
 
-	if config.Ollama.URL == "" {
-		return fmt.Errorf("ollama URL cannot be empty")
+	switch config.LLM.Provider {
+	case "", "ollama":
+		if config.Ollama.URL == "" {
+			return fmt.Errorf("ollama URL cannot be empty")
+		}
+
+		if config.Ollama.Model == "" {
+			return fmt.Errorf("ollama model cannot be empty")
+		}
+
+		if config.Ollama.Temperature < 0 || config.Ollama.Temperature > 2 {
+			return fmt.Errorf("invalid temperature: %f (must be between 0 and 2)", config.Ollama.Temperature)
+		}
+	case "openai":
+		if config.LLM.OpenAI.BaseURL == "" {
+			return fmt.Errorf("openai base URL cannot be empty")
+		}
+
+		if config.LLM.OpenAI.Model == "" {
+			return fmt.Errorf("openai model cannot be empty")
+		}
+
+		if config.LLM.OpenAI.Temperature < 0 || config.LLM.OpenAI.Temperature > 2 {
+			return fmt.Errorf("invalid temperature: %f (must be between 0 and 2)", config.LLM.OpenAI.Temperature)
+		}
+	default:
+		return fmt.Errorf("invalid llm provider: %s (must be ollama or openai)", config.LLM.Provider)
+	}
+
+	if config.Ollama.ContextTokens < 0 || config.Ollama.MaxMessageTokens < 0 {
+		return fmt.Errorf("context_tokens and max_message_tokens cannot be negative")
+	}
+
+	for jid, persona := range config.WhatsApp.GroupPersonas {
+		if persona.Temperature != nil && (*persona.Temperature < 0 || *persona.Temperature > 2) {
+			return fmt.Errorf("invalid temperature for group %s: %f (must be between 0 and 2)", jid, *persona.Temperature)
+		}
+		if persona.ContextLength < 0 {
+			return fmt.Errorf("invalid context length for group %s: %d", jid, persona.ContextLength)
+		}
+	}
+
+	switch config.Storage.Type {
+	case "", "memory", "sqlite":
+	default:
+		return fmt.Errorf("invalid storage type: %s (must be memory or sqlite)", config.Storage.Type)
+	}
+
+	if config.Storage.MaxAge != "" {
+		if _, err := time.ParseDuration(config.Storage.MaxAge); err != nil {
+			return fmt.Errorf("invalid storage max_age: %w", err)
+		}
+	}
+
+	if config.Summary.Interval != "" {
+		if _, err := time.ParseDuration(config.Summary.Interval); err != nil {
+			return fmt.Errorf("invalid summary interval: %w", err)
+		}
+	}
+
+	if config.Summary.KeepRecent < 0 || config.Summary.MinMessages < 0 {
+		return fmt.Errorf("summary keep_recent and min_messages cannot be negative")
+	}
+
+	if config.Tools.MaxIterations < 0 {
+		return fmt.Errorf("tools max_iterations cannot be negative")
+	}
+
+	if config.Transcription.Enabled && config.Transcription.URL == "" {
+		return fmt.Errorf("transcription url is required when transcription is enabled")
+	}
+
+	if config.Transcription.Timeout != "" {
+		if _, err := time.ParseDuration(config.Transcription.Timeout); err != nil {
+			return fmt.Errorf("invalid transcription timeout: %w", err)
+		}
+	}
+
+	if config.Speech.Enabled && config.Speech.URL == "" {
+		return fmt.Errorf("speech url is required when speech is enabled")
+	}
+
+	if config.Speech.Timeout != "" {
+		if _, err := time.ParseDuration(config.Speech.Timeout); err != nil {
+			return fmt.Errorf("invalid speech timeout: %w", err)
+		}
+	}
+
+	if config.Documents.ChunkTokens < 0 || config.Documents.ContextTokens < 0 {
+		return fmt.Errorf("documents chunk_tokens and context_tokens cannot be negative")
+	}
+
+	if config.Knowledge.Enabled && config.Knowledge.EmbeddingModel == "" {
+		return fmt.Errorf("knowledge embedding_model is required when the knowledge base is enabled")
+	}
+
+	if minScore := config.Knowledge.MinScore; minScore != nil && (*minScore < 0 || *minScore > 1) {
+		return fmt.Errorf("knowledge min_score must be between 0 and 1")
+	}
+
+	if config.Knowledge.Timeout != "" {
+		if _, err := time.ParseDuration(config.Knowledge.Timeout); err != nil {
+			return fmt.Errorf("invalid knowledge timeout: %w", err)
+		}
+	}
+
+	if config.WebhookRetry.MaxAttempts < 0 || config.WebhookRetry.FailureThreshold < 0 {
+		return fmt.Errorf("webhook_retry max_attempts and failure_threshold cannot be negative")
 	}
 
-	if config.Ollama.Model == "" {
-		return fmt.Errorf("ollama model cannot be empty")
+	for name, value := range map[string]string{
+		"initial_backoff": config.WebhookRetry.InitialBackoff,
+		"max_backoff":     config.WebhookRetry.MaxBackoff,
+		"open_duration":   config.WebhookRetry.OpenDuration,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid webhook_retry %s: %w", name, err)
+		}
 	}
 
-	if config.Ollama.Temperature < 0 || config.Ollama.Temperature > 2 {
-		return fmt.Errorf("invalid temperature: %f (must be between 0 and 2)", config.Ollama.Temperature)
+	if config.LLM.Queue.Concurrency < 0 || config.LLM.Queue.MaxQueued < 0 {
+		return fmt.Errorf("llm queue concurrency and max_queued cannot be negative")
+	}
+
+	if config.LLM.Queue.NotifyAfter != "" {
+		if _, err := time.ParseDuration(config.LLM.Queue.NotifyAfter); err != nil {
+			return fmt.Errorf("invalid llm queue notify_after: %w", err)
+		}
+	}
+
+	if limits := config.RateLimit; limits.SenderLimit < 0 || limits.SenderBurst < 0 || limits.GroupLimit < 0 ||
+		limits.GroupBurst < 0 || limits.SenderDailyQuota < 0 || limits.GroupDailyQuota < 0 {
+		return fmt.Errorf("rate_limit limits, bursts and quotas cannot be negative")
+	}
+
+	if config.RateLimit.Period != "" {
+		if period, err := time.ParseDuration(config.RateLimit.Period); err != nil || period <= 0 {
+			return fmt.Errorf("invalid rate_limit period %q", config.RateLimit.Period)
+		}
+	}
+
+	seenTokens := make(map[string]bool)
+	for _, token := range config.API.Tokens {
+		if token.Name == "" || token.Token == "" {
+			return fmt.Errorf("api tokens need a name and a token")
+		}
+		if seenTokens[token.Token] {
+			return fmt.Errorf("api token %s: token is used more than once", token.Name)
+		}
+		seenTokens[token.Token] = true
+		if !validRole(token.Role) {
+			return fmt.Errorf("api token %s: unknown role %q", token.Name, token.Role)
+		}
+	}
+
+	seenUsers := make(map[string]bool)
+	for _, user := range config.API.Users {
+		if user.Name == "" || (user.Password == "" && user.PasswordHash == "") {
+			return fmt.Errorf("api users need a name and a password or password_hash")
+		}
+		if seenUsers[user.Name] {
+			return fmt.Errorf("api user %s is configured more than once", user.Name)
+		}
+		seenUsers[user.Name] = true
+		if !validRole(user.Role) {
+			return fmt.Errorf("api user %s: unknown role %q", user.Name, user.Role)
+		}
+	}
+
+	if oidc := config.API.OIDC; oidc.Issuer != "" {
+		if _, err := url.ParseRequestURI(oidc.Issuer); err != nil {
+			return fmt.Errorf("invalid api oidc issuer: %w", err)
+		}
+		// Without an audience, tokens the provider issued to any other client would be accepted
+		if oidc.Audience == "" {
+			return fmt.Errorf("api oidc audience is required when an issuer is set")
+		}
+		if !validRole(oidc.DefaultRole) {
+			return fmt.Errorf("api oidc: unknown default_role %q", oidc.DefaultRole)
+		}
+	}
+
+	for _, proxy := range config.API.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return fmt.Errorf("api trusted_proxies: %q is not an IP or CIDR", proxy)
+		}
+	}
+
+	for _, webhook := range config.Webhooks {
+		if webhook.Async && config.App.PublicURL == "" {
+			return fmt.Errorf("webhook %s: async webhooks require app.public_url", webhook.SubTrigger)
+		}
+		if webhook.Async && webhook.Secret == "" {
+			return fmt.Errorf("webhook %s: async webhooks require a secret to sign their results", webhook.SubTrigger)
+		}
+		if webhook.CallbackTimeout != "" {
+			if _, err := time.ParseDuration(webhook.CallbackTimeout); err != nil {
+				return fmt.Errorf("webhook %s: invalid callback_timeout: %w", webhook.SubTrigger, err)
+			}
+		}
+		if webhook.MaxAttempts < 0 {
+			return fmt.Errorf("webhook %s: max_attempts cannot be negative", webhook.SubTrigger)
+		}
+		for name, value := range map[string]string{
+			"timeout":         webhook.Timeout,
+			"initial_backoff": webhook.InitialBackoff,
+			"max_backoff":     webhook.MaxBackoff,
+		} {
+			if value == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("webhook %s: invalid %s: %w", webhook.SubTrigger, name, err)
+			}
+		}
+		if webhook.PayloadVersion < 0 || webhook.PayloadVersion > domain.WebhookPayloadV2 {
+			return fmt.Errorf("webhook %s: unsupported payload_version %d", webhook.SubTrigger, webhook.PayloadVersion)
+		}
+		if webhook.ContextMessages < 0 {
+			return fmt.Errorf("webhook %s: context_messages cannot be negative", webhook.SubTrigger)
+		}
+		if webhook.BearerToken != "" && webhook.BasicUsername != "" {
+			return fmt.Errorf("webhook %s: use either bearer_token or basic auth, not both", webhook.SubTrigger)
+		}
 	}
 
 	return nil
 }
+
+// validRole reports whether role is empty (the default) or a known API role
+func validRole(role string) bool {
+	switch role {
+	case "", domain.RoleViewer, domain.RoleOperator, domain.RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
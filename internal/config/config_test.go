@@ -0,0 +1,79 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const testConfig = `app:
+    port: 8080
+    log_level: info
+whatsapp:
+    session_path: /data/session
+llm:
+    provider: openai
+    openai:
+        base_url: http://localhost:8000/v1
+        model: test
+ollama:
+    url: http://localhost:11434
+    model: test
+storage:
+    type: memory
+`
+
+func TestFileConfigStore_SaveKeepsEnvOverridesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	t.Setenv("APP_PORT", "9090")
+
+	store := NewFileConfigStore(path, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	cfg, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.LLM.OpenAI.APIKey != "sk-secret" || cfg.App.Port != 9090 {
+		t.Fatalf("Expected env overrides to apply, got %+v %+v", cfg.LLM.OpenAI, cfg.App)
+	}
+
+	// An admin change saves the loaded config
+	cfg.Webhooks = append(cfg.Webhooks, domain.WebhookConfig{SubTrigger: "#news", URL: "http://localhost:5678/news"})
+	if err := store.Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret") || strings.Contains(string(data), "9090") {
+		t.Errorf("Expected env overrides not to be written, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "#news") || !strings.Contains(string(data), "port: 8080") {
+		t.Errorf("Expected the change and the file's own values to be saved, got:\n%s", data)
+	}
+	if cfg.LLM.OpenAI.APIKey != "sk-secret" {
+		t.Error("Expected the loaded config to keep its overrides")
+	}
+}
+
+func TestFileConfigStore_ValidateOIDCAudience(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	oidc := "api:\n    oidc:\n        issuer: http://localhost:5556/dex\n"
+	if err := os.WriteFile(path, []byte(testConfig+oidc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewFileConfigStore(path, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	if _, err := store.Load(); err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Errorf("Expected an OIDC issuer without audience to be rejected, got %v", err)
+	}
+}
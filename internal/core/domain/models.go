@@ -1,16 +1,80 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Message represents a chat message
 type Message struct {
 	ID           string
-	GroupJID     string
+	GroupJID     string // empty for direct messages
+	ChatJID      string // conversation the message belongs to: the group JID, or the contact's JID in a direct chat
+	IsDirect     bool   // true for 1:1 chats
 	Sender       string
+	SenderName   string // WhatsApp display (push) name of the sender, if known
 	Content      string
 	Timestamp    time.Time
 	IsFromBot    bool
-	IsReplyToBot bool // true if this is a reply to bot's message
+	IsReplyToBot bool   // true if this is a reply to bot's message
+	Media        *Media // attachment sent with the message (or quoted by it), if any
+
+	// Set when the message is a reply
+	QuotedMessageID string
+	QuotedSender    string
+	QuotedContent   string // text or caption of the quoted message
+
+	// LoadMedia downloads a photo sent with the message and LoadQuotedMedia the attachment of
+	// the quoted message, if it has one the bot can read. Both are only fetched for messages
+	// the bot answers, and may be nil.
+	LoadMedia       func(ctx context.Context) (*Media, error)
+	LoadQuotedMedia func(ctx context.Context) (*Media, error)
+
+	GroupName string // subject of the group, if known
+}
+
+// MediaType identifies the kind of attachment
+type MediaType string
+
+const (
+	MediaTypeImage    MediaType = "image"
+	MediaTypeAudio    MediaType = "audio"
+	MediaTypeDocument MediaType = "document"
+	MediaTypeVideo    MediaType = "video"
+	MediaTypeSticker  MediaType = "sticker"
+)
+
+// Media is a downloaded message attachment. Media is not persisted with message history.
+type Media struct {
+	Type      MediaType
+	MimeType  string
+	Data      []byte
+	FileName  string
+	MessageID string // ID of the message that carried the attachment, which differs for quoted media
+}
+
+// MediaTypeOf returns the WhatsApp media type a MIME type is sent as, or false for text and JSON
+func MediaTypeOf(mimeType string) (MediaType, bool) {
+	switch {
+	case mimeType == "image/webp":
+		return MediaTypeSticker, true
+	case mimeType == "image/gif":
+		// Sent as a video, converted to MP4 with GIF playback, which WhatsApp animates
+		return MediaTypeVideo, true
+	case strings.HasPrefix(mimeType, "image/"):
+		return MediaTypeImage, true
+	case strings.HasPrefix(mimeType, "video/"):
+		return MediaTypeVideo, true
+	case strings.HasPrefix(mimeType, "audio/"):
+		return MediaTypeAudio, true
+	case strings.HasPrefix(mimeType, "application/") && mimeType != "application/json":
+		return MediaTypeDocument, true
+	default:
+		return "", false
+	}
 }
 
 // Group represents a WhatsApp group
@@ -29,11 +93,21 @@ type GroupParticipant struct {
 
 // Config represents application configuration
 type Config struct {
-	App      AppConfig      `yaml:"app"`
-	WhatsApp WhatsAppConfig `yaml:"whatsapp"`
-	Ollama   OllamaConfig   `yaml:"ollama"`
-	Storage  StorageConfig  `yaml:"storage"`
-	Webhooks []WebhookConfig `yaml:"webhooks"`
+	App           AppConfig           `yaml:"app"`
+	WhatsApp      WhatsAppConfig      `yaml:"whatsapp"`
+	LLM           LLMConfig           `yaml:"llm"`
+	Ollama        OllamaConfig        `yaml:"ollama"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Summary       SummaryConfig       `yaml:"summary"`
+	Tools         ToolsConfig         `yaml:"tools"`
+	Transcription TranscriptionConfig `yaml:"transcription"`
+	Speech        SpeechConfig        `yaml:"speech"`
+	Documents     DocumentsConfig     `yaml:"documents"`
+	Knowledge     KnowledgeConfig     `yaml:"knowledge"`
+	WebhookRetry  WebhookRetryConfig  `yaml:"webhook_retry"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	API           APIConfig           `yaml:"api"`
+	Webhooks      []WebhookConfig     `yaml:"webhooks"`
 }
 
 // AppConfig contains application-level settings
@@ -41,6 +115,9 @@ type AppConfig struct {
 	Name     string `yaml:"name"`
 	Port     int    `yaml:"port"`
 	LogLevel string `yaml:"log_level"`
+
+	// PublicURL is where webhooks reach the bot's API, e.g. "http://192.168.1.50:8080"; required by async webhooks
+	PublicURL string `yaml:"public_url,omitempty"`
 }
 
 // WhatsAppConfig contains WhatsApp-specific settings
@@ -48,6 +125,28 @@ type WhatsAppConfig struct {
 	SessionPath   string   `yaml:"session_path"`
 	AllowedGroups []string `yaml:"allowed_groups"`
 	TriggerWords  []string `yaml:"trigger_words"`
+
+	// GroupPersonas customizes the bot per group, keyed by group JID
+	GroupPersonas map[string]GroupPersona `yaml:"group_personas,omitempty"`
+
+	DirectMessages DirectMessageConfig `yaml:"direct_messages"`
+}
+
+// DirectMessageConfig controls whether the bot answers 1:1 chats
+type DirectMessageConfig struct {
+	Enabled         bool     `yaml:"enabled" json:"enabled"`
+	AllowedContacts []string `yaml:"allowed_contacts" json:"allowed_contacts"` // contact JIDs, e.g. "491701234567@s.whatsapp.net"
+	RequireTrigger  bool     `yaml:"require_trigger" json:"require_trigger"`   // false answers every message
+}
+
+// GroupPersona overrides the default bot behaviour for a single group.
+// Empty fields fall back to the global LLM settings.
+type GroupPersona struct {
+	SystemPrompt  string   `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	Model         string   `yaml:"model,omitempty" json:"model,omitempty"`
+	Temperature   *float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	ContextLength int      `yaml:"context_length,omitempty" json:"context_length,omitempty"` // number of recent messages sent as context
+	VoiceReplies  bool     `yaml:"voice_replies,omitempty" json:"voice_replies,omitempty"`   // answer with voice notes instead of text
 }
 
 // OllamaConfig contains Ollama LLM settings
@@ -56,11 +155,185 @@ type OllamaConfig struct {
 	Model       string  `yaml:"model"`
 	Temperature float64 `yaml:"temperature"`
 	Timeout     string  `yaml:"timeout"`
+
+	// Streaming sends an initial reply and edits it as tokens arrive
+	Stream             bool   `yaml:"stream"`
+	StreamEditInterval string `yaml:"stream_edit_interval,omitempty"` // minimum time between edits, e.g. "2s"
+
+	// Context window budget in (estimated) tokens; ModelContextTokens overrides it per model name
+	ContextTokens      int            `yaml:"context_tokens,omitempty"`
+	ModelContextTokens map[string]int `yaml:"model_context_tokens,omitempty"`
+	MaxMessageTokens   int            `yaml:"max_message_tokens,omitempty"` // longer history messages are truncated
+
+	// VisionModel answers messages with images, e.g. "llava" or "gemma3"; empty uses the regular model
+	VisionModel string `yaml:"vision_model,omitempty"`
+}
+
+// LLMConfig selects which LLM backend answers messages
+type LLMConfig struct {
+	Provider string         `yaml:"provider"` // "ollama" (default) or "openai"
+	OpenAI   OpenAIConfig   `yaml:"openai"`
+	Queue    LLMQueueConfig `yaml:"queue"`
+}
+
+// LLMQueueConfig bounds the concurrent LLM requests. Waiting requests are served by priority
+// (replies, then scheduled webhooks, then summaries) and round-robin across chats.
+type LLMQueueConfig struct {
+	Concurrency  int    `yaml:"concurrency,omitempty"`   // requests generated at once, default 1
+	MaxQueued    int    `yaml:"max_queued,omitempty"`    // waiting requests before new ones are refused, default 100
+	NotifyAfter  string `yaml:"notify_after,omitempty"`  // wait before a user is told their place in line, default 10s; "0s" disables
+	QueueMessage string `yaml:"queue_message,omitempty"` // "{position}" is replaced; "-" stays silent
+	BusyMessage  string `yaml:"busy_message,omitempty"`  // reply when max_queued requests are waiting; "-" stays silent
+}
+
+// OpenAIConfig contains settings for an OpenAI-compatible chat completions endpoint
+type OpenAIConfig struct {
+	BaseURL     string  `yaml:"base_url"` // e.g. "http://localhost:8000/v1"
+	APIKey      string  `yaml:"api_key"`
+	Model       string  `yaml:"model"`
+	Temperature float64 `yaml:"temperature"`
+	Timeout     string  `yaml:"timeout"`
+
+	// Streaming sends an initial reply and edits it as tokens arrive
+	Stream             bool   `yaml:"stream"`
+	StreamEditInterval string `yaml:"stream_edit_interval,omitempty"` // minimum time between edits, e.g. "2s"
+
+	// VisionModel answers messages with images; empty uses the regular model
+	VisionModel string `yaml:"vision_model,omitempty"`
 }
 
 // StorageConfig contains storage settings
 type StorageConfig struct {
-	Type string `yaml:"type"`
+	Type                string `yaml:"type"`                             // "memory" or "sqlite"
+	Path                string `yaml:"path,omitempty"`                   // SQLite database file, e.g. "/data/messages.db"
+	MaxMessagesPerGroup int    `yaml:"max_messages_per_group,omitempty"` // 0 = unlimited
+	MaxAge              string `yaml:"max_age,omitempty"`                // e.g., "720h"; empty = keep forever
+}
+
+// SummaryConfig contains rolling conversation summary settings
+type SummaryConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Interval    string `yaml:"interval,omitempty"`     // how often groups are checked, e.g. "15m"
+	KeepRecent  int    `yaml:"keep_recent,omitempty"`  // newest messages left out of the summary (sent verbatim instead)
+	MinMessages int    `yaml:"min_messages,omitempty"` // new messages required before the summary is refreshed
+}
+
+// TranscriptionConfig contains voice note transcription settings
+type TranscriptionConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	URL      string `yaml:"url"`                // whisper.cpp /inference or OpenAI-compatible /v1/audio/transcriptions endpoint
+	Model    string `yaml:"model,omitempty"`    // model name, for servers hosting several
+	Language string `yaml:"language,omitempty"` // ISO-639-1 code; empty lets the server detect it
+	Timeout  string `yaml:"timeout,omitempty"`
+}
+
+// SpeechConfig contains text-to-speech settings for voice replies
+type SpeechConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	URL        string `yaml:"url"`                // Piper or Coqui TTS endpoint, e.g. "http://localhost:5002/api/tts"
+	Voice      string `yaml:"voice,omitempty"`    // voice/speaker name, for servers hosting several
+	Modifier   string `yaml:"modifier,omitempty"` // message prefix that requests a voice reply, default "@voice"
+	Timeout    string `yaml:"timeout,omitempty"`
+	FFmpegPath string `yaml:"ffmpeg_path,omitempty"` // converts WAV output to OGG/Opus and GIFs to MP4, default "ffmpeg"
+}
+
+// DocumentsConfig contains document question answering settings
+type DocumentsConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	ChunkTokens   int    `yaml:"chunk_tokens,omitempty"`   // size of the stored text chunks, default 300
+	ContextTokens int    `yaml:"context_tokens,omitempty"` // document excerpts added to a prompt, default 1000
+	PDFToTextPath string `yaml:"pdftotext_path,omitempty"` // extracts PDF text, default "pdftotext"
+}
+
+// KnowledgeConfig contains per-group knowledge base settings
+type KnowledgeConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	EmbeddingModel string   `yaml:"embedding_model"`        // Ollama embedding model, e.g. "nomic-embed-text"
+	URL            string   `yaml:"url,omitempty"`          // Ollama server for embeddings, default ollama.url
+	TopK           int      `yaml:"top_k,omitempty"`        // chunks added to a prompt, default 4
+	MinScore       *float64 `yaml:"min_score,omitempty"`    // minimum cosine similarity of a chunk, default 0.5; 0 keeps every match
+	ChunkTokens    int      `yaml:"chunk_tokens,omitempty"` // size of the embedded text chunks, default 300
+	Timeout        string   `yaml:"timeout,omitempty"`
+}
+
+// WebhookRetryConfig contains retry and circuit breaker settings for webhook calls
+type WebhookRetryConfig struct {
+	MaxAttempts      int    `yaml:"max_attempts,omitempty"`      // tries per call, default 3; 1 disables retries
+	InitialBackoff   string `yaml:"initial_backoff,omitempty"`   // wait before the first retry, doubled after each, default 1s
+	MaxBackoff       string `yaml:"max_backoff,omitempty"`       // default 30s
+	FailureThreshold int    `yaml:"failure_threshold,omitempty"` // consecutive failures that open a URL's circuit, default 5
+	OpenDuration     string `yaml:"open_duration,omitempty"`     // how long an open circuit fails fast, default 1m
+}
+
+// RateLimitConfig limits how often senders and groups can make the bot answer. Limits are token
+// buckets refilled at limit requests per period and holding up to burst requests; zero disables a limit.
+type RateLimitConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	Period           string   `yaml:"period,omitempty"`             // refill period of the limits, default 1m
+	SenderLimit      int      `yaml:"sender_limit,omitempty"`       // requests per sender per period
+	SenderBurst      int      `yaml:"sender_burst,omitempty"`       // default sender_limit
+	GroupLimit       int      `yaml:"group_limit,omitempty"`        // requests per group per period
+	GroupBurst       int      `yaml:"group_burst,omitempty"`        // default group_limit
+	SenderDailyQuota int      `yaml:"sender_daily_quota,omitempty"` // requests per sender per day, reset at midnight
+	GroupDailyQuota  int      `yaml:"group_daily_quota,omitempty"`  // requests per group per day
+	CooldownMessage  string   `yaml:"cooldown_message,omitempty"`   // reply when a limit is hit, "{wait}" is replaced; "-" stays silent
+	QuotaMessage     string   `yaml:"quota_message,omitempty"`      // reply when a quota is used up, "{quota}" is replaced; "-" stays silent
+	Exempt           []string `yaml:"exempt,omitempty"`             // sender JIDs or phone numbers that are never limited
+}
+
+// APIConfig contains authentication and CORS settings of the HTTP API. Without tokens, users
+// or OIDC the admin API is open to anyone who can reach it, and the messaging API is closed.
+type APIConfig struct {
+	Tokens      []APIToken `yaml:"tokens,omitempty"` // "Authorization: Bearer <token>", for other systems
+	Users       []APIUser  `yaml:"users,omitempty"`  // HTTP basic auth, for the admin UI
+	OIDC        OIDCConfig `yaml:"oidc,omitempty"`
+	CORSOrigins []string   `yaml:"cors_origins,omitempty"` // browser origins allowed to call the API, "*" for any; empty allows none
+
+	// AllowAnonymous makes everyone an admin while no credentials are configured; otherwise
+	// anonymous callers may only read
+	AllowAnonymous bool `yaml:"allow_anonymous,omitempty"`
+
+	// TrustedProxies are the reverse proxies, as IPs or CIDRs, whose X-Forwarded-For header
+	// names the client; it is ignored from anyone else
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+}
+
+// API roles; each role may do everything the roles before it may
+const (
+	RoleViewer   = "viewer"   // read groups, schedules, logs and settings
+	RoleOperator = "operator" // manage schedules, knowledge and presence, send messages
+	RoleAdmin    = "admin"    // change allowed groups, webhooks and personas, link the WhatsApp session
+)
+
+// APIToken is a credential of one API client, e.g. CI or Grafana
+type APIToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  string `yaml:"role,omitempty"` // default operator
+}
+
+// APIUser is an admin UI user signing in with HTTP basic auth
+type APIUser struct {
+	Name         string `yaml:"name"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordHash string `yaml:"password_hash,omitempty"` // bcrypt, used instead of password
+	Role         string `yaml:"role,omitempty"`          // default viewer
+}
+
+// OIDCConfig accepts bearer tokens (JWTs) issued by an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer      string `yaml:"issuer,omitempty"`       // enables OIDC, e.g. "http://localhost:5556/dex"
+	Audience    string `yaml:"audience,omitempty"`     // client ID the tokens must be issued for, required
+	JWKSURL     string `yaml:"jwks_url,omitempty"`     // default: discovered from the issuer
+	RoleClaim   string `yaml:"role_claim,omitempty"`   // claim with the role or list of roles, default "roles"
+	DefaultRole string `yaml:"default_role,omitempty"` // for tokens without a known role, default viewer
+}
+
+// ToolsConfig contains LLM tool calling settings
+type ToolsConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	MaxIterations int      `yaml:"max_iterations,omitempty"` // model/tool round trips per message, default 5
+	BuiltIns      []string `yaml:"built_ins,omitempty"`      // e.g. "get_server_time", "create_schedule"; empty = all
 }
 
 // WebhookConfig contains webhook settings
@@ -68,13 +341,144 @@ type WebhookConfig struct {
 	SubTrigger string `yaml:"sub_trigger" json:"sub_trigger"`
 	URL        string `yaml:"url" json:"url"`
 	Timeout    string `yaml:"timeout" json:"timeout"` // e.g., "60s", "2m"
+
+	// Tool exposes the webhook to the LLM as a callable function
+	Tool        bool   `yaml:"tool,omitempty" json:"tool,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"` // tells the model when to call it
+
+	// PayloadVersion selects the request schema: 1 (default) sends only {"message"},
+	// 2 adds the sender, chat, quoted message, media and recent context
+	PayloadVersion  int `yaml:"payload_version,omitempty" json:"payload_version,omitempty"`
+	ContextMessages int `yaml:"context_messages,omitempty" json:"context_messages,omitempty"` // recent messages in version 2 payloads, default 10
+
+	// Authentication of outgoing calls. Secret signs the body with HMAC-SHA256 in the
+	// X-Webhook-Signature header; bearer and basic auth set the Authorization header.
+	Secret        string            `yaml:"secret,omitempty" json:"secret,omitempty"`
+	BearerToken   string            `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+	BasicUsername string            `yaml:"basic_username,omitempty" json:"basic_username,omitempty"`
+	BasicPassword string            `yaml:"basic_password,omitempty" json:"basic_password,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"` // added to every call, e.g. an API key
+
+	// Retries override the webhook_retry settings, e.g. to keep retrying through a nightly reboot
+	MaxAttempts    int    `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+	InitialBackoff string `yaml:"initial_backoff,omitempty" json:"initial_backoff,omitempty"`
+	MaxBackoff     string `yaml:"max_backoff,omitempty" json:"max_backoff,omitempty"`
+
+	// Async webhooks get a callback_id and callback_url, answer at once and post the result to the
+	// callback URL later; the bot acknowledges the message in the meantime
+	Async           bool   `yaml:"async,omitempty" json:"async,omitempty"`
+	AckMessage      string `yaml:"ack_message,omitempty" json:"ack_message,omitempty"`           // default "Working on it…"
+	CallbackTimeout string `yaml:"callback_timeout,omitempty" json:"callback_timeout,omitempty"` // how long to wait for the result, default 1h
+}
+
+// ErrWebhookCircuitOpen is returned without calling a webhook whose endpoint keeps failing
+var ErrWebhookCircuitOpen = errors.New("webhook endpoint is failing, circuit open")
+
+// Headers of requests signed with a webhook secret, both calls to the webhook and the
+// results async webhooks post back
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp" // Unix time the request was signed at
+	WebhookSignatureHeader = "X-Webhook-Signature" // "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+)
+
+// WebhookError is returned when a webhook call fails
+type WebhookError struct {
+	Attempts int // calls made; 0 when the circuit was open
+	Err      error
+}
+
+func (e *WebhookError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+func (e *WebhookError) Unwrap() error {
+	return e.Err
+}
+
+// Webhook payload versions
+const (
+	WebhookPayloadV1 = 1
+	WebhookPayloadV2 = 2
+)
+
+// WebhookRequest is the payload sent to a webhook. Version 1 payloads only carry Message.
+type WebhookRequest struct {
+	Version       int                     `json:"version"`
+	Message       string                  `json:"message"`
+	Source        string                  `json:"source"` // "message", "tool" or "schedule"
+	MessageID     string                  `json:"message_id,omitempty"`
+	ChatJID       string                  `json:"chat_jid,omitempty"`
+	GroupJID      string                  `json:"group_jid,omitempty"`
+	GroupName     string                  `json:"group_name,omitempty"`
+	IsDirect      bool                    `json:"is_direct"`
+	Sender        string                  `json:"sender,omitempty"`
+	SenderName    string                  `json:"sender_name,omitempty"`
+	Timestamp     time.Time               `json:"timestamp"`
+	QuotedMessage *WebhookQuotedMessage   `json:"quoted_message,omitempty"`
+	Media         *WebhookMediaReference  `json:"media,omitempty"`
+	Context       []WebhookContextMessage `json:"context,omitempty"`      // oldest first, excluding the message itself
+	CallbackID    string                  `json:"callback_id,omitempty"`  // async webhooks only
+	CallbackURL   string                  `json:"callback_url,omitempty"` // where to POST the result
+}
+
+// WebhookQuotedMessage is the message a webhook request replies to
+type WebhookQuotedMessage struct {
+	ID      string `json:"id"`
+	Sender  string `json:"sender,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// WebhookMediaReference describes the attachment of a webhook request. It is metadata only:
+// the bot does not keep media, so neither the data nor a URL to fetch it is available.
+type WebhookMediaReference struct {
+	Type      MediaType `json:"type"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	FileName  string    `json:"file_name,omitempty"`
+	Size      int       `json:"size"`
+	MessageID string    `json:"message_id,omitempty"`
+}
+
+// WebhookContextMessage is a recent chat message included in a webhook request
+type WebhookContextMessage struct {
+	Sender     string    `json:"sender"`
+	SenderName string    `json:"sender_name,omitempty"`
+	Content    string    `json:"content"`
+	IsFromBot  bool      `json:"is_from_bot"`
+	Timestamp  time.Time `json:"timestamp"`
 }
 
 // WebhookResponse represents a response from a webhook
 type WebhookResponse struct {
-	ContentType string // "text", "image/jpeg", "image/png"
-	Content     []byte // Raw content (text or image data)
-	TextContent string // Convenience field for text responses
+	ContentType string // "text", "multipart" or the media MIME type, e.g. "image/png", "application/pdf"
+	Content     []byte // Raw content (text or media data)
+	TextContent string // Convenience field for text responses; the text parts of multi-part responses
+	Media       *Media // File to send for non-text responses, nil for text
+	Attempts    int    // calls it took to get the response
+
+	// Parts are the messages of a multi-part response, sent in order
+	Parts []WebhookResponsePart
+}
+
+// WebhookPartType identifies the kind of a webhook response part
+type WebhookPartType string
+
+const (
+	WebhookPartText     WebhookPartType = "text"
+	WebhookPartMedia    WebhookPartType = "media"
+	WebhookPartReaction WebhookPartType = "reaction"
+	WebhookPartAsk      WebhookPartType = "ask"
+)
+
+// WebhookResponsePart is one message of a multi-part webhook response
+type WebhookResponsePart struct {
+	Type     WebhookPartType
+	Text     string   // message text, media caption or the prompt of an ask part
+	Media    *Media   // media parts only
+	Emoji    string   // reaction parts only, added to the triggering message
+	Mentions []string // JIDs mentioned by a text part
 }
 
 // Schedule represents a scheduled webhook trigger
@@ -83,15 +487,15 @@ type Schedule struct {
 	Name         string     `json:"name"`
 	GroupJID     string     `json:"group_jid"`
 	WebhookURL   string     `json:"webhook_url"`
-	UsePrompt    bool       `json:"use_prompt"`               // Whether to use custom prompt
-	Prompt       string     `json:"prompt,omitempty"`         // Custom prompt/text to send to webhook
-	ScheduleType string     `json:"schedule_type"`            // "weekly", "yearly", "once"
-	DayOfWeek    *int       `json:"day_of_week,omitempty"`    // 0 = Sunday, 6 = Saturday (for weekly)
-	Month        *int       `json:"month,omitempty"`          // 1-12 (for yearly)
-	DayOfMonth   *int       `json:"day_of_month,omitempty"`   // 1-31 (for yearly)
-	Hour         int        `json:"hour"`                     // 0-23
-	Minute       int        `json:"minute"`                   // 0-59
-	SpecificDate *time.Time `json:"specific_date,omitempty"`  // Specific date for one-time schedules
+	UsePrompt    bool       `json:"use_prompt"`              // Whether to use custom prompt
+	Prompt       string     `json:"prompt,omitempty"`        // Custom prompt/text to send to webhook
+	ScheduleType string     `json:"schedule_type"`           // "weekly", "yearly", "once"
+	DayOfWeek    *int       `json:"day_of_week,omitempty"`   // 0 = Sunday, 6 = Saturday (for weekly)
+	Month        *int       `json:"month,omitempty"`         // 1-12 (for yearly)
+	DayOfMonth   *int       `json:"day_of_month,omitempty"`  // 1-31 (for yearly)
+	Hour         int        `json:"hour"`                    // 0-23
+	Minute       int        `json:"minute"`                  // 0-59
+	SpecificDate *time.Time `json:"specific_date,omitempty"` // Specific date for one-time schedules
 	Enabled      bool       `json:"enabled"`
 	LastRun      *time.Time `json:"last_run,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
@@ -106,18 +510,97 @@ type ScheduleExecution struct {
 	Success    bool      `json:"success"`
 	Error      string    `json:"error,omitempty"`
 	Response   string    `json:"response,omitempty"`
+	Attempts   int       `json:"attempts"` // webhook calls made, including retries
 }
 
 // LLMRequest represents a request to the LLM
 type LLMRequest struct {
-	Prompt  string
-	Context []Message
+	SystemPrompt string
+	Messages     []ChatMessage // conversation, oldest first, ending with the triggering message
+
+	// Optional per-request overrides of the provider defaults
+	Model       string
+	Temperature *float64
+
+	// Tools the model may call instead of answering directly; empty disables tool calling
+	Tools []ToolDefinition
+}
+
+// ToolDefinition describes a function the LLM can call
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON schema of the arguments object
+}
+
+// ToolCall is a function invocation requested by the LLM
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON-encoded arguments object
+}
+
+// ChatRole identifies who authored a message in an LLM conversation
+type ChatRole string
+
+const (
+	ChatRoleUser      ChatRole = "user"
+	ChatRoleAssistant ChatRole = "assistant"
+	ChatRoleTool      ChatRole = "tool"
+)
+
+// ChatMessage is a single role-tagged message in an LLM conversation
+type ChatMessage struct {
+	Role       ChatRole
+	SenderName string // display name of the human sender; empty for the assistant
+	Content    string
+	ToolCalls  []ToolCall // calls requested by the assistant
+	ToolCallID string     // the call a tool message is the result of
+	Images     []*Media   // images for vision-capable models
 }
 
 // LLMResponse represents a response from the LLM
 type LLMResponse struct {
-	Content string
-	Error   error
+	Content   string
+	ToolCalls []ToolCall // non-empty when the model wants tools run before answering
+	Error     error
+}
+
+// ConversationSummary is a condensed record of a group's older conversation history
+type ConversationSummary struct {
+	GroupJID     string    `json:"group_jid"`
+	Content      string    `json:"content"`
+	CoveredUntil time.Time `json:"covered_until"` // timestamp of the newest summarized message
+	MessageCount int       `json:"message_count"` // total messages folded into the summary
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Document is a shared file whose extracted text is kept for question answering
+type Document struct {
+	ID        string    `json:"id"` // ID of the WhatsApp message that shared the file
+	ChatJID   string    `json:"chat_jid"`
+	FileName  string    `json:"file_name"`
+	Sender    string    `json:"sender"`
+	Chunks    []string  `json:"-"` // extracted text, split for retrieval
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KnowledgeDocument is a curated text, e.g. a Markdown file or FAQ, in a group's knowledge base
+type KnowledgeDocument struct {
+	ID        string    `json:"id"`
+	GroupJID  string    `json:"group_jid"`
+	Title     string    `json:"title"`
+	Chunks    int       `json:"chunks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KnowledgeChunk is an embedded piece of a knowledge document
+type KnowledgeChunk struct {
+	DocumentID string    `json:"document_id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Embedding  []float32 `json:"-"`
+	Score      float64   `json:"score,omitempty"` // cosine similarity to the query, set by Search
 }
 
 // AuthStatus represents WhatsApp authentication status
@@ -129,10 +612,10 @@ type AuthStatus struct {
 
 // ContactPresence represents a contact's presence status
 type ContactPresence struct {
-	JID            string    `json:"jid"`              // WhatsApp JID
-	Name           string    `json:"name,omitempty"`   // Contact name
-	IsOnline       bool      `json:"is_online"`        // Current online status
-	LastSeen       time.Time `json:"last_seen"`        // Last seen timestamp
+	JID              string    `json:"jid"`                // WhatsApp JID
+	Name             string    `json:"name,omitempty"`     // Contact name
+	IsOnline         bool      `json:"is_online"`          // Current online status
+	LastSeen         time.Time `json:"last_seen"`          // Last seen timestamp
 	LastStatusChange time.Time `json:"last_status_change"` // When status last changed
 }
 
@@ -142,3 +625,60 @@ type PresenceEvent struct {
 	IsOnline  bool      `json:"is_online"`
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// OutgoingMessage is a message sent into a chat through the messaging API
+type OutgoingMessage struct {
+	ChatJID          string
+	Text             string // the caption when Media is set
+	Media            *Media
+	ReplyToMessageID string
+	ReplyToSender    string
+	Mentions         []string // phone numbers or JIDs, text messages only
+}
+
+// MentionJIDs turns phone numbers into user JIDs, leaving full JIDs as they are
+func MentionJIDs(mentions []string) []string {
+	jids := make([]string, 0, len(mentions))
+	for _, mention := range mentions {
+		mention = strings.TrimPrefix(strings.TrimSpace(mention), "@")
+		if mention == "" {
+			continue
+		}
+		if !strings.Contains(mention, "@") {
+			mention = strings.TrimPrefix(mention, "+") + "@s.whatsapp.net"
+		}
+		jids = append(jids, mention)
+	}
+	return jids
+}
+
+// SentMessage is the result of sending an OutgoingMessage
+type SentMessage struct {
+	ChatJID        string    `json:"chat_jid"`
+	Type           string    `json:"type"` // "text" or the media type
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+	Duplicate      bool      `json:"duplicate"` // the key was used before, so nothing was sent again
+	SentAt         time.Time `json:"sent_at"`
+}
+
+// AuditEntry records a change made through the admin API
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`            // user, token or OIDC subject that made the change
+	Action    string    `json:"action"`           // e.g. "webhook.delete", "schedule.update"
+	Target    string    `json:"target"`           // what was changed, e.g. a sub-trigger or schedule ID
+	Before    string    `json:"before,omitempty"` // JSON state before the change, secrets masked
+	After     string    `json:"after,omitempty"`  // JSON state after the change, secrets masked
+	Diff      string    `json:"diff,omitempty"`   // human-readable summary of the changed fields
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AuditFilter selects audit entries; zero fields match everything
+type AuditFilter struct {
+	Actor  string
+	Action string // exact action, or a prefix ending in "." such as "webhook."
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
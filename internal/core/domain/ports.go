@@ -8,23 +8,79 @@ import (
 // MessageRepository defines the interface for message storage
 type MessageRepository interface {
 	Save(ctx context.Context, message *Message) error
-	GetByGroupJID(ctx context.Context, groupJID string, limit int) ([]*Message, error)
+	GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*Message, error)
 	GetAll(ctx context.Context) ([]*Message, error)
 }
 
+// SummaryRepository defines the interface for conversation summary storage
+type SummaryRepository interface {
+	Get(ctx context.Context, groupJID string) (*ConversationSummary, error) // nil if the group has no summary
+	Save(ctx context.Context, summary *ConversationSummary) error
+	Delete(ctx context.Context, groupJID string) error
+}
+
+// DocumentRepository defines the interface for storing the text of shared documents
+type DocumentRepository interface {
+	Save(ctx context.Context, document *Document) error
+	Get(ctx context.Context, chatJID, documentID string) (*Document, error)           // nil if not found
+	GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*Document, error) // newest first
+}
+
+// KnowledgeRepository defines the interface for the per-group knowledge base vector store
+type KnowledgeRepository interface {
+	SaveDocument(ctx context.Context, document *KnowledgeDocument, chunks []*KnowledgeChunk) error
+	ListDocuments(ctx context.Context, groupJID string) ([]*KnowledgeDocument, error)
+	DeleteDocument(ctx context.Context, groupJID, documentID string) error
+	Search(ctx context.Context, groupJID string, embedding []float32, limit int) ([]*KnowledgeChunk, error) // most similar first
+}
+
+// Embedder defines the interface for text embedding models
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
 // LLMProvider defines the interface for LLM interactions
 type LLMProvider interface {
 	Generate(ctx context.Context, request *LLMRequest) (*LLMResponse, error)
 	IsAvailable(ctx context.Context) bool
 }
 
+// StreamingLLMProvider is implemented by LLM providers that can stream partial output.
+// onChunk is called with each new piece of text as it is generated; returning an error aborts generation.
+type StreamingLLMProvider interface {
+	LLMProvider
+	GenerateStream(ctx context.Context, request *LLMRequest, onChunk func(chunk string) error) (*LLMResponse, error)
+}
+
+// Transcriber defines the interface for speech-to-text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio *Media) (string, error)
+}
+
+// TextExtractor defines the interface for extracting plain text from documents
+type TextExtractor interface {
+	Extract(ctx context.Context, document *Media) (string, error)
+}
+
+// SpeechSynthesizer defines the interface for text-to-speech.
+// The returned audio is OGG/Opus so it can be sent as a WhatsApp voice note.
+type SpeechSynthesizer interface {
+	Synthesize(ctx context.Context, text string) (*Media, error)
+}
+
 // WhatsAppClient defines the interface for WhatsApp operations
 type WhatsAppClient interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
 	SendMessage(ctx context.Context, groupJID, message string) error
 	SendReply(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) error
+	SendReplyWithID(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) (string, error)
+	EditMessage(ctx context.Context, groupJID, messageID, message string) error
 	SendImage(ctx context.Context, groupJID string, imageData []byte, mimeType, caption, replyToMessageID, quotedSender string) error
+	SendAudio(ctx context.Context, groupJID string, audioData []byte, mimeType, replyToMessageID, quotedSender string) error
+	SendMedia(ctx context.Context, groupJID string, media *Media, caption, replyToMessageID, quotedSender string) error
+	SendMentions(ctx context.Context, groupJID, message string, mentions []string, replyToMessageID, quotedSender string) error
+	SendReaction(ctx context.Context, groupJID, messageID, sender, emoji string) error
 	GetGroups(ctx context.Context) ([]*Group, error)
 	GetGroupParticipants(ctx context.Context, groupJID string) ([]*GroupParticipant, error)
 	GetAuthStatus(ctx context.Context) (*AuthStatus, error)
@@ -54,7 +110,11 @@ type GroupManager interface {
 
 // WebhookClient defines the interface for webhook interactions
 type WebhookClient interface {
-	Call(ctx context.Context, url string, message string) (*WebhookResponse, error)
+	Call(ctx context.Context, webhook *WebhookConfig, request *WebhookRequest) (*WebhookResponse, error)
+	// ParseResponse reads a webhook result posted to the bot, e.g. by an async webhook's callback
+	ParseResponse(ctx context.Context, contentType, contentDisposition string, body []byte) (*WebhookResponse, error)
+	// VerifySignature checks that a body posted to the bot was signed with the webhook's secret
+	VerifySignature(webhook *WebhookConfig, timestamp, signature string, body []byte) error
 }
 
 // ScheduleRepository defines the interface for schedule storage
@@ -71,3 +131,9 @@ type ScheduleRepository interface {
 	LogExecution(ctx context.Context, execution *ScheduleExecution) error
 	GetExecutions(ctx context.Context, scheduleID string, limit int) ([]*ScheduleExecution, error)
 }
+
+// AuditRepository stores the append-only log of admin changes
+type AuditRepository interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) // newest first
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// maxAuditDiffValue truncates long values in audit diffs
+const maxAuditDiffValue = 80
+
+// Audit actions
+const (
+	AuditAllowedGroupsUpdate = "allowed_groups.update"
+	AuditWebhookAdd          = "webhook.add"
+	AuditWebhookDelete       = "webhook.delete"
+	AuditPersonaUpdate       = "persona.update"
+	AuditPersonaDelete       = "persona.delete"
+	AuditScheduleCreate      = "schedule.create"
+	AuditScheduleUpdate      = "schedule.update"
+	AuditScheduleDelete      = "schedule.delete"
+)
+
+// AuditService records who changed the bot's configuration and schedules, and when
+type AuditService struct {
+	repository domain.AuditRepository
+	logger     *slog.Logger
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repository domain.AuditRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// Record appends a change to the audit log. before and after are stored as JSON, nil meaning
+// the target did not exist; callers mask secrets first. A failure is logged, as the change has
+// already been made.
+func (s *AuditService) Record(ctx context.Context, actor, action, target string, before, after interface{}) {
+	entry := &domain.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    auditJSON(before),
+		After:     auditJSON(after),
+		Timestamp: time.Now(),
+	}
+	entry.Diff = auditDiff(entry.Before, entry.After)
+
+	if err := s.repository.Append(ctx, entry); err != nil {
+		s.logger.Error("Failed to record audit entry", "error", err, "actor", actor, "action", action, "target", target)
+		return
+	}
+
+	s.logger.Info("Audit", "actor", actor, "action", action, "target", target, "diff", entry.Diff)
+}
+
+// List returns the audit entries matching the filter, newest first
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	return s.repository.List(ctx, filter)
+}
+
+// auditJSON encodes a state for the audit log, or "" for nil
+func auditJSON(state interface{}) string {
+	if state == nil {
+		return ""
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Sprintf("%q", fmt.Sprint(state))
+	}
+	return string(data)
+}
+
+// auditDiff summarizes the difference between two JSON states: the changed fields of
+// objects, the added and removed items of lists, or the whole value otherwise
+func auditDiff(before, after string) string {
+	switch {
+	case before == "" && after == "":
+		return ""
+	case before == "":
+		return "created"
+	case after == "":
+		return "deleted"
+	}
+
+	var old, updated interface{}
+	if json.Unmarshal([]byte(before), &old) != nil || json.Unmarshal([]byte(after), &updated) != nil {
+		return ""
+	}
+
+	switch old := old.(type) {
+	case map[string]interface{}:
+		if updated, ok := updated.(map[string]interface{}); ok {
+			return diffObjects(old, updated)
+		}
+	case []interface{}:
+		if updated, ok := updated.([]interface{}); ok {
+			return diffLists(old, updated)
+		}
+	}
+
+	if before == after {
+		return "no changes"
+	}
+	return diffValue(old) + " → " + diffValue(updated)
+}
+
+// diffObjects lists the fields that differ between two objects
+func diffObjects(old, updated map[string]interface{}) string {
+	keys := make(map[string]bool)
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range updated {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		before, after := diffValue(old[key]), diffValue(updated[key])
+		if before != after {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", key, before, after))
+		}
+	}
+
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// diffLists lists the items added to and removed from a list
+func diffLists(old, updated []interface{}) string {
+	count := func(items []interface{}) map[string]int {
+		counts := make(map[string]int)
+		for _, item := range items {
+			counts[diffValue(item)]++
+		}
+		return counts
+	}
+	oldCounts, updatedCounts := count(old), count(updated)
+
+	var added, removed []string
+	for _, item := range updated {
+		value := diffValue(item)
+		if oldCounts[value] > 0 {
+			oldCounts[value]--
+		} else {
+			added = append(added, value)
+		}
+	}
+	for _, item := range old {
+		value := diffValue(item)
+		if updatedCounts[value] > 0 {
+			updatedCounts[value]--
+		} else {
+			removed = append(removed, value)
+		}
+	}
+
+	var changes []string
+	if len(added) > 0 {
+		changes = append(changes, "added "+strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, "removed "+strings.Join(removed, ", "))
+	}
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// diffValue formats a JSON value for a diff, truncating long values
+func diffValue(value interface{}) string {
+	if value == nil {
+		return "∅"
+	}
+	data, _ := json.Marshal(value)
+	text := string(data)
+	if len([]rune(text)) > maxAuditDiffValue {
+		text = string([]rune(text)[:maxAuditDiffValue]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{"created", "", `{"sub_trigger":"#family"}`, "created"},
+		{"deleted", `{"sub_trigger":"#family"}`, "", "deleted"},
+		{"changed fields", `{"name":"Daily","hour":8,"enabled":true}`, `{"name":"Daily","hour":9,"enabled":false}`, "enabled: true → false; hour: 8 → 9"},
+		{"added field", `{"name":"Daily"}`, `{"name":"Daily","prompt":"hi"}`, `prompt: ∅ → "hi"`},
+		{"list", `["a@g.us","b@g.us"]`, `["b@g.us","c@g.us"]`, `added "c@g.us"; removed "a@g.us"`},
+		{"unchanged", `["a@g.us"]`, `["a@g.us"]`, "no changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auditDiff(tt.before, tt.after); got != tt.want {
+				t.Errorf("auditDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
 )
@@ -20,10 +23,55 @@ type ChatService struct {
 	webhookClient  domain.WebhookClient
 	triggerWords   []string
 	webhookConfigs []domain.WebhookConfig
+	streamEnabled  bool
+	streamInterval time.Duration
+	personas       map[string]domain.GroupPersona
+	contextBuilder *ContextBuilder
+	summaries      domain.SummaryRepository
+	directMessages domain.DirectMessageConfig
+	visionModel    string
+	transcriber    domain.Transcriber
+	synthesizer    domain.SpeechSynthesizer
+	voiceModifier  string
+	documents      *DocumentService
+	knowledge      *KnowledgeService
+	tools          *ToolRegistry
+	maxToolRounds  int
+	publicURL      string
+	callbacks      *callbackRegistry
+	limiter        *RateLimiter
+	queue          *LLMQueue
 	configMu       sync.RWMutex
 	logger         *slog.Logger
 }
 
+// defaultSystemPrompt is the instruction given to the model unless a group persona overrides it
+const defaultSystemPrompt = "You are a helpful AI assistant in a WhatsApp group chat. " +
+	"Messages from group members are prefixed with their name. " +
+	"Provide concise, friendly, and helpful responses. " +
+	"Keep your answers brief and to the point."
+
+// defaultDirectSystemPrompt is the instruction given to the model in direct messages
+const defaultDirectSystemPrompt = "You are a helpful AI assistant in a private WhatsApp chat with one person. " +
+	"Provide concise, friendly, and helpful responses. " +
+	"Keep your answers brief and to the point."
+
+// defaultContextMessages is the number of recent messages considered for context;
+// the context builder then trims them to the model's token budget
+const defaultContextMessages = 50
+
+// defaultStreamEditInterval is the minimum time between progressive edits of a streamed reply
+const defaultStreamEditInterval = 2 * time.Second
+
+// defaultMaxToolRounds bounds the model/tool round trips for a single message
+const defaultMaxToolRounds = 5
+
+// defaultVoiceModifier is the message prefix that requests a voice reply
+const defaultVoiceModifier = "@voice"
+
+// technicalErrorMessage is sent to users when a request cannot be processed
+const technicalErrorMessage = "Sorry, I cannot process this request right now due to a technical error. Please try again later."
+
 // NewChatService creates a new chat service
 func NewChatService(
 	llmProvider domain.LLMProvider,
@@ -43,38 +91,60 @@ func NewChatService(
 		webhookClient:  webhookClient,
 		triggerWords:   triggerWords,
 		webhookConfigs: webhookConfigs,
+		contextBuilder: NewContextBuilder("", 0, nil, 0),
+		callbacks:      newCallbackRegistry(),
 		logger:         logger,
 	}
 }
 
 // ProcessMessage processes an incoming message
 func (s *ChatService) ProcessMessage(ctx context.Context, message *domain.Message) error {
-	// Validate group is allowed
-	if !s.groupMgr.IsAllowed(message.GroupJID) {
-		s.logger.Debug("Message from non-allowed group", "group", message.GroupJID)
-		return nil
+	// Older producers only set the group JID
+	if message.ChatJID == "" {
+		message.ChatJID = message.GroupJID
 	}
 
-	// Check if message starts with any trigger word OR is a reply to bot
 	s.configMu.RLock()
 	triggerWords := s.triggerWords
+	directMessages := s.directMessages
 	s.configMu.RUnlock()
 
-	if len(triggerWords) > 0 && !message.IsReplyToBot {
-		trimmedContent := strings.TrimSpace(message.Content)
-		triggered := false
-		var matchedTrigger string
-
-		for _, trigger := range triggerWords {
-			if strings.HasPrefix(trimmedContent, trigger) {
-				triggered = true
-				matchedTrigger = trigger
-				// Remove trigger word from message content
-				message.Content = strings.TrimSpace(strings.TrimPrefix(message.Content, trigger))
-				break
-			}
+	// Validate the chat is allowed
+	if message.IsDirect {
+		if !directMessages.Enabled || !isContactAllowed(directMessages.AllowedContacts, message.ChatJID) {
+			s.logger.Debug("Direct message from non-allowed contact", "chat", message.ChatJID)
+			return nil
+		}
+	} else if !s.groupMgr.IsAllowed(message.GroupJID) {
+		s.logger.Debug("Message from non-allowed group", "group", message.GroupJID)
+		return nil
+	}
+
+	// Shared documents are stored even when the bot is not addressed
+	var document *domain.Document
+	if message.Media != nil && message.Media.Type == domain.MediaTypeDocument {
+		document = s.ingestDocument(ctx, message)
+		if message.Content == "" {
+			// Shared without a question
+			return nil
+		}
+	}
+
+	// Voice notes carry no text until they are transcribed
+	if message.Media != nil && message.Media.Type == domain.MediaTypeAudio && message.Content == "" {
+		if !s.transcribeVoiceNote(ctx, message, triggerWords) {
+			return nil
 		}
+	}
 
+	// Check if message starts with any trigger word OR is a reply to bot
+	checkWebhooks := false
+	if message.IsDirect && !directMessages.RequireTrigger {
+		// Every direct message is addressed to the bot; a leading trigger word is optional
+		stripTriggerWord(message, triggerWords)
+		checkWebhooks = true
+	} else if len(triggerWords) > 0 && !message.IsReplyToBot {
+		matchedTrigger, triggered := stripTriggerWord(message, triggerWords)
 		if !triggered {
 			s.logger.Debug("Message doesn't start with any trigger word and is not a reply",
 				"triggers", triggerWords,
@@ -83,15 +153,29 @@ func (s *ChatService) ProcessMessage(ctx context.Context, message *domain.Messag
 		}
 
 		s.logger.Debug("Message triggered", "trigger", matchedTrigger)
+		checkWebhooks = true
+	} else if message.IsReplyToBot {
+		s.logger.Debug("Message is a reply to bot", "content", message.Content)
+	}
 
-		// Check for webhook sub-trigger
+	if !s.allowRequest(ctx, message) {
+		return nil
+	}
+
+	// Photos and quoted documents are only downloaded for messages the bot answers
+	if s.loadMedia(ctx, message) && message.Media.Type == domain.MediaTypeDocument {
+		document = s.ingestDocument(ctx, message)
+	}
+
+	// Check for webhook sub-trigger
+	if checkWebhooks {
 		if webhook := s.findMatchingWebhook(message.Content); webhook != nil {
 			return s.processWebhookMessage(ctx, message, webhook)
 		}
-	} else if message.IsReplyToBot {
-		s.logger.Debug("Message is a reply to bot", "content", message.Content)
 	}
 
+	voiceRequested := s.stripVoiceModifier(message)
+
 	// Save incoming message
 	if err := s.repository.Save(ctx, message); err != nil {
 		s.logger.Error("Failed to save message", "error", err)
@@ -99,54 +183,110 @@ func (s *ChatService) ProcessMessage(ctx context.Context, message *domain.Messag
 	}
 
 	s.logger.Info("Processing message",
-		"group", message.GroupJID,
+		"chat", message.ChatJID,
 		"sender", message.Sender,
 		"content", message.Content)
 
+	// Apply the group's persona, if any
+	s.configMu.RLock()
+	persona := s.personas[message.ChatJID]
+	s.configMu.RUnlock()
+
+	contextLength := defaultContextMessages
+	if persona.ContextLength > 0 {
+		contextLength = persona.ContextLength
+	}
+
 	// Get conversation context
-	context, err := s.repository.GetByGroupJID(ctx, message.GroupJID, 10)
+	context, err := s.repository.GetByChatJID(ctx, message.ChatJID, contextLength)
 	if err != nil {
 		s.logger.Error("Failed to get context", "error", err)
 		return fmt.Errorf("failed to get context: %w", err)
 	}
 
-	// Generate LLM response - convert []*domain.Message to []domain.Message
-	contextMsgs := make([]domain.Message, len(context))
-	for i, msg := range context {
-		contextMsgs[i] = *msg
+	systemPrompt := defaultPrompt(message.IsDirect)
+	if persona.SystemPrompt != "" {
+		systemPrompt = persona.SystemPrompt
 	}
 
+	// Prepend the rolling summary and drop history it already covers
+	systemPrompt, context = s.applySummary(ctx, message.ChatJID, systemPrompt, context)
+
+	// Add the passages of shared documents that answer the question
+	systemPrompt = s.applyDocuments(ctx, message, systemPrompt, document)
+
+	// Ground the answer in the group's knowledge base
+	systemPrompt = s.applyKnowledge(ctx, message, systemPrompt)
+
+	s.configMu.RLock()
+	contextBuilder := s.contextBuilder
+	s.configMu.RUnlock()
+
 	llmRequest := &domain.LLMRequest{
-		Prompt:  message.Content,
-		Context: contextMsgs,
+		SystemPrompt: systemPrompt,
+		Messages:     contextBuilder.Build(systemPrompt, context, message, persona.Model),
+		Model:        persona.Model,
+		Temperature:  persona.Temperature,
 	}
 
-	response, err := s.llmProvider.Generate(ctx, llmRequest)
-	if err != nil || response.Error != nil {
-		s.logger.Error("Failed to generate LLM response", "error", err)
+	s.configMu.RLock()
+	streamEnabled := s.streamEnabled
+	tools := s.tools
+	visionModel := s.visionModel
+	voiceReply := (voiceRequested || persona.VoiceReplies) && s.synthesizer != nil
+	s.configMu.RUnlock()
 
-		// Send user-friendly error message as a reply
-		errorMsg := "Sorry, I cannot process this request right now due to a technical error. Please try again later."
-		if err := s.whatsapp.SendReply(ctx, message.GroupJID, errorMsg, message.ID, message.Sender); err != nil {
-			s.logger.Error("Failed to send error message", "error", err)
-		}
-		return fmt.Errorf("failed to generate response: %w", err)
+	// Text-only models cannot see images, so switch to the vision model if one is set
+	if message.Media != nil && message.Media.Type == domain.MediaTypeImage && visionModel != "" {
+		llmRequest.Model = visionModel
 	}
 
-	s.logger.Info("Generated response", "content", response.Content)
+	if tools != nil {
+		llmRequest.Tools = tools.Definitions()
+	}
+
+	ctx = s.queueJob(ctx, message)
+
+	// Tool calls need complete responses, so streaming is only used without tools.
+	// Voice replies are synthesized from the complete response as well.
+	var responseContent string
+	if streamer, ok := s.llmProvider.(domain.StreamingLLMProvider); ok && streamEnabled && len(llmRequest.Tools) == 0 && !voiceReply {
+		responseContent, err = s.streamResponse(ctx, message, llmRequest, streamer)
+		if err != nil {
+			return err
+		}
+	} else {
+		response, err := s.generate(ctx, message, llmRequest)
+		if err != nil {
+			s.logger.Error("Failed to generate LLM response", "error", err)
+
+			// Send user-friendly error message as a reply
+			if reply := s.generateErrorReply(err); reply != "" {
+				if err := s.whatsapp.SendReply(ctx, message.ChatJID, reply, message.ID, message.Sender); err != nil {
+					s.logger.Error("Failed to send error message", "error", err)
+				}
+			}
+			return fmt.Errorf("failed to generate response: %w", err)
+		}
 
-	// Send response back to WhatsApp as a reply to the original message
-	if err := s.whatsapp.SendReply(ctx, message.GroupJID, response.Content, message.ID, message.Sender); err != nil {
-		s.logger.Error("Failed to send message", "error", err)
-		return fmt.Errorf("failed to send message: %w", err)
+		s.logger.Info("Generated response", "content", response.Content)
+
+		// Send response back to WhatsApp as a reply to the original message
+		if err := s.sendReply(ctx, message, response.Content, voiceReply); err != nil {
+			s.logger.Error("Failed to send message", "error", err)
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		responseContent = response.Content
 	}
 
 	// Save bot response
 	botMessage := &domain.Message{
 		ID:        fmt.Sprintf("bot-%d", message.Timestamp.Unix()),
 		GroupJID:  message.GroupJID,
+		ChatJID:   message.ChatJID,
+		IsDirect:  message.IsDirect,
 		Sender:    "bot",
-		Content:   response.Content,
+		Content:   responseContent,
 		Timestamp: message.Timestamp,
 		IsFromBot: true,
 	}
@@ -158,6 +298,449 @@ func (s *ChatService) ProcessMessage(ctx context.Context, message *domain.Messag
 	return nil
 }
 
+// allowRequest applies the rate limits to a message addressed to the bot, replying with a
+// cooldown or quota notice when it is refused
+func (s *ChatService) allowRequest(ctx context.Context, message *domain.Message) bool {
+	s.configMu.RLock()
+	limiter := s.limiter
+	s.configMu.RUnlock()
+
+	if limiter == nil {
+		return true
+	}
+
+	decision := limiter.Allow(message.Sender, message.ChatJID, message.IsDirect)
+	if decision.Allowed {
+		return true
+	}
+
+	if decision.Reply != "" {
+		if err := s.whatsapp.SendReply(ctx, message.ChatJID, decision.Reply, message.ID, message.Sender); err != nil {
+			s.logger.Error("Failed to send rate limit reply", "error", err)
+		}
+	}
+	return false
+}
+
+// queueJob marks the LLM requests for a message as replies to its chat and, if they wait
+// long in the LLM queue, tells the sender their place in line once
+func (s *ChatService) queueJob(ctx context.Context, message *domain.Message) context.Context {
+	s.configMu.RLock()
+	queue := s.queue
+	s.configMu.RUnlock()
+
+	var onWait func(position int)
+	if queue != nil {
+		var once sync.Once
+		onWait = func(position int) {
+			once.Do(func() {
+				notice := queue.Notice(position)
+				if notice == "" {
+					return
+				}
+				if err := s.whatsapp.SendReply(ctx, message.ChatJID, notice, message.ID, message.Sender); err != nil {
+					s.logger.Error("Failed to send queue notice", "error", err)
+				}
+			})
+		}
+	}
+	return withLLMJob(ctx, message.ChatJID, LLMPriorityInteractive, onWait)
+}
+
+// defaultPrompt returns the default system prompt for a group or direct chat
+func defaultPrompt(isDirect bool) string {
+	if isDirect {
+		return defaultDirectSystemPrompt
+	}
+	return defaultSystemPrompt
+}
+
+// generateErrorReply returns the reply to a failed generation: a busy notice when the LLM
+// queue is full, otherwise the technical error message. "" means stay silent.
+func (s *ChatService) generateErrorReply(err error) string {
+	s.configMu.RLock()
+	queue := s.queue
+	s.configMu.RUnlock()
+
+	if queue != nil && errors.Is(err, ErrLLMQueueFull) {
+		return queue.BusyNotice()
+	}
+	return technicalErrorMessage
+}
+
+// generate asks the LLM for a reply, running any tools it calls and feeding the
+// results back until it answers or the round limit is reached
+func (s *ChatService) generate(ctx context.Context, message *domain.Message, llmRequest *domain.LLMRequest) (*domain.LLMResponse, error) {
+	s.configMu.RLock()
+	tools := s.tools
+	maxRounds := s.maxToolRounds
+	s.configMu.RUnlock()
+
+	for round := 0; ; round++ {
+		// Out of rounds: withhold the tools so the model has to answer
+		if round >= maxRounds {
+			llmRequest.Tools = nil
+		}
+
+		response, err := s.llmProvider.Generate(ctx, llmRequest)
+		if err != nil {
+			return nil, err
+		}
+		if response.Error != nil {
+			return nil, response.Error
+		}
+
+		if len(response.ToolCalls) == 0 || len(llmRequest.Tools) == 0 {
+			return response, nil
+		}
+
+		llmRequest.Messages = append(llmRequest.Messages, domain.ChatMessage{
+			Role:      domain.ChatRoleAssistant,
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		})
+
+		for _, call := range response.ToolCalls {
+			s.logger.Info("Running tool", "tool", call.Name, "arguments", call.Arguments, "chat", message.ChatJID)
+
+			llmRequest.Messages = append(llmRequest.Messages, domain.ChatMessage{
+				Role:       domain.ChatRoleTool,
+				Content:    tools.Execute(ctx, call, message),
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// streamResponse generates a reply with a streaming provider, sending an initial reply
+// as soon as text arrives and editing it in place as further tokens stream in
+func (s *ChatService) streamResponse(ctx context.Context, message *domain.Message, llmRequest *domain.LLMRequest, streamer domain.StreamingLLMProvider) (string, error) {
+	s.configMu.RLock()
+	editInterval := s.streamInterval
+	s.configMu.RUnlock()
+
+	var (
+		buffer    strings.Builder
+		replyID   string
+		lastSent  string
+		lastEdit  time.Time
+		sendError error
+	)
+
+	onChunk := func(chunk string) error {
+		buffer.WriteString(chunk)
+		text := strings.TrimSpace(buffer.String())
+		if text == "" {
+			return nil
+		}
+
+		// Send the first chunk as a new reply
+		if replyID == "" {
+			id, err := s.whatsapp.SendReplyWithID(ctx, message.ChatJID, text+" …", message.ID, message.Sender)
+			if err != nil {
+				// Abort streaming, the final reply cannot be delivered anyway
+				sendError = err
+				return err
+			}
+			replyID = id
+			lastSent = text
+			lastEdit = time.Now()
+			return nil
+		}
+
+		// Throttle edits to avoid WhatsApp rate limits
+		if time.Since(lastEdit) < editInterval || text == lastSent {
+			return nil
+		}
+
+		if err := s.whatsapp.EditMessage(ctx, message.ChatJID, replyID, text+" …"); err != nil {
+			s.logger.Warn("Failed to edit streamed reply", "error", err)
+		}
+		lastSent = text
+		lastEdit = time.Now()
+		return nil
+	}
+
+	response, err := streamer.GenerateStream(ctx, llmRequest, onChunk)
+	if sendError != nil {
+		s.logger.Error("Failed to send streamed reply", "error", sendError)
+		return "", fmt.Errorf("failed to send message: %w", sendError)
+	}
+	if err == nil && response.Error != nil {
+		err = response.Error
+	}
+	if err != nil {
+		s.logger.Error("Failed to generate LLM response", "error", err)
+
+		// Replace the partial reply with a user-friendly error message, or send one
+		if replyID != "" {
+			if err := s.whatsapp.EditMessage(ctx, message.ChatJID, replyID, technicalErrorMessage); err != nil {
+				s.logger.Error("Failed to send error message", "error", err)
+			}
+		} else if reply := s.generateErrorReply(err); reply != "" {
+			if err := s.whatsapp.SendReply(ctx, message.ChatJID, reply, message.ID, message.Sender); err != nil {
+				s.logger.Error("Failed to send error message", "error", err)
+			}
+		}
+		return "", fmt.Errorf("failed to generate response: %w", err)
+	}
+
+	s.logger.Info("Generated streamed response", "content", response.Content)
+
+	// Nothing was streamed, fall back to a single reply
+	if replyID == "" {
+		if err := s.whatsapp.SendReply(ctx, message.ChatJID, response.Content, message.ID, message.Sender); err != nil {
+			s.logger.Error("Failed to send message", "error", err)
+			return "", fmt.Errorf("failed to send message: %w", err)
+		}
+		return response.Content, nil
+	}
+
+	// Final edit with the complete response
+	if err := s.whatsapp.EditMessage(ctx, message.ChatJID, replyID, response.Content); err != nil {
+		s.logger.Error("Failed to finalize streamed reply", "error", err)
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return response.Content, nil
+}
+
+// sendReply answers a message, as a voice note if requested. Text is the fallback
+// when speech synthesis fails, so the answer is never lost.
+func (s *ChatService) sendReply(ctx context.Context, message *domain.Message, content string, voice bool) error {
+	if voice {
+		err := s.sendVoiceReply(ctx, message, content)
+		if err == nil {
+			return nil
+		}
+		s.logger.Warn("Failed to send voice reply, sending text instead", "chat", message.ChatJID, "error", err)
+	}
+
+	return s.whatsapp.SendReply(ctx, message.ChatJID, content, message.ID, message.Sender)
+}
+
+// sendVoiceReply synthesizes the content and sends it as a voice note
+func (s *ChatService) sendVoiceReply(ctx context.Context, message *domain.Message, content string) error {
+	s.configMu.RLock()
+	synthesizer := s.synthesizer
+	s.configMu.RUnlock()
+
+	if synthesizer == nil {
+		return fmt.Errorf("speech synthesis is disabled")
+	}
+
+	audio, err := synthesizer.Synthesize(ctx, content)
+	if err != nil {
+		return fmt.Errorf("failed to synthesize speech: %w", err)
+	}
+
+	return s.whatsapp.SendAudio(ctx, message.ChatJID, audio.Data, audio.MimeType, message.ID, message.Sender)
+}
+
+// stripVoiceModifier removes a leading voice modifier, e.g. "@voice", from the message content.
+// It reports whether a voice reply was requested.
+func (s *ChatService) stripVoiceModifier(message *domain.Message) bool {
+	s.configMu.RLock()
+	modifier := s.voiceModifier
+	enabled := s.synthesizer != nil
+	s.configMu.RUnlock()
+
+	if !enabled || modifier == "" {
+		return false
+	}
+
+	trimmedContent := strings.TrimSpace(message.Content)
+	if !strings.HasPrefix(trimmedContent, modifier) {
+		return false
+	}
+
+	message.Content = strings.TrimSpace(strings.TrimPrefix(trimmedContent, modifier))
+	return true
+}
+
+// stripTriggerWord removes a leading trigger word from the message content
+func stripTriggerWord(message *domain.Message, triggerWords []string) (string, bool) {
+	trimmedContent := strings.TrimSpace(message.Content)
+	for _, trigger := range triggerWords {
+		if strings.HasPrefix(trimmedContent, trigger) {
+			message.Content = strings.TrimSpace(strings.TrimPrefix(trimmedContent, trigger))
+			return trigger, true
+		}
+	}
+	return "", false
+}
+
+// transcribeVoiceNote replaces the content of a voice note with its transcript.
+// It returns false if the voice note cannot be handled.
+func (s *ChatService) transcribeVoiceNote(ctx context.Context, message *domain.Message, triggerWords []string) bool {
+	s.configMu.RLock()
+	transcriber := s.transcriber
+	s.configMu.RUnlock()
+
+	if transcriber == nil {
+		s.logger.Debug("Ignoring voice note, transcription is disabled", "chat", message.ChatJID)
+		return false
+	}
+
+	transcript, err := transcriber.Transcribe(ctx, message.Media)
+	if err != nil {
+		s.logger.Error("Failed to transcribe voice note", "chat", message.ChatJID, "error", err)
+		return false
+	}
+	if transcript == "" {
+		s.logger.Debug("Voice note transcript is empty", "chat", message.ChatJID)
+		return false
+	}
+
+	message.Content = normalizeSpokenTrigger(transcript, triggerWords)
+	s.logger.Debug("Voice note transcribed", "chat", message.ChatJID, "content", message.Content)
+	return true
+}
+
+// normalizeSpokenTrigger rewrites a transcript that starts with a spoken trigger word,
+// e.g. "Sasi, what's up?" for "@sasi", so it matches like a typed trigger
+func normalizeSpokenTrigger(transcript string, triggerWords []string) string {
+	text := strings.TrimSpace(transcript)
+	for _, trigger := range triggerWords {
+		spoken := strings.TrimPrefix(trigger, "@")
+		if spoken == "" || len(text) < len(spoken) || !strings.EqualFold(text[:len(spoken)], spoken) {
+			continue
+		}
+
+		rest := text[len(spoken):]
+		if next, _ := utf8.DecodeRuneInString(rest); rest != "" && (unicode.IsLetter(next) || unicode.IsDigit(next)) {
+			// Only a prefix of a longer word
+			continue
+		}
+
+		return trigger + " " + strings.TrimLeft(rest, " ,.!?:;-")
+	}
+	return text
+}
+
+// isContactAllowed reports whether a contact JID is on the allowlist.
+// Entries may be full JIDs or bare phone numbers.
+func isContactAllowed(allowedContacts []string, chatJID string) bool {
+	user := strings.SplitN(chatJID, "@", 2)[0]
+	for _, allowed := range allowedContacts {
+		if allowed == chatJID || allowed == user {
+			return true
+		}
+	}
+	return false
+}
+
+// applySummary adds the group's conversation summary, if any, to the system prompt
+// and removes the history messages it covers
+func (s *ChatService) applySummary(ctx context.Context, groupJID, systemPrompt string, history []*domain.Message) (string, []*domain.Message) {
+	s.configMu.RLock()
+	summaries := s.summaries
+	s.configMu.RUnlock()
+
+	if summaries == nil {
+		return systemPrompt, history
+	}
+
+	summary, err := summaries.Get(ctx, groupJID)
+	if err != nil {
+		s.logger.Error("Failed to get conversation summary", "group", groupJID, "error", err)
+		return systemPrompt, history
+	}
+	if summary == nil || summary.Content == "" {
+		return systemPrompt, history
+	}
+
+	recent := make([]*domain.Message, 0, len(history))
+	for _, msg := range history {
+		if msg.Timestamp.After(summary.CoveredUntil) {
+			recent = append(recent, msg)
+		}
+	}
+
+	return systemPrompt + "\n\nSummary of the earlier conversation in this group:\n" + summary.Content, recent
+}
+
+// loadMedia downloads the photo sent with a message or, failing that, the media of the
+// message it quotes, reporting whether media was attached
+func (s *ChatService) loadMedia(ctx context.Context, message *domain.Message) bool {
+	if message.Media != nil {
+		return false
+	}
+
+	if message.LoadMedia != nil {
+		media, err := message.LoadMedia(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to download media", "message_id", message.ID, "error", err)
+		} else {
+			message.Media = media
+		}
+	}
+	if message.Media == nil && message.LoadQuotedMedia != nil {
+		media, err := message.LoadQuotedMedia(ctx)
+		if err != nil {
+			s.logger.Warn("Failed to download quoted media", "message_id", message.QuotedMessageID, "error", err)
+		} else {
+			message.Media = media
+		}
+	}
+	return message.Media != nil
+}
+
+// ingestDocument stores the document attached to a message, returning nil if it cannot be read
+func (s *ChatService) ingestDocument(ctx context.Context, message *domain.Message) *domain.Document {
+	s.configMu.RLock()
+	documents := s.documents
+	s.configMu.RUnlock()
+
+	if documents == nil {
+		return nil
+	}
+
+	document, err := documents.Ingest(ctx, message)
+	if err != nil {
+		s.logger.Warn("Failed to store document", "chat", message.ChatJID, "file", message.Media.FileName, "error", err)
+		return nil
+	}
+	return document
+}
+
+// applyDocuments adds excerpts of the chat's shared documents relevant to the message to the system prompt
+func (s *ChatService) applyDocuments(ctx context.Context, message *domain.Message, systemPrompt string, document *domain.Document) string {
+	s.configMu.RLock()
+	documents := s.documents
+	s.configMu.RUnlock()
+
+	if documents == nil {
+		return systemPrompt
+	}
+
+	excerpts := documents.Context(ctx, message.ChatJID, message.Content, document)
+	if excerpts == "" {
+		return systemPrompt
+	}
+
+	return systemPrompt + "\n\nExcerpts from documents shared in this chat that may help to answer:\n" + excerpts
+}
+
+// applyKnowledge adds the knowledge base passages relevant to the message to the system prompt
+func (s *ChatService) applyKnowledge(ctx context.Context, message *domain.Message, systemPrompt string) string {
+	s.configMu.RLock()
+	knowledge := s.knowledge
+	s.configMu.RUnlock()
+
+	if knowledge == nil {
+		return systemPrompt
+	}
+
+	passages := knowledge.Context(ctx, message.ChatJID, message.Content)
+	if passages == "" {
+		return systemPrompt
+	}
+
+	return systemPrompt + "\n\nRelevant entries from this group's knowledge base. " +
+		"Prefer them over your own knowledge, and say so if they do not answer the question:\n" + passages
+}
+
 // findMatchingWebhook finds a webhook config that matches the message content
 func (s *ChatService) findMatchingWebhook(content string) *domain.WebhookConfig {
 	trimmedContent := strings.TrimSpace(content)
@@ -191,62 +774,55 @@ func (s *ChatService) processWebhookMessage(ctx context.Context, message *domain
 		return fmt.Errorf("failed to save message: %w", err)
 	}
 
-	// Parse webhook timeout (default to 30s if not specified or invalid)
-	timeout := 30 * time.Second
-	if webhook.Timeout != "" {
-		if parsedTimeout, err := time.ParseDuration(webhook.Timeout); err == nil {
-			timeout = parsedTimeout
-			s.logger.Debug("Using webhook timeout", "timeout", timeout)
-		} else {
-			s.logger.Warn("Invalid webhook timeout, using default 30s", "timeout_config", webhook.Timeout, "error", err)
+	// Version 2 payloads include the recent conversation
+	var history []*domain.Message
+	if limit := webhookContextLimit(webhook); limit > 0 {
+		recent, err := s.repository.GetByChatJID(ctx, message.ChatJID, limit+1) // includes the message itself
+		if err != nil {
+			s.logger.Warn("Failed to get context for webhook", "error", err)
 		}
+		history = recent
 	}
+	request := newWebhookRequest(webhook, webhookSourceMessage, userMessage, message, history)
 
-	// Create context with timeout
-	webhookCtx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	// Async webhooks post their result to a callback later
+	if webhook.Async {
+		return s.callWebhookAsync(ctx, message, webhook, request)
+	}
 
-	// Call webhook with timeout context
-	response, err := s.webhookClient.Call(webhookCtx, webhook.URL, userMessage)
+	// The client times out each attempt with the webhook's timeout
+	response, err := s.webhookClient.Call(ctx, webhook, request)
 	if err != nil {
 		s.logger.Error("Failed to call webhook", "error", err, "url", webhook.URL)
 
 		// Send user-friendly error message as a reply
-		errorMsg := "Sorry, I cannot process this request right now due to a technical error. Please try again later."
-		if err := s.whatsapp.SendReply(ctx, message.GroupJID, errorMsg, message.ID, message.Sender); err != nil {
+		if err := s.whatsapp.SendReply(ctx, message.ChatJID, technicalErrorMessage, message.ID, message.Sender); err != nil {
 			s.logger.Error("Failed to send error message", "error", err)
 		}
 		return fmt.Errorf("failed to call webhook: %w", err)
 	}
 
-	s.logger.Info("Webhook response received", "type", response.ContentType)
+	s.logger.Info("Webhook response received", "type", response.ContentType, "attempts", response.Attempts)
 
-	// Send webhook response back to WhatsApp based on content type
-	var responseContent string
-	if response.ContentType == "image/jpeg" || response.ContentType == "image/png" {
-		// Send as image
-		s.logger.Info("Sending image response", "size", len(response.Content), "mime", response.ContentType)
-		if err := s.whatsapp.SendImage(ctx, message.GroupJID, response.Content, response.ContentType, "", message.ID, message.Sender); err != nil {
-			s.logger.Error("Failed to send image response", "error", err)
-			return fmt.Errorf("failed to send image: %w", err)
-		}
-		responseContent = "[Image sent]"
-	} else {
-		// Format text response for WhatsApp
-		formattedText := FormatWebhookResponse(response.TextContent)
-
-		// Send as text reply
-		if err := s.whatsapp.SendReply(ctx, message.GroupJID, formattedText, message.ID, message.Sender); err != nil {
-			s.logger.Error("Failed to send webhook response", "error", err)
-			return fmt.Errorf("failed to send message: %w", err)
-		}
-		responseContent = formattedText
+	// Send each part of the webhook response back to WhatsApp
+	target := webhookTarget{chatJID: message.ChatJID, messageID: message.ID, sender: message.Sender}
+	responseContent, err := sendWebhookResponse(ctx, s.whatsapp, s.logger, target, response, s.webhookAsk(message.ChatJID))
+	if err != nil {
+		s.logger.Error("Failed to send webhook response", "error", err)
+		return err
 	}
 
-	// Save bot response
+	s.saveWebhookReply(ctx, message, responseContent)
+	return nil
+}
+
+// saveWebhookReply saves the bot's answer to a webhook message
+func (s *ChatService) saveWebhookReply(ctx context.Context, message *domain.Message, responseContent string) {
 	botMessage := &domain.Message{
 		ID:        fmt.Sprintf("bot-%d", message.Timestamp.Unix()),
 		GroupJID:  message.GroupJID,
+		ChatJID:   message.ChatJID,
+		IsDirect:  message.IsDirect,
 		Sender:    "bot",
 		Content:   responseContent,
 		Timestamp: message.Timestamp,
@@ -256,8 +832,35 @@ func (s *ChatService) processWebhookMessage(ctx context.Context, message *domain
 	if err := s.repository.Save(ctx, botMessage); err != nil {
 		s.logger.Error("Failed to save bot message", "error", err)
 	}
+}
 
-	return nil
+// webhookAsk answers the "ask" parts of webhook responses in the chat's persona
+func (s *ChatService) webhookAsk(chatJID string) askFunc {
+	return func(ctx context.Context, prompt string) (string, error) {
+		s.configMu.RLock()
+		persona := s.personas[chatJID]
+		s.configMu.RUnlock()
+
+		systemPrompt := defaultPrompt(!strings.HasSuffix(chatJID, "@g.us"))
+		if persona.SystemPrompt != "" {
+			systemPrompt = persona.SystemPrompt
+		}
+
+		ctx = withLLMJob(ctx, chatJID, LLMPriorityInteractive, nil)
+		response, err := s.llmProvider.Generate(ctx, &domain.LLMRequest{
+			SystemPrompt: systemPrompt,
+			Messages:     []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: prompt}},
+			Model:        persona.Model,
+			Temperature:  persona.Temperature,
+		})
+		if err != nil {
+			return "", err
+		}
+		if response.Error != nil {
+			return "", response.Error
+		}
+		return response.Content, nil
+	}
 }
 
 // Start initializes the chat service
@@ -279,6 +882,9 @@ func (s *ChatService) UpdateWebhooks(webhooks []domain.WebhookConfig) {
 	defer s.configMu.Unlock()
 
 	s.webhookConfigs = webhooks
+	if s.tools != nil {
+		s.updateWebhookTools(s.tools)
+	}
 	s.logger.Info("Webhooks updated", "count", len(webhooks))
 }
 
@@ -290,3 +896,153 @@ func (s *ChatService) UpdateTriggerWords(triggerWords []string) {
 	s.triggerWords = triggerWords
 	s.logger.Info("Trigger words updated", "count", len(triggerWords))
 }
+
+// UpdatePersonas updates the per-group personas dynamically
+func (s *ChatService) UpdatePersonas(personas map[string]domain.GroupPersona) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.personas = personas
+	s.logger.Info("Group personas updated", "count", len(personas))
+}
+
+// UpdateContextBuilder replaces the builder used to fit history into the token budget
+func (s *ChatService) UpdateContextBuilder(builder *ContextBuilder) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.contextBuilder = builder
+	s.logger.Info("Context budget updated", "default_budget", builder.Budget(""))
+}
+
+// UpdateTools sets the tools the LLM may call; a nil registry disables tool calling
+func (s *ChatService) UpdateTools(registry *ToolRegistry, maxRounds int) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if maxRounds <= 0 {
+		maxRounds = defaultMaxToolRounds
+	}
+
+	s.tools = registry
+	s.maxToolRounds = maxRounds
+	if registry != nil {
+		s.updateWebhookTools(registry)
+	}
+	s.logger.Info("Tools updated", "enabled", registry != nil, "max_rounds", maxRounds)
+}
+
+// updateWebhookTools registers the webhooks marked as tools, logging those that were rejected
+func (s *ChatService) updateWebhookTools(registry *ToolRegistry) {
+	if err := registry.UpdateWebhooks(s.webhookConfigs, s.webhookClient); err != nil {
+		s.logger.Warn("Some webhook tools were not registered", "error", err)
+	}
+}
+
+// WebhookConfigs returns the current webhook configurations
+func (s *ChatService) WebhookConfigs() []domain.WebhookConfig {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return s.webhookConfigs
+}
+
+// UpdateDirectMessages updates the direct (1:1) chat settings dynamically
+func (s *ChatService) UpdateDirectMessages(cfg domain.DirectMessageConfig) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.directMessages = cfg
+	s.logger.Info("Direct messages updated",
+		"enabled", cfg.Enabled,
+		"allowed_contacts", len(cfg.AllowedContacts),
+		"require_trigger", cfg.RequireTrigger)
+}
+
+// UpdateVisionModel sets the model used for messages with images
+func (s *ChatService) UpdateVisionModel(model string) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.visionModel = model
+	s.logger.Info("Vision model updated", "model", model)
+}
+
+// UpdateTranscriber sets the speech-to-text backend for voice notes; nil disables them
+func (s *ChatService) UpdateTranscriber(transcriber domain.Transcriber) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.transcriber = transcriber
+	s.logger.Info("Voice note transcription updated", "enabled", transcriber != nil)
+}
+
+// UpdateSpeech sets the text-to-speech backend and the modifier that requests a voice reply;
+// a nil synthesizer disables voice replies
+func (s *ChatService) UpdateSpeech(synthesizer domain.SpeechSynthesizer, modifier string) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if modifier == "" {
+		modifier = defaultVoiceModifier
+	}
+
+	s.synthesizer = synthesizer
+	s.voiceModifier = modifier
+	s.logger.Info("Voice replies updated", "enabled", synthesizer != nil, "modifier", modifier)
+}
+
+// UpdateDocuments sets the service used for document question answering; nil disables it
+func (s *ChatService) UpdateDocuments(documents *DocumentService) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.documents = documents
+	s.logger.Info("Document question answering updated", "enabled", documents != nil)
+}
+
+// SetKnowledgeService enables grounding answers in the per-group knowledge base
+func (s *ChatService) SetKnowledgeService(knowledge *KnowledgeService) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.knowledge = knowledge
+}
+
+// SetRateLimiter sets the rate limiter applied to messages addressed to the bot
+func (s *ChatService) SetRateLimiter(limiter *RateLimiter) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.limiter = limiter
+}
+
+// SetLLMQueue enables telling senders their place in line while their reply waits in the LLM queue
+func (s *ChatService) SetLLMQueue(queue *LLMQueue) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.queue = queue
+}
+
+// SetSummaryRepository enables rolling conversation summaries in the prompt; nil disables them
+func (s *ChatService) SetSummaryRepository(summaries domain.SummaryRepository) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.summaries = summaries
+}
+
+// UpdateStreaming enables or disables progressive streamed replies dynamically
+func (s *ChatService) UpdateStreaming(enabled bool, editInterval time.Duration) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if editInterval <= 0 {
+		editInterval = defaultStreamEditInterval
+	}
+
+	s.streamEnabled = enabled
+	s.streamInterval = editInterval
+	s.logger.Info("Streaming updated", "enabled", enabled, "edit_interval", editInterval)
+}
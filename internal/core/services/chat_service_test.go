@@ -2,8 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,11 +15,13 @@ import (
 
 // MockLLMProvider is a mock implementation of LLMProvider
 type MockLLMProvider struct {
-	response string
-	err      error
+	response    string
+	err         error
+	lastRequest *domain.LLMRequest
 }
 
 func (m *MockLLMProvider) Generate(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	m.lastRequest = request
 	if m.err != nil {
 		return &domain.LLMResponse{Error: m.err}, m.err
 	}
@@ -27,6 +32,24 @@ func (m *MockLLMProvider) IsAvailable(ctx context.Context) bool {
 	return m.err == nil
 }
 
+// MockStreamingLLMProvider is a mock implementation of StreamingLLMProvider
+type MockStreamingLLMProvider struct {
+	MockLLMProvider
+	chunks        []string
+	responseError error // reported in the response rather than returned
+}
+
+func (m *MockStreamingLLMProvider) GenerateStream(ctx context.Context, request *domain.LLMRequest, onChunk func(chunk string) error) (*domain.LLMResponse, error) {
+	var content string
+	for _, chunk := range m.chunks {
+		content += chunk
+		if err := onChunk(chunk); err != nil {
+			return &domain.LLMResponse{Error: err}, err
+		}
+	}
+	return &domain.LLMResponse{Content: content, Error: m.responseError}, nil
+}
+
 // MockMessageRepository is a mock implementation of MessageRepository
 type MockMessageRepository struct {
 	messages []*domain.Message
@@ -37,10 +60,10 @@ func (m *MockMessageRepository) Save(ctx context.Context, message *domain.Messag
 	return nil
 }
 
-func (m *MockMessageRepository) GetByGroupJID(ctx context.Context, groupJID string, limit int) ([]*domain.Message, error) {
+func (m *MockMessageRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Message, error) {
 	var result []*domain.Message
 	for _, msg := range m.messages {
-		if msg.GroupJID == groupJID {
+		if msg.ChatJID == chatJID || (msg.ChatJID == "" && msg.GroupJID == chatJID) {
 			result = append(result, msg)
 		}
 	}
@@ -53,7 +76,13 @@ func (m *MockMessageRepository) GetAll(ctx context.Context) ([]*domain.Message,
 
 // MockWhatsAppClient is a mock implementation of WhatsAppClient
 type MockWhatsAppClient struct {
-	sentMessages []string
+	sentMessages   []string
+	editedMessages []string
+	sentAudio      [][]byte
+	sentMedia      []*domain.Media
+	mentions       [][]string
+	reactions      []string
+	replyErr       error // returned by SendReply
 }
 
 func (m *MockWhatsAppClient) Start(ctx context.Context) error { return nil }
@@ -64,16 +93,68 @@ func (m *MockWhatsAppClient) SendMessage(ctx context.Context, groupJID, message
 	return nil
 }
 
+func (m *MockWhatsAppClient) SendReply(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) error {
+	if m.replyErr != nil {
+		return m.replyErr
+	}
+	m.sentMessages = append(m.sentMessages, message)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendReplyWithID(ctx context.Context, groupJID, message, replyToMessageID, quotedSender string) (string, error) {
+	m.sentMessages = append(m.sentMessages, message)
+	return "sent-1", nil
+}
+
+func (m *MockWhatsAppClient) EditMessage(ctx context.Context, groupJID, messageID, message string) error {
+	m.editedMessages = append(m.editedMessages, message)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendImage(ctx context.Context, groupJID string, imageData []byte, mimeType, caption, replyToMessageID, quotedSender string) error {
+	m.sentMessages = append(m.sentMessages, caption)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendAudio(ctx context.Context, groupJID string, audioData []byte, mimeType, replyToMessageID, quotedSender string) error {
+	m.sentAudio = append(m.sentAudio, audioData)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendMedia(ctx context.Context, groupJID string, media *domain.Media, caption, replyToMessageID, quotedSender string) error {
+	m.sentMedia = append(m.sentMedia, media)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendMentions(ctx context.Context, groupJID, message string, mentions []string, replyToMessageID, quotedSender string) error {
+	m.sentMessages = append(m.sentMessages, message)
+	m.mentions = append(m.mentions, mentions)
+	return nil
+}
+
+func (m *MockWhatsAppClient) SendReaction(ctx context.Context, groupJID, messageID, sender, emoji string) error {
+	m.reactions = append(m.reactions, emoji)
+	return nil
+}
+
 func (m *MockWhatsAppClient) GetGroups(ctx context.Context) ([]*domain.Group, error) {
 	return nil, nil
 }
 
+func (m *MockWhatsAppClient) GetGroupParticipants(ctx context.Context, groupJID string) ([]*domain.GroupParticipant, error) {
+	return nil, nil
+}
+
 func (m *MockWhatsAppClient) GetAuthStatus(ctx context.Context) (*domain.AuthStatus, error) {
 	return &domain.AuthStatus{IsAuthenticated: true}, nil
 }
 
 func (m *MockWhatsAppClient) OnMessage(handler func(*domain.Message)) {}
 
+func (m *MockWhatsAppClient) OnPresence(handler func(*domain.PresenceEvent)) {}
+
+func (m *MockWhatsAppClient) SubscribeToPresence(jid string) error { return nil }
+
 // MockGroupManager is a mock implementation of GroupManager
 type MockGroupManager struct {
 	allowedGroups map[string]bool
@@ -115,18 +196,48 @@ func (m *MockGroupManager) SyncWithConfig() error {
 
 // MockWebhookClient is a mock implementation of WebhookClient
 type MockWebhookClient struct {
-	response string
-	err      error
+	response    string
+	media       *domain.Media
+	parts       []domain.WebhookResponsePart
+	err         error
+	lastRequest *domain.WebhookRequest
+	onCall      func(*domain.WebhookRequest)
 }
 
-func (m *MockWebhookClient) Call(ctx context.Context, url string, message string) (string, error) {
+func (m *MockWebhookClient) Call(ctx context.Context, webhook *domain.WebhookConfig, request *domain.WebhookRequest) (*domain.WebhookResponse, error) {
+	m.lastRequest = request
+	if m.onCall != nil {
+		m.onCall(request)
+	}
 	if m.err != nil {
-		return "", m.err
+		return nil, m.err
+	}
+	if m.media != nil {
+		return &domain.WebhookResponse{ContentType: m.media.MimeType, Content: m.media.Data, Media: m.media}, nil
+	}
+	if m.parts != nil {
+		return &domain.WebhookResponse{ContentType: "multipart", Parts: m.parts}, nil
 	}
+	text := "webhook response"
 	if m.response != "" {
-		return m.response, nil
+		text = m.response
+	}
+	return &domain.WebhookResponse{ContentType: "text", Content: []byte(text), TextContent: text}, nil
+}
+
+func (m *MockWebhookClient) ParseResponse(ctx context.Context, contentType, contentDisposition string, body []byte) (*domain.WebhookResponse, error) {
+	if len(body) == 0 {
+		return nil, errors.New("empty result")
+	}
+	return &domain.WebhookResponse{ContentType: "text", Content: body, TextContent: string(body)}, nil
+}
+
+// VerifySignature accepts the signature "sha256=valid"
+func (m *MockWebhookClient) VerifySignature(webhook *domain.WebhookConfig, timestamp, signature string, body []byte) error {
+	if webhook.Secret == "" || signature != "sha256=valid" {
+		return errors.New("signature mismatch")
 	}
-	return "webhook response", nil
+	return nil
 }
 
 func TestChatService_ProcessMessage(t *testing.T) {
@@ -201,3 +312,648 @@ func TestChatService_ProcessMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestChatService_ProcessMessage_Streaming(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llmProvider := &MockStreamingLLMProvider{chunks: []string{"Hello", " there", "!"}}
+	repository := &MockMessageRepository{}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+
+	service := NewChatService(llmProvider, repository, whatsapp, groupMgr, &MockWebhookClient{}, []string{}, []domain.WebhookConfig{}, logger)
+	service.UpdateStreaming(true, time.Hour)
+
+	err := service.ProcessMessage(context.Background(), &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "test-group@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "Hi",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(whatsapp.sentMessages) != 1 {
+		t.Fatalf("Expected 1 initial reply, got %d", len(whatsapp.sentMessages))
+	}
+	if whatsapp.sentMessages[0] != "Hello …" {
+		t.Errorf("Expected initial reply %q, got %q", "Hello …", whatsapp.sentMessages[0])
+	}
+
+	// Intermediate edits are throttled, so only the final edit is sent
+	if len(whatsapp.editedMessages) != 1 {
+		t.Fatalf("Expected 1 edit, got %d", len(whatsapp.editedMessages))
+	}
+	if whatsapp.editedMessages[0] != "Hello there!" {
+		t.Errorf("Expected final edit %q, got %q", "Hello there!", whatsapp.editedMessages[0])
+	}
+}
+
+func TestChatService_ProcessMessage_StreamingResponseError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llmProvider := &MockStreamingLLMProvider{responseError: errors.New("model crashed")}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+
+	service := NewChatService(llmProvider, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{}, []domain.WebhookConfig{}, logger)
+	service.UpdateStreaming(true, time.Hour)
+
+	err := service.ProcessMessage(context.Background(), &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "test-group@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "Hi",
+		Timestamp: time.Now(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "model crashed") {
+		t.Fatalf("Expected the response error, got %v", err)
+	}
+	if len(whatsapp.sentMessages) != 1 || whatsapp.sentMessages[0] != technicalErrorMessage {
+		t.Errorf("Expected the error reply, got %v", whatsapp.sentMessages)
+	}
+}
+
+func TestChatService_ProcessMessage_GroupPersona(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llmProvider := &MockLLMProvider{response: "Ahoy!"}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"family@g.us": true, "eng@g.us": true}}
+	service := NewChatService(llmProvider, &MockMessageRepository{}, &MockWhatsAppClient{}, groupMgr, &MockWebhookClient{}, []string{}, []domain.WebhookConfig{}, logger)
+
+	temperature := 1.2
+	service.UpdatePersonas(map[string]domain.GroupPersona{
+		"family@g.us": {SystemPrompt: "You are a pirate.", Model: "llama3", Temperature: &temperature},
+	})
+
+	ctx := context.Background()
+	if err := service.ProcessMessage(ctx, &domain.Message{ID: "1", GroupJID: "family@g.us", Content: "Hi", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	req := llmProvider.lastRequest
+	if len(req.Messages) != 1 || req.Messages[0].Role != domain.ChatRoleUser || req.Messages[0].Content != "Hi" {
+		t.Errorf("Expected the triggering message as the only chat message, got %+v", req.Messages)
+	}
+	if req.SystemPrompt != "You are a pirate." || req.Model != "llama3" || req.Temperature == nil || *req.Temperature != 1.2 {
+		t.Errorf("Expected family persona to be applied, got %+v", req)
+	}
+
+	if err := service.ProcessMessage(ctx, &domain.Message{ID: "2", GroupJID: "eng@g.us", Content: "Hi", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	req = llmProvider.lastRequest
+	if req.SystemPrompt != defaultSystemPrompt || req.Model != "" || req.Temperature != nil {
+		t.Errorf("Expected no persona overrides for eng group, got %+v", req)
+	}
+}
+
+// MockToolCallingLLMProvider returns scripted responses in order
+type MockToolCallingLLMProvider struct {
+	responses []*domain.LLMResponse
+	requests  []domain.LLMRequest
+}
+
+func (m *MockToolCallingLLMProvider) Generate(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	m.requests = append(m.requests, *request)
+	response := m.responses[0]
+	if len(m.responses) > 1 {
+		m.responses = m.responses[1:]
+	}
+	return response, nil
+}
+
+func (m *MockToolCallingLLMProvider) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+func TestChatService_ProcessMessage_ToolCalls(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llm := &MockToolCallingLLMProvider{responses: []*domain.LLMResponse{
+		{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "get_server_time", Arguments: "{}"}}},
+		{Content: "It is late."},
+	}}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"group1@g.us": true}}
+
+	service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, logger)
+	registry := NewToolRegistry()
+	registry.Register(&ServerTimeTool{})
+	service.UpdateTools(registry, 3)
+
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "group1@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@bot What time is it?",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(llm.requests) != 2 {
+		t.Fatalf("Expected 2 LLM calls, got %d", len(llm.requests))
+	}
+	if len(llm.requests[0].Tools) != 1 || llm.requests[0].Tools[0].Name != "get_server_time" {
+		t.Errorf("Expected get_server_time tool, got %+v", llm.requests[0].Tools)
+	}
+
+	followUp := llm.requests[1].Messages
+	last := followUp[len(followUp)-1]
+	if last.Role != domain.ChatRoleTool || last.ToolCallID != "call_1" || last.Content == "" {
+		t.Errorf("Expected tool result as last message, got %+v", last)
+	}
+
+	if len(whatsapp.sentMessages) != 1 || whatsapp.sentMessages[0] != "It is late." {
+		t.Errorf("Expected final answer to be sent, got %v", whatsapp.sentMessages)
+	}
+}
+
+func TestToolRegistry_UpdateWebhooksRejectsDuplicates(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&ServerTimeTool{})
+
+	err := registry.UpdateWebhooks([]domain.WebhookConfig{
+		{SubTrigger: "get_server_time", Tool: true},
+		{SubTrigger: "weather", Tool: true},
+		{SubTrigger: "wea ther", Tool: true},
+	}, &MockWebhookClient{})
+	if err == nil {
+		t.Fatal("Expected duplicate tool names to be rejected")
+	}
+
+	definitions := registry.Definitions()
+	if len(definitions) != 2 || definitions[0].Name != "get_server_time" || definitions[1].Name != "weather" {
+		t.Errorf("Expected the built-in and the first weather tool, got %+v", definitions)
+	}
+	if result := registry.Execute(context.Background(), domain.ToolCall{Name: "get_server_time"}, &domain.Message{}); strings.HasPrefix(result, "Error") {
+		t.Errorf("Expected the built-in tool to run, got %q", result)
+	}
+}
+
+func TestChatService_ProcessMessage_ToolRoundLimit(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	// The model keeps asking for tools; the loop must stop and force an answer
+	llm := &MockToolCallingLLMProvider{responses: []*domain.LLMResponse{
+		{Content: "Checking", ToolCalls: []domain.ToolCall{{ID: "call", Name: "get_server_time"}}},
+	}}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"group1@g.us": true}}
+
+	service := NewChatService(llm, &MockMessageRepository{}, &MockWhatsAppClient{}, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, logger)
+	registry := NewToolRegistry()
+	registry.Register(&ServerTimeTool{})
+	service.UpdateTools(registry, 2)
+
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "group1@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@bot Loop forever",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(llm.requests) != 3 {
+		t.Fatalf("Expected 3 LLM calls, got %d", len(llm.requests))
+	}
+	if len(llm.requests[2].Tools) != 0 {
+		t.Error("Expected tools to be withheld on the final call")
+	}
+}
+
+func TestChatService_ProcessMessage_DirectMessage(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	contactJID := "491701234567@s.whatsapp.net"
+
+	newDirectMessage := func(id, content string) *domain.Message {
+		return &domain.Message{
+			ID:        id,
+			ChatJID:   contactJID,
+			IsDirect:  true,
+			Sender:    contactJID,
+			Content:   content,
+			Timestamp: time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		config    domain.DirectMessageConfig
+		content   string
+		wantReply bool
+	}{
+		{"disabled", domain.DirectMessageConfig{AllowedContacts: []string{contactJID}}, "Hello", false},
+		{"not allowed", domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{"123@s.whatsapp.net"}}, "Hello", false},
+		{"trigger-less", domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{contactJID}}, "Hello", true},
+		{"bare number allowed", domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{"491701234567"}}, "Hello", true},
+		{"trigger required", domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{contactJID}, RequireTrigger: true}, "Hello", false},
+		{"trigger given", domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{contactJID}, RequireTrigger: true}, "@bot Hello", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockMessageRepository{}
+			whatsapp := &MockWhatsAppClient{}
+			llm := &MockLLMProvider{response: "Hi"}
+			groupMgr := &MockGroupManager{allowedGroups: map[string]bool{}}
+
+			service := NewChatService(llm, repo, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, logger)
+			service.UpdateDirectMessages(tt.config)
+
+			if err := service.ProcessMessage(ctx, newDirectMessage("msg1", tt.content)); err != nil {
+				t.Fatalf("ProcessMessage() error = %v", err)
+			}
+
+			if got := len(whatsapp.sentMessages) == 1; got != tt.wantReply {
+				t.Fatalf("Expected reply = %v, got messages %v", tt.wantReply, whatsapp.sentMessages)
+			}
+			if !tt.wantReply {
+				return
+			}
+
+			if llm.lastRequest.Messages[0].Content != "Hello" {
+				t.Errorf("Expected trigger word stripped, got %q", llm.lastRequest.Messages[0].Content)
+			}
+			if llm.lastRequest.SystemPrompt != defaultDirectSystemPrompt {
+				t.Errorf("Expected the direct message prompt, got %q", llm.lastRequest.SystemPrompt)
+			}
+
+			// History is kept under the contact's chat JID
+			history, _ := repo.GetByChatJID(ctx, contactJID, 10)
+			if len(history) != 2 || !history[1].IsFromBot || history[1].ChatJID != contactJID {
+				t.Errorf("Expected message and reply stored under chat JID, got %+v", history)
+			}
+		})
+	}
+}
+
+// MockTranscriber is a mock implementation of Transcriber
+type MockTranscriber struct {
+	transcript string
+	err        error
+}
+
+func (m *MockTranscriber) Transcribe(ctx context.Context, audio *domain.Media) (string, error) {
+	return m.transcript, m.err
+}
+
+func TestChatService_ProcessMessage_QuotedMedia(t *testing.T) {
+	ctx := context.Background()
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	llm := &MockLLMProvider{response: "A cat"}
+	service := NewChatService(llm, &MockMessageRepository{}, &MockWhatsAppClient{}, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	service.UpdateVisionModel("llava")
+
+	downloads := 0
+	reply := func(content string) *domain.Message {
+		return &domain.Message{
+			ID:              "msg1",
+			GroupJID:        "test-group@g.us",
+			Sender:          "user@s.whatsapp.net",
+			Content:         content,
+			Timestamp:       time.Now(),
+			QuotedMessageID: "photo1",
+			LoadQuotedMedia: func(ctx context.Context) (*domain.Media, error) {
+				downloads++
+				return &domain.Media{Type: domain.MediaTypeImage, MimeType: "image/jpeg", Data: []byte("jpeg")}, nil
+			},
+		}
+	}
+
+	// Replies among users never download the photo
+	if err := service.ProcessMessage(ctx, reply("nice one")); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if downloads != 0 {
+		t.Fatalf("Expected no download for a message not addressed to the bot, got %d", downloads)
+	}
+
+	if err := service.ProcessMessage(ctx, reply("@bot what is this?")); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if downloads != 1 || llm.lastRequest == nil || llm.lastRequest.Model != "llava" {
+		t.Errorf("Expected the quoted photo to be downloaded and sent to the vision model, got %d downloads", downloads)
+	}
+}
+
+func TestChatService_ProcessMessage_PhotoLoadedLazily(t *testing.T) {
+	ctx := context.Background()
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	llm := &MockLLMProvider{response: "A dog"}
+	service := NewChatService(llm, &MockMessageRepository{}, &MockWhatsAppClient{}, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	service.UpdateVisionModel("llava")
+
+	downloads := 0
+	photo := func(caption string) *domain.Message {
+		return &domain.Message{
+			ID:        "photo1",
+			GroupJID:  "test-group@g.us",
+			Sender:    "user@s.whatsapp.net",
+			Content:   caption,
+			Timestamp: time.Now(),
+			LoadMedia: func(ctx context.Context) (*domain.Media, error) {
+				downloads++
+				return &domain.Media{Type: domain.MediaTypeImage, MimeType: "image/jpeg", Data: []byte("jpeg")}, nil
+			},
+		}
+	}
+
+	if err := service.ProcessMessage(ctx, photo("our holiday")); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if downloads != 0 {
+		t.Fatalf("Expected no download for a photo not addressed to the bot, got %d", downloads)
+	}
+
+	if err := service.ProcessMessage(ctx, photo("@bot which breed is this?")); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if downloads != 1 || llm.lastRequest == nil || llm.lastRequest.Model != "llava" {
+		t.Errorf("Expected the photo to be downloaded and sent to the vision model, got %d downloads", downloads)
+	}
+}
+
+func TestChatService_ProcessMessage_VoiceNote(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	tests := []struct {
+		name        string
+		transcriber domain.Transcriber
+		wantContent string
+	}{
+		{"spoken trigger", &MockTranscriber{transcript: " Sasi, what's the weather?"}, "what's the weather?"},
+		{"no trigger", &MockTranscriber{transcript: "Dinner is ready"}, ""},
+		{"longer word", &MockTranscriber{transcript: "Sasikala called"}, ""},
+		{"disabled", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &MockLLMProvider{response: "Sunny"}
+			whatsapp := &MockWhatsAppClient{}
+			groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"family@g.us": true}}
+
+			service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@sasi"}, nil, logger)
+			service.UpdateTranscriber(tt.transcriber)
+
+			err := service.ProcessMessage(context.Background(), &domain.Message{
+				ID:        "msg1",
+				GroupJID:  "family@g.us",
+				Sender:    "user@s.whatsapp.net",
+				Media:     &domain.Media{Type: domain.MediaTypeAudio, MimeType: "audio/ogg; codecs=opus", Data: []byte("opus")},
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("ProcessMessage() error = %v", err)
+			}
+
+			if tt.wantContent == "" {
+				if len(whatsapp.sentMessages) != 0 {
+					t.Errorf("Expected no reply, got %v", whatsapp.sentMessages)
+				}
+				return
+			}
+
+			if len(whatsapp.sentMessages) != 1 {
+				t.Fatalf("Expected 1 reply, got %d", len(whatsapp.sentMessages))
+			}
+			if got := llm.lastRequest.Messages[0].Content; got != tt.wantContent {
+				t.Errorf("Expected transcript %q, got %q", tt.wantContent, got)
+			}
+			if len(llm.lastRequest.Messages[0].Images) != 0 {
+				t.Error("Expected audio not to be sent as an image")
+			}
+		})
+	}
+}
+
+// MockSpeechSynthesizer is a mock implementation of SpeechSynthesizer
+type MockSpeechSynthesizer struct {
+	err error
+}
+
+func (m *MockSpeechSynthesizer) Synthesize(ctx context.Context, text string) (*domain.Media, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &domain.Media{Type: domain.MediaTypeAudio, MimeType: "audio/ogg; codecs=opus", Data: []byte("voice:" + text)}, nil
+}
+
+func TestChatService_ProcessMessage_VoiceReply(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	tests := []struct {
+		name        string
+		synthesizer domain.SpeechSynthesizer
+		persona     domain.GroupPersona
+		content     string
+		wantPrompt  string
+		wantAudio   bool
+	}{
+		{"modifier", &MockSpeechSynthesizer{}, domain.GroupPersona{}, "@sasi @voice tell a joke", "tell a joke", true},
+		{"group setting", &MockSpeechSynthesizer{}, domain.GroupPersona{VoiceReplies: true}, "@sasi tell a joke", "tell a joke", true},
+		{"text by default", &MockSpeechSynthesizer{}, domain.GroupPersona{}, "@sasi tell a joke", "tell a joke", false},
+		{"disabled", nil, domain.GroupPersona{VoiceReplies: true}, "@sasi @voice tell a joke", "@voice tell a joke", false},
+		{"synthesis fails", &MockSpeechSynthesizer{err: errors.New("server down")}, domain.GroupPersona{}, "@sasi @voice tell a joke", "tell a joke", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &MockLLMProvider{response: "Why did the chicken cross the road?"}
+			whatsapp := &MockWhatsAppClient{}
+			groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"family@g.us": true}}
+
+			service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@sasi"}, nil, logger)
+			service.UpdatePersonas(map[string]domain.GroupPersona{"family@g.us": tt.persona})
+			service.UpdateSpeech(tt.synthesizer, "")
+
+			err := service.ProcessMessage(context.Background(), &domain.Message{
+				ID:        "msg1",
+				GroupJID:  "family@g.us",
+				Sender:    "user@s.whatsapp.net",
+				Content:   tt.content,
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatalf("ProcessMessage() error = %v", err)
+			}
+
+			if got := llm.lastRequest.Messages[0].Content; got != tt.wantPrompt {
+				t.Errorf("Expected prompt %q, got %q", tt.wantPrompt, got)
+			}
+
+			if tt.wantAudio {
+				if len(whatsapp.sentAudio) != 1 || len(whatsapp.sentMessages) != 0 {
+					t.Fatalf("Expected only a voice reply, got audio %d, text %v", len(whatsapp.sentAudio), whatsapp.sentMessages)
+				}
+				if string(whatsapp.sentAudio[0]) != "voice:Why did the chicken cross the road?" {
+					t.Errorf("Unexpected audio %q", whatsapp.sentAudio[0])
+				}
+			} else if len(whatsapp.sentAudio) != 0 || len(whatsapp.sentMessages) != 1 {
+				t.Fatalf("Expected only a text reply, got audio %d, text %v", len(whatsapp.sentAudio), whatsapp.sentMessages)
+			}
+		})
+	}
+}
+
+func TestChatService_ProcessMessage_WebhookMedia(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	whatsapp := &MockWhatsAppClient{}
+	repository := &MockMessageRepository{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	webhookClient := &MockWebhookClient{media: &domain.Media{
+		Type:     domain.MediaTypeDocument,
+		MimeType: "application/pdf",
+		Data:     []byte("%PDF-1.4"),
+		FileName: "report.pdf",
+	}}
+	webhooks := []domain.WebhookConfig{{SubTrigger: "#report", URL: "http://n8n/webhook/report"}}
+
+	service := NewChatService(&MockLLMProvider{}, repository, whatsapp, groupMgr, webhookClient, []string{"@sasi"}, webhooks, logger)
+
+	err := service.ProcessMessage(context.Background(), &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "test-group@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@sasi #report weekly",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(whatsapp.sentMedia) != 1 || whatsapp.sentMedia[0].FileName != "report.pdf" {
+		t.Fatalf("Expected the PDF to be sent as media, got %+v", whatsapp.sentMedia)
+	}
+	if len(whatsapp.sentMessages) != 0 {
+		t.Errorf("Expected no text reply, got %v", whatsapp.sentMessages)
+	}
+
+	last := repository.messages[len(repository.messages)-1]
+	if last.Content != "[Document sent: report.pdf]" {
+		t.Errorf("Expected media label in history, got %q", last.Content)
+	}
+}
+
+func TestChatService_ProcessMessage_WebhookParts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llm := &MockLLMProvider{response: "Keep the promotion running."}
+	whatsapp := &MockWhatsAppClient{}
+	repository := &MockMessageRepository{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	chart := &domain.Media{Type: domain.MediaTypeImage, MimeType: "image/png", Data: []byte("png")}
+	webhookClient := &MockWebhookClient{parts: []domain.WebhookResponsePart{
+		{Type: domain.WebhookPartMedia, Media: chart, Text: "Sales this week"},
+		{Type: domain.WebhookPartText, Text: "Up 12% thanks to @491701234567", Mentions: []string{"491701234567@s.whatsapp.net"}},
+		{Type: domain.WebhookPartReaction, Emoji: "📈"},
+		{Type: domain.WebhookPartAsk, Text: "Suggest one way to keep the trend going"},
+	}}
+	webhooks := []domain.WebhookConfig{{SubTrigger: "#sales", URL: "http://n8n/webhook/sales"}}
+
+	service := NewChatService(llm, repository, whatsapp, groupMgr, webhookClient, []string{"@sasi"}, webhooks, logger)
+
+	err := service.ProcessMessage(context.Background(), &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "test-group@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@sasi #sales",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(whatsapp.sentMedia) != 1 || whatsapp.sentMedia[0] != chart {
+		t.Fatalf("Expected the chart to be sent, got %+v", whatsapp.sentMedia)
+	}
+	wantMessages := []string{"Up 12% thanks to @491701234567", "Keep the promotion running."}
+	if len(whatsapp.sentMessages) != len(wantMessages) {
+		t.Fatalf("Expected messages %v, got %v", wantMessages, whatsapp.sentMessages)
+	}
+	for i, want := range wantMessages {
+		if whatsapp.sentMessages[i] != want {
+			t.Errorf("Message %d = %q, want %q", i, whatsapp.sentMessages[i], want)
+		}
+	}
+	if len(whatsapp.mentions) != 1 || whatsapp.mentions[0][0] != "491701234567@s.whatsapp.net" {
+		t.Errorf("Expected one mention, got %v", whatsapp.mentions)
+	}
+	if len(whatsapp.reactions) != 1 || whatsapp.reactions[0] != "📈" {
+		t.Errorf("Expected a reaction, got %v", whatsapp.reactions)
+	}
+	if llm.lastRequest == nil || llm.lastRequest.Messages[0].Content != "Suggest one way to keep the trend going" {
+		t.Errorf("Expected the ask prompt to be sent to the LLM, got %+v", llm.lastRequest)
+	}
+
+	last := repository.messages[len(repository.messages)-1]
+	if !strings.HasPrefix(last.Content, "[Image sent] Sales this week\n") {
+		t.Errorf("Expected all parts in history, got %q", last.Content)
+	}
+}
+
+func TestChatService_ProcessMessage_WebhookPayload(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	for _, version := range []int{0, domain.WebhookPayloadV2} {
+		t.Run(fmt.Sprintf("version %d", version), func(t *testing.T) {
+			repository := &MockMessageRepository{messages: []*domain.Message{
+				{ID: "old", ChatJID: "test-group@g.us", Sender: "a@s.whatsapp.net", Content: "Anyone up for lunch?"},
+			}}
+			groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+			webhookClient := &MockWebhookClient{}
+			webhooks := []domain.WebhookConfig{{SubTrigger: "#food", URL: "http://n8n/webhook/food", PayloadVersion: version}}
+
+			service := NewChatService(&MockLLMProvider{}, repository, &MockWhatsAppClient{}, groupMgr, webhookClient, []string{"@sasi"}, webhooks, logger)
+
+			err := service.ProcessMessage(context.Background(), &domain.Message{
+				ID:              "msg1",
+				GroupJID:        "test-group@g.us",
+				GroupName:       "Lunch crew",
+				Sender:          "b@s.whatsapp.net",
+				SenderName:      "Bea",
+				Content:         "@sasi #food pizza nearby",
+				Timestamp:       time.Now(),
+				QuotedMessageID: "old",
+				QuotedSender:    "a@s.whatsapp.net",
+				QuotedContent:   "Anyone up for lunch?",
+			})
+			if err != nil {
+				t.Fatalf("ProcessMessage() error = %v", err)
+			}
+
+			request := webhookClient.lastRequest
+			if request.Message != "pizza nearby" || request.Source != "message" {
+				t.Fatalf("Unexpected request %+v", request)
+			}
+			if version < domain.WebhookPayloadV2 {
+				if request.Version != domain.WebhookPayloadV1 || len(request.Context) != 0 {
+					t.Errorf("Expected a version 1 request without context, got %+v", request)
+				}
+				return
+			}
+
+			if request.Version != domain.WebhookPayloadV2 || request.GroupName != "Lunch crew" || request.SenderName != "Bea" || request.MessageID != "msg1" {
+				t.Errorf("Expected message metadata, got %+v", request)
+			}
+			if request.QuotedMessage == nil || request.QuotedMessage.Content != "Anyone up for lunch?" {
+				t.Errorf("Expected the quoted message, got %+v", request.QuotedMessage)
+			}
+			if len(request.Context) != 1 || request.Context[0].Content != "Anyone up for lunch?" {
+				t.Errorf("Expected the earlier message as context, got %+v", request.Context)
+			}
+		})
+	}
+}
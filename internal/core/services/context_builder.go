@@ -0,0 +1,147 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// defaultContextTokens is the token budget used when none is configured
+	defaultContextTokens = 2048
+
+	// defaultMaxMessageTokens caps a single history message when none is configured
+	defaultMaxMessageTokens = 512
+
+	// charsPerToken is a rough average for English text with common BPE tokenizers
+	charsPerToken = 4
+
+	// truncationMarker is appended to messages that were shortened to fit the budget
+	truncationMarker = " […]"
+)
+
+// ContextBuilder selects the conversation history sent to the LLM so that it
+// fits within a per-model token budget
+type ContextBuilder struct {
+	defaultModel     string
+	defaultBudget    int
+	modelBudgets     map[string]int
+	maxMessageTokens int
+}
+
+// NewContextBuilder creates a new context builder. defaultModel is the provider's
+// configured model, used to look up modelBudgets when a request does not override it.
+// Zero values fall back to sensible defaults.
+func NewContextBuilder(defaultModel string, defaultBudget int, modelBudgets map[string]int, maxMessageTokens int) *ContextBuilder {
+	if defaultBudget <= 0 {
+		defaultBudget = defaultContextTokens
+	}
+	if maxMessageTokens <= 0 {
+		maxMessageTokens = defaultMaxMessageTokens
+	}
+
+	return &ContextBuilder{
+		defaultModel:     defaultModel,
+		defaultBudget:    defaultBudget,
+		modelBudgets:     modelBudgets,
+		maxMessageTokens: maxMessageTokens,
+	}
+}
+
+// EstimateTokens approximates the number of tokens in text
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// Budget returns the token budget for a model ("" means the default model)
+func (b *ContextBuilder) Budget(model string) int {
+	if model == "" {
+		model = b.defaultModel
+	}
+	if budget, ok := b.modelBudgets[model]; ok && budget > 0 {
+		return budget
+	}
+	return b.defaultBudget
+}
+
+// Build converts history into role-tagged messages that fit the model's budget.
+// The triggering message is always included as the last message; older history
+// is added newest-first until the budget is exhausted.
+func (b *ContextBuilder) Build(systemPrompt string, history []*domain.Message, trigger *domain.Message, model string) []domain.ChatMessage {
+	remaining := b.Budget(model) - EstimateTokens(systemPrompt)
+
+	// Always keep the triggering message, truncated only if it alone exceeds the budget
+	triggerMsg := toChatMessage(trigger)
+	if trigger.Media != nil && trigger.Media.Type == domain.MediaTypeImage {
+		// Only the triggering message carries images; history is text only
+		triggerMsg.Images = []*domain.Media{trigger.Media}
+	}
+	triggerLimit := remaining
+	if triggerLimit < b.maxMessageTokens {
+		triggerLimit = b.maxMessageTokens
+	}
+	triggerMsg.Content = truncateToTokens(triggerMsg.Content, triggerLimit)
+	remaining -= messageTokens(triggerMsg)
+
+	// Walk history from newest to oldest
+	selected := make([]domain.ChatMessage, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == trigger.ID {
+			continue
+		}
+
+		msg := toChatMessage(history[i])
+		msg.Content = truncateToTokens(msg.Content, b.maxMessageTokens)
+
+		cost := messageTokens(msg)
+		if cost > remaining {
+			break
+		}
+		remaining -= cost
+		selected = append(selected, msg)
+	}
+
+	// Restore chronological order and append the trigger
+	messages := make([]domain.ChatMessage, 0, len(selected)+1)
+	for i := len(selected) - 1; i >= 0; i-- {
+		messages = append(messages, selected[i])
+	}
+	return append(messages, triggerMsg)
+}
+
+// messageTokens estimates the tokens a chat message costs, including the sender name prefix
+func messageTokens(msg domain.ChatMessage) int {
+	return EstimateTokens(msg.SenderName) + EstimateTokens(msg.Content) + 1
+}
+
+// truncateToTokens shortens text to approximately maxTokens tokens
+func truncateToTokens(text string, maxTokens int) string {
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	maxChars := maxTokens*charsPerToken - utf8.RuneCountInString(truncationMarker)
+	if maxChars <= 0 {
+		return truncationMarker
+	}
+
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxChars])) + truncationMarker
+}
+
+// toChatMessage converts a stored message into a role-tagged LLM message
+func toChatMessage(msg *domain.Message) domain.ChatMessage {
+	if msg.IsFromBot {
+		return domain.ChatMessage{Role: domain.ChatRoleAssistant, Content: msg.Content}
+	}
+
+	name := msg.SenderName
+	if name == "" {
+		// Fall back to the phone number / user part of the JID, without device suffix
+		name = strings.SplitN(msg.Sender, "@", 2)[0]
+		name = strings.SplitN(name, ":", 2)[0]
+	}
+
+	return domain.ChatMessage{Role: domain.ChatRoleUser, SenderName: name, Content: msg.Content}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestContextBuilder_Budget(t *testing.T) {
+	builder := NewContextBuilder("small", 100, map[string]int{"small": 50, "large": 500}, 0)
+
+	if got := builder.Budget(""); got != 50 {
+		t.Errorf("Expected default model budget 50, got %d", got)
+	}
+	if got := builder.Budget("large"); got != 500 {
+		t.Errorf("Expected large model budget 500, got %d", got)
+	}
+	if got := builder.Budget("unknown"); got != 100 {
+		t.Errorf("Expected fallback budget 100, got %d", got)
+	}
+}
+
+func TestContextBuilder_Build(t *testing.T) {
+	trigger := &domain.Message{ID: "3", Sender: "111@s.whatsapp.net", SenderName: "Alice", Content: "What now?"}
+	history := []*domain.Message{
+		{ID: "1", Sender: "222@s.whatsapp.net", Content: strings.Repeat("old ", 100)},
+		{ID: "2", IsFromBot: true, Content: "Recent answer"},
+		trigger,
+	}
+
+	t.Run("drops oldest history beyond budget", func(t *testing.T) {
+		builder := NewContextBuilder("", 30, nil, 0)
+		messages := builder.Build("", history, trigger, "")
+
+		if len(messages) != 2 {
+			t.Fatalf("Expected 2 messages, got %d", len(messages))
+		}
+		if messages[0].Role != domain.ChatRoleAssistant || messages[1].Content != "What now?" {
+			t.Errorf("Unexpected messages: %+v", messages)
+		}
+	})
+
+	t.Run("truncates long history messages", func(t *testing.T) {
+		builder := NewContextBuilder("", 1000, nil, 10)
+		messages := builder.Build("", history, trigger, "")
+
+		if len(messages) != 3 {
+			t.Fatalf("Expected 3 messages, got %d", len(messages))
+		}
+		if !strings.HasSuffix(messages[0].Content, truncationMarker) {
+			t.Errorf("Expected truncated content, got %q", messages[0].Content)
+		}
+		if messages[0].SenderName != "222" {
+			t.Errorf("Expected sender fallback to phone number, got %q", messages[0].SenderName)
+		}
+	})
+
+	t.Run("always keeps trigger", func(t *testing.T) {
+		builder := NewContextBuilder("", 1, nil, 0)
+		messages := builder.Build(strings.Repeat("system ", 50), history, trigger, "")
+
+		if len(messages) != 1 || messages[0].Content != "What now?" {
+			t.Errorf("Expected only the trigger message, got %+v", messages)
+		}
+	})
+}
+
+func TestContextBuilder_BuildAttachesTriggerImage(t *testing.T) {
+	image := &domain.Media{Type: domain.MediaTypeImage, MimeType: "image/jpeg", Data: []byte{0xff, 0xd8}}
+	older := &domain.Message{ID: "1", Sender: "111@s.whatsapp.net", Content: "Earlier photo", Media: image}
+	trigger := &domain.Message{ID: "2", Sender: "111@s.whatsapp.net", Content: "What is in this photo?", Media: image}
+
+	messages := NewContextBuilder("", 0, nil, 0).Build("", []*domain.Message{older, trigger}, trigger, "")
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if len(messages[0].Images) != 0 {
+		t.Error("Expected history messages without images")
+	}
+	if len(messages[1].Images) != 1 || messages[1].Images[0] != image {
+		t.Errorf("Expected image on the trigger message, got %+v", messages[1].Images)
+	}
+}
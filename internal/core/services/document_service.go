@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// defaultDocumentChunkTokens is the size of the stored text chunks
+	defaultDocumentChunkTokens = 300
+
+	// defaultDocumentContextTokens bounds the document excerpts added to a prompt
+	defaultDocumentContextTokens = 1000
+
+	// recentDocumentLimit is the number of recent documents searched for a question
+	recentDocumentLimit = 5
+)
+
+// documentReferenceWords mark questions about a shared document as a whole,
+// e.g. "summarize the document above"
+var documentReferenceWords = map[string]bool{
+	"document": true, "documents": true, "doc": true, "pdf": true,
+	"file": true, "files": true, "attachment": true,
+}
+
+// stopWords are ignored when matching questions to document text
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "what": true, "who": true,
+	"when": true, "where": true, "which": true, "how": true, "does": true, "did": true,
+	"this": true, "that": true, "with": true, "from": true, "about": true, "there": true,
+	"can": true, "you": true, "your": true, "tell": true, "please": true, "have": true, "has": true,
+}
+
+// DocumentService extracts, stores and retrieves the text of documents shared in chats
+type DocumentService struct {
+	documents     domain.DocumentRepository
+	extractor     domain.TextExtractor
+	chunkTokens   int
+	contextTokens int
+	logger        *slog.Logger
+}
+
+// NewDocumentService creates a new document service. Zero config values fall back to defaults.
+func NewDocumentService(
+	documents domain.DocumentRepository,
+	extractor domain.TextExtractor,
+	cfg domain.DocumentsConfig,
+	logger *slog.Logger,
+) *DocumentService {
+	chunkTokens := cfg.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = defaultDocumentChunkTokens
+	}
+	contextTokens := cfg.ContextTokens
+	if contextTokens <= 0 {
+		contextTokens = defaultDocumentContextTokens
+	}
+
+	return &DocumentService{
+		documents:     documents,
+		extractor:     extractor,
+		chunkTokens:   chunkTokens,
+		contextTokens: contextTokens,
+		logger:        logger,
+	}
+}
+
+// Ingest extracts and stores the text of the document attached to a message.
+// Documents that were stored before, e.g. when a reply quotes them, are returned as-is.
+func (s *DocumentService) Ingest(ctx context.Context, message *domain.Message) (*domain.Document, error) {
+	media := message.Media
+	if media == nil || media.Type != domain.MediaTypeDocument {
+		return nil, fmt.Errorf("message has no document")
+	}
+
+	documentID := media.MessageID
+	if documentID == "" {
+		documentID = message.ID
+	}
+
+	existing, err := s.documents.Get(ctx, message.ChatJID, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	text, err := s.extractor.Extract(ctx, media)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	chunks := chunkText(text, s.chunkTokens)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("document %s has no text", media.FileName)
+	}
+
+	createdAt := message.Timestamp
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	document := &domain.Document{
+		ID:        documentID,
+		ChatJID:   message.ChatJID,
+		FileName:  media.FileName,
+		Sender:    message.Sender,
+		Chunks:    chunks,
+		CreatedAt: createdAt,
+	}
+	if err := s.documents.Save(ctx, document); err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	s.logger.Info("Document stored",
+		"chat", message.ChatJID,
+		"file", media.FileName,
+		"chunks", len(chunks))
+	return document, nil
+}
+
+// documentChunk is a chunk of document text considered for a prompt
+type documentChunk struct {
+	document *domain.Document
+	index    int
+	score    int
+}
+
+// Context returns the document excerpts that best answer a question. It searches the given
+// document, or the chat's recent documents if there is none. Questions about a document as
+// a whole get its beginning when no passage matches.
+func (s *DocumentService) Context(ctx context.Context, chatJID, question string, document *domain.Document) string {
+	candidates := []*domain.Document{document}
+	if document == nil {
+		recent, err := s.documents.GetByChatJID(ctx, chatJID, recentDocumentLimit)
+		if err != nil {
+			s.logger.Error("Failed to get documents", "chat", chatJID, "error", err)
+			return ""
+		}
+		if len(recent) == 0 {
+			return ""
+		}
+		candidates = recent
+	}
+
+	terms, refersToDocument := questionTerms(question)
+
+	var matches []documentChunk
+	for _, doc := range candidates {
+		for i, chunk := range doc.Chunks {
+			if score := scoreChunk(chunk, terms); score > 0 {
+				matches = append(matches, documentChunk{document: doc, index: i, score: score})
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		if document == nil && !refersToDocument {
+			return ""
+		}
+
+		// Start of the quoted document, or of the most recent one
+		focus := candidates[0]
+		for i := range focus.Chunks {
+			matches = append(matches, documentChunk{document: focus, index: i})
+		}
+	} else {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+	}
+
+	return s.formatExcerpts(matches)
+}
+
+// formatExcerpts renders chunks until the token budget is used, in document order
+func (s *DocumentService) formatExcerpts(chunks []documentChunk) string {
+	var selected []documentChunk
+	used := 0
+	for _, chunk := range chunks {
+		tokens := EstimateTokens(chunk.document.Chunks[chunk.index])
+		if used+tokens > s.contextTokens && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, chunk)
+		used += tokens
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].document != selected[j].document {
+			return selected[i].document.CreatedAt.Before(selected[j].document.CreatedAt)
+		}
+		return selected[i].index < selected[j].index
+	})
+
+	var builder strings.Builder
+	for _, chunk := range selected {
+		name := chunk.document.FileName
+		if name == "" {
+			name = "document"
+		}
+		fmt.Fprintf(&builder, "[%s, part %d of %d]\n%s\n\n",
+			name, chunk.index+1, len(chunk.document.Chunks),
+			truncateToTokens(chunk.document.Chunks[chunk.index], s.contextTokens))
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+// questionTerms returns the search terms of a question and whether it refers to a document
+func questionTerms(question string) ([]string, bool) {
+	var terms []string
+	refersToDocument := false
+	seen := make(map[string]bool)
+
+	for _, word := range splitWords(question) {
+		if documentReferenceWords[word] {
+			refersToDocument = true
+			continue
+		}
+		if len([]rune(word)) < 3 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+
+	return terms, refersToDocument
+}
+
+// scoreChunk counts the occurrences of the search terms in a chunk
+func scoreChunk(chunk string, terms []string) int {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	score := 0
+	for _, word := range splitWords(chunk) {
+		if wanted[word] {
+			score++
+		}
+	}
+	return score
+}
+
+// splitWords lowercases text and splits it into words
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// chunkText splits text into chunks of about maxTokens, keeping paragraphs together where possible
+func chunkText(text string, maxTokens int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		if EstimateTokens(current.String())+EstimateTokens(paragraph) > maxTokens {
+			flush()
+		}
+
+		// Split oversized paragraphs on word boundaries
+		for EstimateTokens(paragraph) > maxTokens {
+			cut := maxTokens * charsPerToken
+			runes := []rune(paragraph)
+			if space := strings.LastIndexFunc(string(runes[:cut]), unicode.IsSpace); space > 0 {
+				chunks = append(chunks, strings.TrimSpace(string(runes[:cut])[:space]))
+				paragraph = strings.TrimSpace(string(runes[:cut])[space:] + string(runes[cut:]))
+			} else {
+				chunks = append(chunks, string(runes[:cut]))
+				paragraph = strings.TrimSpace(string(runes[cut:]))
+			}
+		}
+
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+
+	return chunks
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MockDocumentRepository is a mock implementation of DocumentRepository
+type MockDocumentRepository struct {
+	documents []*domain.Document // oldest first
+}
+
+func (m *MockDocumentRepository) Save(ctx context.Context, document *domain.Document) error {
+	m.documents = append(m.documents, document)
+	return nil
+}
+
+func (m *MockDocumentRepository) Get(ctx context.Context, chatJID, documentID string) (*domain.Document, error) {
+	for _, document := range m.documents {
+		if document.ChatJID == chatJID && document.ID == documentID {
+			return document, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockDocumentRepository) GetByChatJID(ctx context.Context, chatJID string, limit int) ([]*domain.Document, error) {
+	var result []*domain.Document
+	for i := len(m.documents) - 1; i >= 0 && len(result) < limit; i-- {
+		if m.documents[i].ChatJID == chatJID {
+			result = append(result, m.documents[i])
+		}
+	}
+	return result, nil
+}
+
+// MockTextExtractor returns the document data as text
+type MockTextExtractor struct {
+	calls int
+}
+
+func (m *MockTextExtractor) Extract(ctx context.Context, document *domain.Media) (string, error) {
+	m.calls++
+	return string(document.Data), nil
+}
+
+func TestChunkText(t *testing.T) {
+	text := "First paragraph.\n\nSecond paragraph.\n\n" + strings.Repeat("word ", 100)
+
+	chunks := chunkText(text, 20)
+	if len(chunks) < 3 {
+		t.Fatalf("Expected the long paragraph to be split, got %d chunks", len(chunks))
+	}
+	if chunks[0] != "First paragraph.\n\nSecond paragraph." {
+		t.Errorf("Expected short paragraphs to share a chunk, got %q", chunks[0])
+	}
+	for _, chunk := range chunks {
+		if EstimateTokens(chunk) > 20 {
+			t.Errorf("Chunk exceeds budget: %q", chunk)
+		}
+	}
+}
+
+func TestDocumentService_Context(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	repo := &MockDocumentRepository{}
+	extractor := &MockTextExtractor{}
+	service := NewDocumentService(repo, extractor, domain.DocumentsConfig{ChunkTokens: 10}, logger)
+
+	message := &domain.Message{
+		ID:        "doc1",
+		ChatJID:   "family@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Timestamp: time.Now(),
+		Media: &domain.Media{
+			Type:     domain.MediaTypeDocument,
+			FileName: "trip.txt",
+			Data:     []byte("We land in Lisbon on Friday.\n\nThe hotel checkout is at noon."),
+		},
+	}
+
+	document, err := service.Ingest(ctx, message)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if len(document.Chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %v", document.Chunks)
+	}
+
+	// Quoting the document again does not extract it twice
+	if _, err := service.Ingest(ctx, message); err != nil || extractor.calls != 1 {
+		t.Fatalf("Expected stored document to be reused, extract calls %d, err %v", extractor.calls, err)
+	}
+
+	excerpts := service.Context(ctx, "family@g.us", "When is hotel checkout?", nil)
+	if !strings.Contains(excerpts, "checkout is at noon") || strings.Contains(excerpts, "Lisbon") {
+		t.Errorf("Expected only the matching passage, got %q", excerpts)
+	}
+
+	excerpts = service.Context(ctx, "family@g.us", "summarize the document above", nil)
+	if !strings.Contains(excerpts, "[trip.txt, part 1 of 2]") || !strings.Contains(excerpts, "checkout") {
+		t.Errorf("Expected the whole document, got %q", excerpts)
+	}
+
+	if excerpts := service.Context(ctx, "family@g.us", "what's for dinner?", nil); excerpts != "" {
+		t.Errorf("Expected no excerpts for an unrelated question, got %q", excerpts)
+	}
+	if excerpts := service.Context(ctx, "other@g.us", "summarize the document", nil); excerpts != "" {
+		t.Errorf("Expected no excerpts from other chats, got %q", excerpts)
+	}
+}
+
+func TestChatService_ProcessMessage_Document(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llm := &MockLLMProvider{response: "The hotel checkout is at noon."}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"family@g.us": true}}
+
+	service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@sasi"}, nil, logger)
+	service.UpdateDocuments(NewDocumentService(&MockDocumentRepository{}, &MockTextExtractor{}, domain.DocumentsConfig{}, logger))
+
+	// Shared without a caption: stored, not answered
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "doc1",
+		GroupJID:  "family@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Timestamp: time.Now(),
+		Media: &domain.Media{
+			Type:     domain.MediaTypeDocument,
+			FileName: "trip.txt",
+			Data:     []byte("The hotel checkout is at noon."),
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	if len(whatsapp.sentMessages) != 0 {
+		t.Fatalf("Expected no reply to the document, got %v", whatsapp.sentMessages)
+	}
+
+	err = service.ProcessMessage(ctx, &domain.Message{
+		ID:        "msg2",
+		GroupJID:  "family@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@sasi summarize the document above",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if len(whatsapp.sentMessages) != 1 {
+		t.Fatalf("Expected 1 reply, got %d", len(whatsapp.sentMessages))
+	}
+	if !strings.Contains(llm.lastRequest.SystemPrompt, "[trip.txt, part 1 of 1]\nThe hotel checkout is at noon.") {
+		t.Errorf("Expected document excerpt in system prompt, got %q", llm.lastRequest.SystemPrompt)
+	}
+}
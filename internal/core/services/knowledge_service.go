@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// defaultKnowledgeTopK is the number of knowledge chunks added to a prompt
+	defaultKnowledgeTopK = 4
+
+	// defaultKnowledgeMinScore is the minimum cosine similarity for a chunk to be used
+	defaultKnowledgeMinScore = 0.5
+
+	// defaultKnowledgeChunkTokens is the size of the embedded text chunks
+	defaultKnowledgeChunkTokens = 300
+)
+
+// ErrKnowledgeDisabled is returned when the knowledge base is used while it is disabled
+var ErrKnowledgeDisabled = errors.New("knowledge base is disabled")
+
+// KnowledgeService manages per-group knowledge bases and retrieves the passages
+// that ground the bot's answers
+type KnowledgeService struct {
+	knowledge   domain.KnowledgeRepository
+	embedder    domain.Embedder
+	topK        int
+	minScore    float64
+	chunkTokens int
+	logger      *slog.Logger
+	mu          sync.RWMutex
+}
+
+// NewKnowledgeService creates a new knowledge service. It stays disabled until
+// UpdateConfig provides an embedder.
+func NewKnowledgeService(knowledge domain.KnowledgeRepository, logger *slog.Logger) *KnowledgeService {
+	s := &KnowledgeService{
+		knowledge: knowledge,
+		logger:    logger,
+	}
+	s.UpdateConfig(domain.KnowledgeConfig{}, nil)
+	return s
+}
+
+// UpdateConfig updates the retrieval settings and embedder; a nil embedder disables the knowledge base.
+// Zero config values fall back to defaults, except min_score, which only does when unset.
+func (s *KnowledgeService) UpdateConfig(cfg domain.KnowledgeConfig, embedder domain.Embedder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.embedder = embedder
+
+	s.topK = cfg.TopK
+	if s.topK <= 0 {
+		s.topK = defaultKnowledgeTopK
+	}
+
+	s.minScore = defaultKnowledgeMinScore
+	if cfg.MinScore != nil {
+		s.minScore = *cfg.MinScore
+	}
+
+	s.chunkTokens = cfg.ChunkTokens
+	if s.chunkTokens <= 0 {
+		s.chunkTokens = defaultKnowledgeChunkTokens
+	}
+}
+
+// AddDocument splits a text into chunks, embeds them and adds it to a group's knowledge base
+func (s *KnowledgeService) AddDocument(ctx context.Context, groupJID, title, content string) (*domain.KnowledgeDocument, error) {
+	s.mu.RLock()
+	embedder := s.embedder
+	chunkTokens := s.chunkTokens
+	s.mu.RUnlock()
+
+	if embedder == nil {
+		return nil, ErrKnowledgeDisabled
+	}
+
+	texts := chunkText(content, chunkTokens)
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("document has no text")
+	}
+
+	// Embedding a document can take many requests, so let replies to users go first
+	embedCtx := withLLMJob(ctx, groupJID, LLMPriorityBackground, nil)
+
+	chunks := make([]*domain.KnowledgeChunk, 0, len(texts))
+	for i, text := range texts {
+		// The title gives short chunks the context they were written in
+		embedding, err := embedder.Embed(embedCtx, title+"\n\n"+text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed chunk %d: %w", i+1, err)
+		}
+		chunks = append(chunks, &domain.KnowledgeChunk{Content: text, Embedding: embedding})
+	}
+
+	document := &domain.KnowledgeDocument{
+		ID:        uuid.New().String(),
+		GroupJID:  groupJID,
+		Title:     title,
+		Chunks:    len(chunks),
+		CreatedAt: time.Now(),
+	}
+	if err := s.knowledge.SaveDocument(ctx, document, chunks); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Knowledge document added", "group", groupJID, "title", title, "chunks", len(chunks))
+	return document, nil
+}
+
+// ListDocuments returns the documents in a group's knowledge base
+func (s *KnowledgeService) ListDocuments(ctx context.Context, groupJID string) ([]*domain.KnowledgeDocument, error) {
+	return s.knowledge.ListDocuments(ctx, groupJID)
+}
+
+// DeleteDocument removes a document from a group's knowledge base
+func (s *KnowledgeService) DeleteDocument(ctx context.Context, groupJID, documentID string) error {
+	if err := s.knowledge.DeleteDocument(ctx, groupJID, documentID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Knowledge document deleted", "group", groupJID, "id", documentID)
+	return nil
+}
+
+// Search returns the group's knowledge chunks most relevant to a question
+func (s *KnowledgeService) Search(ctx context.Context, groupJID, question string) ([]*domain.KnowledgeChunk, error) {
+	s.mu.RLock()
+	embedder := s.embedder
+	topK := s.topK
+	minScore := s.minScore
+	s.mu.RUnlock()
+
+	if embedder == nil {
+		return nil, ErrKnowledgeDisabled
+	}
+
+	embedding, err := embedder.Embed(withLLMJob(ctx, groupJID, LLMPriorityInteractive, nil), question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	chunks, err := s.knowledge.Search(ctx, groupJID, embedding, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	relevant := chunks[:0]
+	for _, chunk := range chunks {
+		if chunk.Score >= minScore {
+			relevant = append(relevant, chunk)
+		}
+	}
+	return relevant, nil
+}
+
+// Context returns the knowledge passages relevant to a question, formatted for the prompt,
+// or "" if the group has none
+func (s *KnowledgeService) Context(ctx context.Context, groupJID, question string) string {
+	if strings.TrimSpace(question) == "" {
+		return ""
+	}
+
+	// Skip embedding the question for groups without a knowledge base
+	documents, err := s.knowledge.ListDocuments(ctx, groupJID)
+	if err != nil || len(documents) == 0 {
+		return ""
+	}
+
+	chunks, err := s.Search(ctx, groupJID, question)
+	if errors.Is(err, ErrKnowledgeDisabled) {
+		return ""
+	}
+	if err != nil {
+		s.logger.Error("Failed to search knowledge base", "group", groupJID, "error", err)
+		return ""
+	}
+
+	var builder strings.Builder
+	for _, chunk := range chunks {
+		fmt.Fprintf(&builder, "[%s]\n%s\n\n", chunk.Title, chunk.Content)
+	}
+	return strings.TrimSpace(builder.String())
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MockKnowledgeRepository is a mock implementation of KnowledgeRepository
+type MockKnowledgeRepository struct {
+	documents []*domain.KnowledgeDocument
+	chunks    map[string][]*domain.KnowledgeChunk
+}
+
+func (m *MockKnowledgeRepository) SaveDocument(ctx context.Context, document *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	if m.chunks == nil {
+		m.chunks = make(map[string][]*domain.KnowledgeChunk)
+	}
+	m.documents = append(m.documents, document)
+	m.chunks[document.ID] = chunks
+	return nil
+}
+
+func (m *MockKnowledgeRepository) ListDocuments(ctx context.Context, groupJID string) ([]*domain.KnowledgeDocument, error) {
+	var result []*domain.KnowledgeDocument
+	for _, document := range m.documents {
+		if document.GroupJID == groupJID {
+			result = append(result, document)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockKnowledgeRepository) DeleteDocument(ctx context.Context, groupJID, documentID string) error {
+	for i, document := range m.documents {
+		if document.GroupJID == groupJID && document.ID == documentID {
+			m.documents = append(m.documents[:i], m.documents[i+1:]...)
+			delete(m.chunks, documentID)
+			return nil
+		}
+	}
+	return errors.New("document not found")
+}
+
+// Search scores chunks by the dot product of the (one-hot) embeddings
+func (m *MockKnowledgeRepository) Search(ctx context.Context, groupJID string, embedding []float32, limit int) ([]*domain.KnowledgeChunk, error) {
+	var result []*domain.KnowledgeChunk
+	for _, document := range m.documents {
+		if document.GroupJID != groupJID {
+			continue
+		}
+		for _, chunk := range m.chunks[document.ID] {
+			var score float64
+			for i := range embedding {
+				score += float64(embedding[i] * chunk.Embedding[i])
+			}
+			result = append(result, &domain.KnowledgeChunk{
+				DocumentID: document.ID,
+				Title:      document.Title,
+				Content:    chunk.Content,
+				Score:      score,
+			})
+		}
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// MockEmbedder embeds text as a one-hot vector of the first topic it mentions
+type MockEmbedder struct {
+	topics []string
+	calls  int
+}
+
+func (m *MockEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	m.calls++
+	embedding := make([]float32, len(m.topics))
+	lower := strings.ToLower(text)
+	for i, topic := range m.topics {
+		if strings.Contains(lower, topic) {
+			embedding[i] = 1
+			break
+		}
+	}
+	return embedding, nil
+}
+
+func TestKnowledgeService_Context(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	embedder := &MockEmbedder{topics: []string{"parking", "wifi"}}
+	service := NewKnowledgeService(&MockKnowledgeRepository{}, logger)
+
+	if _, err := service.AddDocument(ctx, "club@g.us", "Parking", "Park behind the hall."); !errors.Is(err, ErrKnowledgeDisabled) {
+		t.Fatalf("Expected ErrKnowledgeDisabled without an embedder, got %v", err)
+	}
+
+	service.UpdateConfig(domain.KnowledgeConfig{Enabled: true, EmbeddingModel: "test"}, embedder)
+
+	document, err := service.AddDocument(ctx, "club@g.us", "Parking", "Park behind the hall, spaces are free after 6pm.")
+	if err != nil {
+		t.Fatalf("AddDocument() error = %v", err)
+	}
+	if document.Chunks != 1 {
+		t.Errorf("Expected 1 chunk, got %d", document.Chunks)
+	}
+	if _, err := service.AddDocument(ctx, "club@g.us", "Wifi", "The wifi password is on the fridge."); err != nil {
+		t.Fatalf("AddDocument() error = %v", err)
+	}
+
+	passages := service.Context(ctx, "club@g.us", "where is the parking?")
+	if !strings.Contains(passages, "[Parking]\nPark behind the hall") {
+		t.Errorf("Expected the parking entry, got %q", passages)
+	}
+	if strings.Contains(passages, "wifi password") {
+		t.Errorf("Expected the unrelated wifi entry to be filtered out, got %q", passages)
+	}
+
+	// A min_score of 0 keeps every match instead of falling back to the default
+	minScore := 0.0
+	service.UpdateConfig(domain.KnowledgeConfig{Enabled: true, EmbeddingModel: "test", MinScore: &minScore}, embedder)
+	if passages := service.Context(ctx, "club@g.us", "where is the parking?"); !strings.Contains(passages, "wifi password") {
+		t.Errorf("Expected every entry with min_score 0, got %q", passages)
+	}
+	service.UpdateConfig(domain.KnowledgeConfig{Enabled: true, EmbeddingModel: "test"}, embedder)
+
+	// Groups without a knowledge base are skipped without embedding the question
+	calls := embedder.calls
+	if passages := service.Context(ctx, "other@g.us", "where is the parking?"); passages != "" {
+		t.Errorf("Expected no passages for another group, got %q", passages)
+	}
+	if embedder.calls != calls {
+		t.Error("Expected the question not to be embedded for a group without documents")
+	}
+}
+
+func TestChatService_ProcessMessage_Knowledge(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llm := &MockLLMProvider{response: "Park behind the hall."}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"club@g.us": true}}
+
+	knowledge := NewKnowledgeService(&MockKnowledgeRepository{}, logger)
+	knowledge.UpdateConfig(domain.KnowledgeConfig{Enabled: true}, &MockEmbedder{topics: []string{"parking"}})
+	if _, err := knowledge.AddDocument(ctx, "club@g.us", "Parking", "Park behind the hall."); err != nil {
+		t.Fatalf("AddDocument() error = %v", err)
+	}
+
+	service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@sasi"}, nil, logger)
+	service.SetKnowledgeService(knowledge)
+
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "club@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@sasi where can I leave my car? Is there parking?",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if !strings.Contains(llm.lastRequest.SystemPrompt, "[Parking]\nPark behind the hall.") {
+		t.Errorf("Expected knowledge passage in system prompt, got %q", llm.lastRequest.SystemPrompt)
+	}
+}
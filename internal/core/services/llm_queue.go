@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// LLM request priorities; waiting requests of a higher priority are served first
+const (
+	LLMPriorityBackground  = iota // conversation summaries
+	LLMPriorityScheduled          // scheduled webhook calls
+	LLMPriorityInteractive        // replies to users
+)
+
+// llmPriorityNames labels the priorities in metrics
+var llmPriorityNames = [...]string{"background", "scheduled", "interactive"}
+
+const (
+	// defaultLLMConcurrency suits a single Ollama host
+	defaultLLMConcurrency = 1
+
+	// defaultLLMMaxQueued is the number of waiting requests before new ones are refused
+	defaultLLMMaxQueued = 100
+
+	// defaultLLMNotifyAfter is the wait before a user is told their place in line
+	defaultLLMNotifyAfter = 10 * time.Second
+
+	// defaultQueueMessage tells a user their place in line
+	defaultQueueMessage = "You're #{position} in line, I'll answer as soon as I can."
+
+	// defaultBusyMessage tells a user the queue is full
+	defaultBusyMessage = "I'm busy right now, please try again shortly."
+)
+
+// ErrLLMQueueFull is returned when too many requests are already waiting for the LLM
+var ErrLLMQueueFull = errors.New("too many requests are waiting for the LLM")
+
+var (
+	// llmQueueDepth is the number of requests waiting for the LLM per priority
+	llmQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "whatsapp_llm_queue_depth",
+			Help: "Number of requests waiting for the LLM by priority",
+		},
+		[]string{"priority"},
+	)
+
+	// llmRequestsRunning is the number of requests being generated
+	llmRequestsRunning = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "whatsapp_llm_requests_running",
+			Help: "Number of requests currently being generated by the LLM",
+		},
+	)
+
+	// llmQueueWait is the time requests wait for the LLM
+	llmQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "whatsapp_llm_queue_wait_seconds",
+			Help:    "Time requests wait in the queue before the LLM starts generating",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+		},
+		[]string{"priority"},
+	)
+
+	// llmQueueRejected counts the requests refused because the queue was full
+	llmQueueRejected = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "whatsapp_llm_queue_rejected_total",
+			Help: "Total number of requests refused because too many were waiting for the LLM",
+		},
+	)
+)
+
+// llmJobKey is the context key of an LLM request's queue details
+type llmJobKey struct{}
+
+// llmJobInfo describes who an LLM request is for
+type llmJobInfo struct {
+	chatJID  string
+	priority int
+	onWait   func(position int) // called once the request has waited notify_after, may be nil
+}
+
+// withLLMJob attaches the chat, priority and wait callback of the LLM requests made with ctx
+func withLLMJob(ctx context.Context, chatJID string, priority int, onWait func(position int)) context.Context {
+	return context.WithValue(ctx, llmJobKey{}, llmJobInfo{chatJID: chatJID, priority: priority, onWait: onWait})
+}
+
+// llmJobFrom returns the queue details of a request; requests without any are interactive
+func llmJobFrom(ctx context.Context) llmJobInfo {
+	info, ok := ctx.Value(llmJobKey{}).(llmJobInfo)
+	if !ok {
+		info.priority = LLMPriorityInteractive
+	}
+	if info.priority < LLMPriorityBackground || info.priority > LLMPriorityInteractive {
+		info.priority = LLMPriorityInteractive
+	}
+	return info
+}
+
+// llmJob is a request waiting for the LLM
+type llmJob struct {
+	llmJobInfo
+	ready chan struct{} // closed when the request may start
+}
+
+// LLMQueue bounds the concurrent requests to the LLM. Waiting requests are served by priority
+// and, within a priority, round-robin across chats so one busy group cannot starve the others.
+type LLMQueue struct {
+	provider     domain.LLMProvider
+	concurrency  int
+	maxQueued    int
+	notifyAfter  time.Duration
+	queueMessage string
+	busyMessage  string
+	running      int
+	queued       int
+	levels       [len(llmPriorityNames)]fairQueue
+	mu           sync.Mutex
+	logger       *slog.Logger
+}
+
+// NewLLMQueue creates a queue in front of an LLM provider
+func NewLLMQueue(provider domain.LLMProvider, cfg domain.LLMQueueConfig, logger *slog.Logger) *LLMQueue {
+	q := &LLMQueue{
+		provider: provider,
+		logger:   logger,
+	}
+	q.UpdateConfig(cfg)
+	return q
+}
+
+// UpdateConfig updates the queue settings; a higher concurrency starts waiting requests at once
+func (q *LLMQueue) UpdateConfig(cfg domain.LLMQueueConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.concurrency = cfg.Concurrency
+	if q.concurrency <= 0 {
+		q.concurrency = defaultLLMConcurrency
+	}
+
+	q.maxQueued = cfg.MaxQueued
+	if q.maxQueued <= 0 {
+		q.maxQueued = defaultLLMMaxQueued
+	}
+
+	q.notifyAfter = defaultLLMNotifyAfter
+	if cfg.NotifyAfter != "" {
+		if notifyAfter, err := time.ParseDuration(cfg.NotifyAfter); err == nil && notifyAfter >= 0 {
+			q.notifyAfter = notifyAfter
+		}
+	}
+
+	q.queueMessage = cfg.QueueMessage
+	if q.queueMessage == "" {
+		q.queueMessage = defaultQueueMessage
+	}
+
+	q.busyMessage = cfg.BusyMessage
+	if q.busyMessage == "" {
+		q.busyMessage = defaultBusyMessage
+	}
+
+	q.dispatch()
+}
+
+// Provider returns an LLM provider whose requests wait for their turn in the queue. It streams
+// if the underlying provider does.
+func (q *LLMQueue) Provider() domain.LLMProvider {
+	if _, ok := q.provider.(domain.StreamingLLMProvider); ok {
+		return &queuedStreamingProvider{queuedProvider{queue: q}}
+	}
+	return &queuedProvider{queue: q}
+}
+
+// Embedder returns an embedder whose requests wait for their turn in the queue, as they
+// usually run on the same host as the LLM. A nil embedder stays nil.
+func (q *LLMQueue) Embedder(embedder domain.Embedder) domain.Embedder {
+	if embedder == nil {
+		return nil
+	}
+	return &queuedEmbedder{queue: q, embedder: embedder}
+}
+
+// Notice returns the message telling a user their place in line, or "" to stay silent
+func (q *LLMQueue) Notice(position int) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if strings.TrimSpace(q.queueMessage) == silentLimitMessage {
+		return ""
+	}
+	return strings.ReplaceAll(q.queueMessage, "{position}", strconv.Itoa(position))
+}
+
+// BusyNotice returns the message telling a user the queue is full, or "" to stay silent
+func (q *LLMQueue) BusyNotice() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if strings.TrimSpace(q.busyMessage) == silentLimitMessage {
+		return ""
+	}
+	return q.busyMessage
+}
+
+// acquire waits until the request may use the LLM and returns the function that frees its slot
+func (q *LLMQueue) acquire(ctx context.Context) (func(), error) {
+	info := llmJobFrom(ctx)
+	priority := llmPriorityNames[info.priority]
+	start := time.Now()
+
+	q.mu.Lock()
+	if q.running < q.concurrency && q.queued == 0 {
+		q.running++
+		llmRequestsRunning.Inc()
+		q.mu.Unlock()
+		llmQueueWait.WithLabelValues(priority).Observe(0)
+		return q.release, nil
+	}
+	if q.queued >= q.maxQueued {
+		q.mu.Unlock()
+		llmQueueRejected.Inc()
+		return nil, ErrLLMQueueFull
+	}
+
+	job := &llmJob{llmJobInfo: info, ready: make(chan struct{})}
+	q.levels[info.priority].push(job)
+	q.queued++
+	llmQueueDepth.WithLabelValues(priority).Inc()
+	notifyAfter := q.notifyAfter
+	q.mu.Unlock()
+
+	var notify <-chan time.Time
+	if info.onWait != nil && notifyAfter > 0 {
+		timer := time.NewTimer(notifyAfter)
+		defer timer.Stop()
+		notify = timer.C
+	}
+
+	for {
+		select {
+		case <-job.ready:
+			llmQueueWait.WithLabelValues(priority).Observe(time.Since(start).Seconds())
+			return q.release, nil
+
+		case <-notify:
+			notify = nil
+			if position, ok := q.position(job); ok {
+				q.logger.Debug("LLM request is waiting", "chat", info.chatJID, "position", position)
+				info.onWait(position)
+			}
+
+		case <-ctx.Done():
+			q.mu.Lock()
+			removed := q.levels[info.priority].remove(job)
+			if removed {
+				q.queued--
+				llmQueueDepth.WithLabelValues(priority).Dec()
+			}
+			q.mu.Unlock()
+
+			if !removed {
+				// Started just now; hand the slot on
+				q.release()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release frees a slot and starts the next waiting request
+func (q *LLMQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	llmRequestsRunning.Dec()
+	q.dispatch()
+}
+
+// dispatch starts waiting requests while slots are free; callers hold q.mu
+func (q *LLMQueue) dispatch() {
+	for q.running < q.concurrency && q.queued > 0 {
+		for priority := len(q.levels) - 1; priority >= 0; priority-- {
+			if job := q.levels[priority].pop(); job != nil {
+				q.queued--
+				q.running++
+				llmQueueDepth.WithLabelValues(llmPriorityNames[priority]).Dec()
+				llmRequestsRunning.Inc()
+				close(job.ready)
+				break
+			}
+		}
+	}
+}
+
+// position returns a waiting request's place in line, counting from 1
+func (q *LLMQueue) position(job *llmJob) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ahead, ok := q.levels[job.priority].ahead(job)
+	if !ok {
+		return 0, false
+	}
+	for priority := job.priority + 1; priority < len(q.levels); priority++ {
+		ahead += q.levels[priority].len()
+	}
+	return ahead + 1, true
+}
+
+// fairQueue holds the waiting requests of one priority, served round-robin across chats
+type fairQueue struct {
+	jobs  map[string][]*llmJob
+	order []string // chats with waiting requests, next to be served first
+}
+
+// push adds a request behind the chat's other waiting requests
+func (f *fairQueue) push(job *llmJob) {
+	if f.jobs == nil {
+		f.jobs = make(map[string][]*llmJob)
+	}
+	if len(f.jobs[job.chatJID]) == 0 {
+		f.order = append(f.order, job.chatJID)
+	}
+	f.jobs[job.chatJID] = append(f.jobs[job.chatJID], job)
+}
+
+// pop removes the next chat's oldest request and moves the chat to the back of the line
+func (f *fairQueue) pop() *llmJob {
+	if len(f.order) == 0 {
+		return nil
+	}
+
+	chat := f.order[0]
+	f.order = f.order[1:]
+	jobs := f.jobs[chat]
+	job := jobs[0]
+
+	if len(jobs) > 1 {
+		f.jobs[chat] = jobs[1:]
+		f.order = append(f.order, chat)
+	} else {
+		delete(f.jobs, chat)
+	}
+	return job
+}
+
+// remove drops a request that is no longer waiting, reporting whether it was found
+func (f *fairQueue) remove(job *llmJob) bool {
+	jobs := f.jobs[job.chatJID]
+	for i, candidate := range jobs {
+		if candidate != job {
+			continue
+		}
+
+		jobs = append(jobs[:i:i], jobs[i+1:]...)
+		if len(jobs) > 0 {
+			f.jobs[job.chatJID] = jobs
+			return true
+		}
+
+		delete(f.jobs, job.chatJID)
+		for j, chat := range f.order {
+			if chat == job.chatJID {
+				f.order = append(f.order[:j:j], f.order[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ahead returns the number of requests that will be served before a waiting request
+func (f *fairQueue) ahead(job *llmJob) (int, bool) {
+	index := -1
+	for i, candidate := range f.jobs[job.chatJID] {
+		if candidate == job {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, false
+	}
+
+	// Every round serves one request per chat, starting with the chat at the front
+	ahead := index
+	before := true
+	for _, chat := range f.order {
+		if chat == job.chatJID {
+			before = false
+			continue
+		}
+		rounds := index
+		if before {
+			rounds++
+		}
+		ahead += min(len(f.jobs[chat]), rounds)
+	}
+	return ahead, true
+}
+
+// len returns the number of waiting requests
+func (f *fairQueue) len() int {
+	count := 0
+	for _, jobs := range f.jobs {
+		count += len(jobs)
+	}
+	return count
+}
+
+// queuedProvider makes LLM requests wait for their turn in the queue
+type queuedProvider struct {
+	queue *LLMQueue
+}
+
+func (p *queuedProvider) Generate(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	release, err := p.queue.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.queue.provider.Generate(ctx, request)
+}
+
+// IsAvailable checks the underlying provider without waiting in the queue
+func (p *queuedProvider) IsAvailable(ctx context.Context) bool {
+	return p.queue.provider.IsAvailable(ctx)
+}
+
+// queuedStreamingProvider is a queuedProvider for streaming providers
+type queuedStreamingProvider struct {
+	queuedProvider
+}
+
+func (p *queuedStreamingProvider) GenerateStream(ctx context.Context, request *domain.LLMRequest, onChunk func(chunk string) error) (*domain.LLMResponse, error) {
+	release, err := p.queue.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.queue.provider.(domain.StreamingLLMProvider).GenerateStream(ctx, request, onChunk)
+}
+
+// queuedEmbedder makes embedding requests wait for their turn in the queue
+type queuedEmbedder struct {
+	queue    *LLMQueue
+	embedder domain.Embedder
+}
+
+func (e *queuedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	release, err := e.queue.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return e.embedder.Embed(ctx, text)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// BlockingLLMProvider answers each request once it is released, recording the order of requests
+type BlockingLLMProvider struct {
+	started chan string
+	release chan struct{}
+	mu      sync.Mutex
+	order   []string
+}
+
+func newBlockingLLMProvider() *BlockingLLMProvider {
+	return &BlockingLLMProvider{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+func (m *BlockingLLMProvider) Generate(ctx context.Context, request *domain.LLMRequest) (*domain.LLMResponse, error) {
+	prompt := request.Messages[0].Content
+	m.mu.Lock()
+	m.order = append(m.order, prompt)
+	m.mu.Unlock()
+
+	m.started <- prompt
+	<-m.release
+	return &domain.LLMResponse{Content: prompt}, nil
+}
+
+func (m *BlockingLLMProvider) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// submit makes a queued request in the background and returns a channel with its error
+func submit(ctx context.Context, provider domain.LLMProvider, prompt string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := provider.Generate(ctx, &domain.LLMRequest{Messages: []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: prompt}}})
+		done <- err
+	}()
+	return done
+}
+
+// waitQueued waits until the queue holds n waiting requests
+func waitQueued(t *testing.T, queue *LLMQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		queue.mu.Lock()
+		queued := queue.queued
+		queue.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d queued requests", n)
+}
+
+func TestLLMQueue_PriorityAndFairness(t *testing.T) {
+	inner := newBlockingLLMProvider()
+	queue := NewLLMQueue(inner, domain.LLMQueueConfig{Concurrency: 1}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	provider := queue.Provider()
+	ctx := context.Background()
+
+	// Occupy the only slot, then queue requests behind it
+	submit(ctx, provider, "first")
+	<-inner.started
+
+	requests := []struct {
+		chat     string
+		priority int
+		prompt   string
+	}{
+		{"busy@g.us", LLMPriorityInteractive, "busy 1"},
+		{"busy@g.us", LLMPriorityInteractive, "busy 2"},
+		{"busy@g.us", LLMPriorityInteractive, "busy 3"},
+		{"quiet@g.us", LLMPriorityBackground, "summary"},
+		{"quiet@g.us", LLMPriorityInteractive, "quiet 1"},
+	}
+	for i, request := range requests {
+		submit(withLLMJob(ctx, request.chat, request.priority, nil), provider, request.prompt)
+		waitQueued(t, queue, i+1)
+	}
+
+	for range requests {
+		inner.release <- struct{}{}
+		<-inner.started
+	}
+	inner.release <- struct{}{}
+
+	want := []string{"first", "busy 1", "quiet 1", "busy 2", "busy 3", "summary"}
+	for i := range want {
+		if inner.order[i] != want[i] {
+			t.Fatalf("Expected order %v, got %v", want, inner.order)
+		}
+	}
+}
+
+func TestLLMQueue_Concurrency(t *testing.T) {
+	inner := newBlockingLLMProvider()
+	queue := NewLLMQueue(inner, domain.LLMQueueConfig{Concurrency: 2}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	provider := queue.Provider()
+	ctx := context.Background()
+
+	for _, prompt := range []string{"a", "b", "c"} {
+		submit(ctx, provider, prompt)
+	}
+	<-inner.started
+	<-inner.started
+	waitQueued(t, queue, 1)
+
+	// Raising the concurrency starts the waiting request
+	queue.UpdateConfig(domain.LLMQueueConfig{Concurrency: 3})
+	select {
+	case <-inner.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the waiting request to start")
+	}
+	close(inner.release)
+}
+
+func TestLLMQueue_PositionNotice(t *testing.T) {
+	inner := newBlockingLLMProvider()
+	queue := NewLLMQueue(inner, domain.LLMQueueConfig{NotifyAfter: "10ms"}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	provider := queue.Provider()
+	ctx := context.Background()
+	defer close(inner.release)
+
+	submit(ctx, provider, "first")
+	<-inner.started
+	submit(withLLMJob(ctx, "a@g.us", LLMPriorityInteractive, nil), provider, "a")
+	submit(withLLMJob(ctx, "b@g.us", LLMPriorityInteractive, nil), provider, "b")
+	waitQueued(t, queue, 2)
+
+	positions := make(chan int, 1)
+	submit(withLLMJob(ctx, "a@g.us", LLMPriorityInteractive, func(position int) { positions <- position }), provider, "a again")
+
+	select {
+	case position := <-positions:
+		if position != 3 {
+			t.Errorf("Expected to be #3 in line, got #%d", position)
+		}
+		if notice := queue.Notice(position); notice != "You're #3 in line, I'll answer as soon as I can." {
+			t.Errorf("Unexpected notice %q", notice)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a position notice")
+	}
+}
+
+func TestLLMQueue_CancelAndFull(t *testing.T) {
+	inner := newBlockingLLMProvider()
+	queue := NewLLMQueue(inner, domain.LLMQueueConfig{MaxQueued: 1}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	provider := queue.Provider()
+	defer close(inner.release)
+
+	submit(context.Background(), provider, "first")
+	<-inner.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := submit(ctx, provider, "second")
+	waitQueued(t, queue, 1)
+
+	if err := <-submit(context.Background(), provider, "third"); !errors.Is(err, ErrLLMQueueFull) {
+		t.Errorf("Expected ErrLLMQueueFull, got %v", err)
+	}
+
+	cancel()
+	if err := <-waiting; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled request to give up, got %v", err)
+	}
+	waitQueued(t, queue, 0)
+}
+
+func TestLLMQueue_BusyReply(t *testing.T) {
+	ctx := context.Background()
+	queue := NewLLMQueue(&MockLLMProvider{}, domain.LLMQueueConfig{}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	llm := &MockLLMProvider{err: fmt.Errorf("generate: %w", ErrLLMQueueFull)}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"group1@g.us": true}}
+	service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	service.SetLLMQueue(queue)
+
+	message := &domain.Message{ID: "msg1", GroupJID: "group1@g.us", Sender: "user@s.whatsapp.net", Content: "@bot hi", Timestamp: time.Now()}
+	if err := service.ProcessMessage(ctx, message); !errors.Is(err, ErrLLMQueueFull) {
+		t.Fatalf("Expected ErrLLMQueueFull, got %v", err)
+	}
+	if len(whatsapp.sentMessages) != 1 || whatsapp.sentMessages[0] != defaultBusyMessage {
+		t.Errorf("Expected the busy reply, got %v", whatsapp.sentMessages)
+	}
+
+	// "-" keeps the bot silent
+	queue.UpdateConfig(domain.LLMQueueConfig{BusyMessage: "-"})
+	message.ID = "msg2"
+	service.ProcessMessage(ctx, message)
+	if len(whatsapp.sentMessages) != 1 {
+		t.Errorf("Expected no reply, got %v", whatsapp.sentMessages)
+	}
+}
+
+// BlockingEmbedder records the embedded texts
+type BlockingEmbedder struct {
+	texts chan string
+}
+
+func (e *BlockingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.texts <- text
+	return []float32{1}, nil
+}
+
+func TestLLMQueue_Embedder(t *testing.T) {
+	inner := newBlockingLLMProvider()
+	queue := NewLLMQueue(inner, domain.LLMQueueConfig{}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	if queue.Embedder(nil) != nil {
+		t.Error("Expected a nil embedder to stay nil")
+	}
+
+	embedded := &BlockingEmbedder{texts: make(chan string, 1)}
+	embedder := queue.Embedder(embedded)
+
+	// Embeddings wait while the LLM is busy
+	submit(context.Background(), queue.Provider(), "first")
+	<-inner.started
+	done := make(chan error, 1)
+	go func() {
+		_, err := embedder.Embed(context.Background(), "question")
+		done <- err
+	}()
+	waitQueued(t, queue, 1)
+
+	inner.release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if text := <-embedded.texts; text != "question" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// idempotencyKeyTTL is how long a sent message's idempotency key prevents resending it
+const idempotencyKeyTTL = 24 * time.Hour
+
+var (
+	// ErrInvalidOutgoingMessage is returned for a message without a chat or content
+	ErrInvalidOutgoingMessage = errors.New("chat_jid and text or media are required")
+
+	// ErrChatNotAllowed is returned for a group the bot is not allowed in, or a contact
+	// the bot may not message directly
+	ErrChatNotAllowed = errors.New("chat is not allowed")
+
+	// ErrIdempotencyKeyInUse is returned while a request with the same idempotency key is being sent
+	ErrIdempotencyKeyInUse = errors.New("a request with this idempotency key is in progress")
+)
+
+// idempotentSend is the outcome of a message sent with an idempotency key; result is nil while sending
+type idempotentSend struct {
+	result  *domain.SentMessage
+	expires time.Time
+}
+
+// MessagingService sends messages pushed into chats by other systems, such as alerts
+type MessagingService struct {
+	whatsapp       domain.WhatsAppClient
+	groupMgr       domain.GroupManager
+	repository     domain.MessageRepository
+	directMessages domain.DirectMessageConfig
+	sent           map[string]*idempotentSend // by client and idempotency key
+	mu             sync.Mutex
+	logger         *slog.Logger
+}
+
+// NewMessagingService creates a new messaging service
+func NewMessagingService(whatsapp domain.WhatsAppClient, groupMgr domain.GroupManager, repository domain.MessageRepository, logger *slog.Logger) *MessagingService {
+	return &MessagingService{
+		whatsapp:   whatsapp,
+		groupMgr:   groupMgr,
+		repository: repository,
+		sent:       make(map[string]*idempotentSend),
+		logger:     logger,
+	}
+}
+
+// UpdateDirectMessages updates which contacts may be messaged directly
+func (s *MessagingService) UpdateDirectMessages(cfg domain.DirectMessageConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.directMessages = cfg
+}
+
+// Send sends a message to a chat for a client. A repeated idempotency key of the same
+// client returns the first result without sending the message again.
+func (s *MessagingService) Send(ctx context.Context, client, idempotencyKey string, message *domain.OutgoingMessage) (*domain.SentMessage, error) {
+	if message.ChatJID == "" || !strings.Contains(message.ChatJID, "@") || (strings.TrimSpace(message.Text) == "" && message.Media == nil) {
+		return nil, ErrInvalidOutgoingMessage
+	}
+	if !s.chatAllowed(message.ChatJID) {
+		return nil, ErrChatNotAllowed
+	}
+
+	// Clients cannot see or block each other's keys
+	cacheKey := client + "\x00" + idempotencyKey
+	if idempotencyKey != "" {
+		previous, err := s.reserve(cacheKey)
+		if err != nil || previous != nil {
+			return previous, err
+		}
+	}
+
+	result, err := s.send(ctx, message)
+	if idempotencyKey != "" {
+		s.complete(cacheKey, idempotencyKey, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.IdempotencyKey = idempotencyKey
+	return result, nil
+}
+
+// chatAllowed reports whether the bot may message a chat: an allowed group, or a contact
+// on the direct message allowlist while direct messages are enabled
+func (s *MessagingService) chatAllowed(chatJID string) bool {
+	if strings.HasSuffix(chatJID, "@g.us") {
+		return s.groupMgr.IsAllowed(chatJID)
+	}
+
+	s.mu.Lock()
+	directMessages := s.directMessages
+	s.mu.Unlock()
+	return directMessages.Enabled && isContactAllowed(directMessages.AllowedContacts, chatJID)
+}
+
+// send sends the message and saves it to the chat's history
+func (s *MessagingService) send(ctx context.Context, message *domain.OutgoingMessage) (*domain.SentMessage, error) {
+	result := &domain.SentMessage{ChatJID: message.ChatJID, Type: "text", SentAt: time.Now()}
+
+	var err error
+	switch {
+	case message.Media != nil:
+		result.Type = string(message.Media.Type)
+		err = s.whatsapp.SendMedia(ctx, message.ChatJID, message.Media, message.Text, message.ReplyToMessageID, message.ReplyToSender)
+	case len(message.Mentions) > 0:
+		err = s.whatsapp.SendMentions(ctx, message.ChatJID, message.Text, domain.MentionJIDs(message.Mentions), message.ReplyToMessageID, message.ReplyToSender)
+	case message.ReplyToMessageID != "":
+		err = s.whatsapp.SendReply(ctx, message.ChatJID, message.Text, message.ReplyToMessageID, message.ReplyToSender)
+	default:
+		err = s.whatsapp.SendMessage(ctx, message.ChatJID, message.Text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	s.logger.Info("Message sent through API", "chat", message.ChatJID, "type", result.Type)
+
+	// Keep pushed messages in the history so the bot knows about them when asked
+	content := message.Text
+	if message.Media != nil {
+		content = strings.TrimSpace(mediaSentLabel(message.Media) + " " + message.Text)
+	}
+	botMessage := &domain.Message{
+		ID:        "api-" + uuid.New().String(),
+		GroupJID:  message.ChatJID,
+		ChatJID:   message.ChatJID,
+		IsDirect:  !strings.HasSuffix(message.ChatJID, "@g.us"),
+		Sender:    "bot",
+		Content:   content,
+		Timestamp: result.SentAt,
+		IsFromBot: true,
+	}
+	if err := s.repository.Save(ctx, botMessage); err != nil {
+		s.logger.Error("Failed to save sent message", "error", err)
+	}
+
+	return result, nil
+}
+
+// reserve claims an idempotency key, returning the earlier result if it was used before
+func (s *MessagingService) reserve(key string) (*domain.SentMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, sent := range s.sent {
+		if sent.result != nil && now.After(sent.expires) {
+			delete(s.sent, k)
+		}
+	}
+
+	if sent, ok := s.sent[key]; ok {
+		if sent.result == nil {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		duplicate := *sent.result
+		duplicate.Duplicate = true
+		return &duplicate, nil
+	}
+
+	s.sent[key] = &idempotentSend{}
+	return nil, nil
+}
+
+// complete records the result of an idempotency key, or releases it if sending failed
+func (s *MessagingService) complete(cacheKey, idempotencyKey string, result *domain.SentMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result == nil {
+		delete(s.sent, cacheKey)
+		return
+	}
+
+	stored := *result
+	stored.IdempotencyKey = idempotencyKey
+	s.sent[cacheKey] = &idempotentSend{result: &stored, expires: time.Now().Add(idempotencyKeyTTL)}
+}
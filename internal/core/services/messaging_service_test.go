@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestMessagingService_Send(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	whatsapp := &MockWhatsAppClient{}
+	repository := &MockMessageRepository{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"ops@g.us": true}}
+	service := NewMessagingService(whatsapp, groupMgr, repository, logger)
+
+	alert := &domain.OutgoingMessage{ChatJID: "ops@g.us", Text: "Disk full on @491701234567's box", Mentions: []string{"+491701234567"}}
+	result, err := service.Send(ctx, "ci", "alert-1", alert)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if result.Duplicate || result.IdempotencyKey != "alert-1" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(whatsapp.mentions) != 1 || whatsapp.mentions[0][0] != "491701234567@s.whatsapp.net" {
+		t.Errorf("Expected a mention of the user JID, got %v", whatsapp.mentions)
+	}
+	if len(repository.messages) != 1 || !repository.messages[0].IsFromBot {
+		t.Errorf("Expected the message in the history, got %v", repository.messages)
+	}
+
+	// A retry with the same key is not sent again
+	result, err = service.Send(ctx, "ci", "alert-1", alert)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !result.Duplicate || len(whatsapp.sentMessages) != 1 {
+		t.Errorf("Expected a duplicate without sending, got %+v and %v", result, whatsapp.sentMessages)
+	}
+
+	image := &domain.OutgoingMessage{ChatJID: "ops@g.us", Text: "CPU graph", Media: &domain.Media{Type: domain.MediaTypeImage, MimeType: "image/png", Data: []byte("png")}}
+	if result, err := service.Send(ctx, "ci", "", image); err != nil || result.Type != "image" {
+		t.Fatalf("Send() = %+v, %v", result, err)
+	}
+	if len(whatsapp.sentMedia) != 1 {
+		t.Errorf("Expected the image to be sent, got %v", whatsapp.sentMedia)
+	}
+
+	if _, err := service.Send(ctx, "ci", "", &domain.OutgoingMessage{ChatJID: "other@g.us", Text: "hi"}); !errors.Is(err, ErrChatNotAllowed) {
+		t.Errorf("Expected ErrChatNotAllowed, got %v", err)
+	}
+	if _, err := service.Send(ctx, "ci", "", &domain.OutgoingMessage{ChatJID: "ops@g.us"}); !errors.Is(err, ErrInvalidOutgoingMessage) {
+		t.Errorf("Expected ErrInvalidOutgoingMessage, got %v", err)
+	}
+
+	// Another client's key does not collide
+	if result, err := service.Send(ctx, "grafana", "alert-1", alert); err != nil || result.Duplicate {
+		t.Errorf("Expected another client's alert-1 to be sent, got %+v, %v", result, err)
+	}
+}
+
+func TestMessagingService_SendDirect(t *testing.T) {
+	ctx := context.Background()
+	whatsapp := &MockWhatsAppClient{}
+	service := NewMessagingService(whatsapp, &MockGroupManager{}, &MockMessageRepository{}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	message := &domain.OutgoingMessage{ChatJID: "491701234567@s.whatsapp.net", Text: "hi"}
+
+	// Direct messages are off by default
+	if _, err := service.Send(ctx, "ci", "", message); !errors.Is(err, ErrChatNotAllowed) {
+		t.Errorf("Expected ErrChatNotAllowed with direct messages disabled, got %v", err)
+	}
+
+	service.UpdateDirectMessages(domain.DirectMessageConfig{Enabled: true, AllowedContacts: []string{"491701234567"}})
+	if _, err := service.Send(ctx, "ci", "", message); err != nil {
+		t.Errorf("Expected an allowed contact to be messaged, got %v", err)
+	}
+	if _, err := service.Send(ctx, "ci", "", &domain.OutgoingMessage{ChatJID: "15550001111@s.whatsapp.net", Text: "hi"}); !errors.Is(err, ErrChatNotAllowed) {
+		t.Errorf("Expected ErrChatNotAllowed for a contact not on the allowlist, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// defaultRateLimitPeriod is the refill period of the rate limits
+const defaultRateLimitPeriod = time.Minute
+
+// defaultCooldownMessage is sent when a sender or group hits a rate limit
+const defaultCooldownMessage = "I'm getting a lot of requests right now. Please try again in {wait}."
+
+// defaultQuotaMessage is sent when a sender or group has used up its daily quota
+const defaultQuotaMessage = "You've reached today's limit of {quota} requests. It resets at midnight."
+
+// silentLimitMessage disables a cooldown or quota reply
+const silentLimitMessage = "-"
+
+// rateLimitPruneInterval is how often idle buckets and old quotas are dropped
+const rateLimitPruneInterval = 10 * time.Minute
+
+// Rate limit decisions
+const (
+	RateLimitAllowed     = "allowed"
+	RateLimitExempt      = "exempt"
+	RateLimitSenderRate  = "sender_rate"
+	RateLimitGroupRate   = "group_rate"
+	RateLimitSenderQuota = "sender_quota"
+	RateLimitGroupQuota  = "group_quota"
+)
+
+// rateLimitDecisions counts the rate limiter's decisions per allowed group; direct messages
+// share the "direct" label so every contact does not add a series
+var rateLimitDecisions = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "whatsapp_rate_limit_decisions_total",
+		Help: "Total number of bot requests by rate limit decision (allowed, exempt, sender_rate, group_rate, sender_quota, group_quota)",
+	},
+	[]string{"chat", "decision"},
+)
+
+// RateLimitDecision is the rate limiter's verdict on a request
+type RateLimitDecision struct {
+	Allowed    bool
+	Reason     string        // one of the RateLimit* decisions
+	RetryAfter time.Duration // until the request would be allowed
+	Reply      string        // cooldown or quota reply to send, empty if the sender was already told
+}
+
+// RateLimiter limits how often senders and groups can make the bot answer, with token buckets
+// for bursts and daily quotas. State is kept in memory, so a restart resets it.
+type RateLimiter struct {
+	enabled          bool
+	period           time.Duration
+	senderLimit      int
+	senderBurst      int
+	groupLimit       int
+	groupBurst       int
+	senderDailyQuota int
+	groupDailyQuota  int
+	cooldownMessage  string
+	quotaMessage     string
+	exempt           []string
+
+	senders  map[string]*tokenBucket
+	groups   map[string]*tokenBucket
+	daily    map[string]*dailyCount
+	notified map[string]time.Time // when each sender or group may be told about a limit again
+	pruned   time.Time
+	now      func() time.Time
+	mu       sync.Mutex
+	logger   *slog.Logger
+}
+
+// NewRateLimiter creates a new rate limiter
+func NewRateLimiter(cfg domain.RateLimitConfig, logger *slog.Logger) *RateLimiter {
+	l := &RateLimiter{
+		senders:  make(map[string]*tokenBucket),
+		groups:   make(map[string]*tokenBucket),
+		daily:    make(map[string]*dailyCount),
+		notified: make(map[string]time.Time),
+		now:      time.Now,
+		logger:   logger,
+	}
+	l.UpdateConfig(cfg)
+	return l
+}
+
+// UpdateConfig updates the limits. Buckets keep their state and adapt to new rates as they refill.
+func (l *RateLimiter) UpdateConfig(cfg domain.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.enabled = cfg.Enabled
+
+	l.period = defaultRateLimitPeriod
+	if cfg.Period != "" {
+		if period, err := time.ParseDuration(cfg.Period); err == nil && period > 0 {
+			l.period = period
+		}
+	}
+
+	l.senderLimit = cfg.SenderLimit
+	l.senderBurst = cfg.SenderBurst
+	if l.senderBurst <= 0 {
+		l.senderBurst = cfg.SenderLimit
+	}
+	l.groupLimit = cfg.GroupLimit
+	l.groupBurst = cfg.GroupBurst
+	if l.groupBurst <= 0 {
+		l.groupBurst = cfg.GroupLimit
+	}
+	l.senderDailyQuota = cfg.SenderDailyQuota
+	l.groupDailyQuota = cfg.GroupDailyQuota
+
+	l.cooldownMessage = cfg.CooldownMessage
+	if l.cooldownMessage == "" {
+		l.cooldownMessage = defaultCooldownMessage
+	}
+	l.quotaMessage = cfg.QuotaMessage
+	if l.quotaMessage == "" {
+		l.quotaMessage = defaultQuotaMessage
+	}
+
+	l.exempt = cfg.Exempt
+}
+
+// Allow decides whether a sender may make the bot answer in a chat and, if so, takes the
+// request from the sender's and group's buckets and quotas. Group limits do not apply to
+// direct messages.
+func (l *RateLimiter) Allow(sender, chatJID string, isDirect bool) RateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	decision := l.decide(sender, chatJID, isDirect)
+	chatLabel := chatJID
+	if isDirect {
+		chatLabel = "direct"
+	}
+	rateLimitDecisions.With(prometheus.Labels{"chat": chatLabel, "decision": decision.Reason}).Inc()
+
+	if !decision.Allowed {
+		l.logger.Info("Rate limited request",
+			"sender", sender,
+			"chat", chatJID,
+			"reason", decision.Reason,
+			"retry_after", decision.RetryAfter)
+	}
+	return decision
+}
+
+func (l *RateLimiter) decide(sender, chatJID string, isDirect bool) RateLimitDecision {
+	if !l.enabled {
+		return RateLimitDecision{Allowed: true, Reason: RateLimitAllowed}
+	}
+	if isContactAllowed(l.exempt, sender) {
+		return RateLimitDecision{Allowed: true, Reason: RateLimitExempt}
+	}
+
+	now := l.now()
+	l.prune(now)
+	day := now.Format("2006-01-02")
+
+	// Check every limit before taking anything, so a denied request costs nothing
+	var senderBucket, groupBucket *tokenBucket
+	if l.senderLimit > 0 {
+		senderBucket = l.bucket(l.senders, sender, l.senderLimit, l.senderBurst, now)
+		if wait := senderBucket.wait(); wait > 0 {
+			return l.deny(sender, RateLimitSenderRate, wait, now)
+		}
+	}
+	if !isDirect && l.groupLimit > 0 {
+		groupBucket = l.bucket(l.groups, chatJID, l.groupLimit, l.groupBurst, now)
+		if wait := groupBucket.wait(); wait > 0 {
+			return l.deny(chatJID, RateLimitGroupRate, wait, now)
+		}
+	}
+
+	senderCount := l.dailyCount("sender:"+sender, day)
+	if l.senderDailyQuota > 0 && senderCount.count >= l.senderDailyQuota {
+		return l.deny(sender, RateLimitSenderQuota, untilMidnight(now), now)
+	}
+	groupCount := l.dailyCount("group:"+chatJID, day)
+	if !isDirect && l.groupDailyQuota > 0 && groupCount.count >= l.groupDailyQuota {
+		return l.deny(chatJID, RateLimitGroupQuota, untilMidnight(now), now)
+	}
+
+	if senderBucket != nil {
+		senderBucket.tokens--
+	}
+	if groupBucket != nil {
+		groupBucket.tokens--
+	}
+	senderCount.count++
+	if !isDirect {
+		groupCount.count++
+	}
+	return RateLimitDecision{Allowed: true, Reason: RateLimitAllowed}
+}
+
+// deny builds a denial, with a reply unless the limited sender or group was already told
+// about this limit. Group limits are announced once per group, not to every sender.
+func (l *RateLimiter) deny(limited, reason string, wait time.Duration, now time.Time) RateLimitDecision {
+	decision := RateLimitDecision{Reason: reason, RetryAfter: wait}
+
+	key := limited + "|" + reason
+	if now.Before(l.notified[key]) {
+		return decision
+	}
+	l.notified[key] = now.Add(wait)
+
+	switch reason {
+	case RateLimitSenderQuota:
+		decision.Reply = strings.ReplaceAll(l.quotaMessage, "{quota}", strconv.Itoa(l.senderDailyQuota))
+	case RateLimitGroupQuota:
+		decision.Reply = strings.ReplaceAll(l.quotaMessage, "{quota}", strconv.Itoa(l.groupDailyQuota))
+	default:
+		decision.Reply = strings.ReplaceAll(l.cooldownMessage, "{wait}", formatWait(wait))
+	}
+	if strings.TrimSpace(decision.Reply) == silentLimitMessage {
+		decision.Reply = ""
+	}
+	return decision
+}
+
+// bucket returns the refilled bucket for key, creating a full one if needed
+func (l *RateLimiter) bucket(buckets map[string]*tokenBucket, key string, limit, burst int, now time.Time) *tokenBucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), updated: now}
+		buckets[key] = b
+	}
+	b.refill(float64(limit)/l.period.Seconds(), float64(burst), now)
+	return b
+}
+
+// dailyCount returns today's request count for key, resetting it on a new day
+func (l *RateLimiter) dailyCount(key, day string) *dailyCount {
+	c, ok := l.daily[key]
+	if !ok || c.day != day {
+		c = &dailyCount{day: day}
+		l.daily[key] = c
+	}
+	return c
+}
+
+// prune drops full buckets, past days and expired notifications so idle senders cost no memory
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < rateLimitPruneInterval {
+		return
+	}
+	l.pruned = now
+
+	l.pruneBuckets(l.senders, l.senderLimit, l.senderBurst, now)
+	l.pruneBuckets(l.groups, l.groupLimit, l.groupBurst, now)
+
+	day := now.Format("2006-01-02")
+	for key, c := range l.daily {
+		if c.day != day {
+			delete(l.daily, key)
+		}
+	}
+	for key, until := range l.notified {
+		if !now.Before(until) {
+			delete(l.notified, key)
+		}
+	}
+}
+
+// pruneBuckets drops the buckets that have refilled completely
+func (l *RateLimiter) pruneBuckets(buckets map[string]*tokenBucket, limit, burst int, now time.Time) {
+	for key, b := range buckets {
+		b.refill(float64(limit)/l.period.Seconds(), float64(burst), now)
+		if b.tokens >= float64(burst) {
+			delete(buckets, key)
+		}
+	}
+}
+
+// tokenBucket holds up to burst tokens, refilled continuously; each request takes one
+type tokenBucket struct {
+	tokens  float64
+	rate    float64 // tokens per second
+	updated time.Time
+}
+
+// refill adds the tokens accrued since the last update
+func (b *tokenBucket) refill(rate, burst float64, now time.Time) {
+	b.rate = rate
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+	}
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.updated = now
+}
+
+// wait returns how long until a token is available, 0 if one is
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if b.rate <= 0 {
+		return defaultRateLimitPeriod
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// dailyCount counts the requests of a sender or group on one day
+type dailyCount struct {
+	day   string
+	count int
+}
+
+// untilMidnight returns the time until the next local midnight, when quotas reset
+func untilMidnight(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
+
+// formatWait formats a wait for users, rounded up to whole seconds or minutes
+func formatWait(wait time.Duration) string {
+	if wait < time.Minute {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		if seconds == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	minutes := int((wait + time.Minute - 1) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
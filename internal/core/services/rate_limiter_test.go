@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// newTestRateLimiter creates a rate limiter on a controllable clock
+func newTestRateLimiter(cfg domain.RateLimitConfig) (*RateLimiter, *time.Time) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	limiter := NewRateLimiter(cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiter_SenderBucket(t *testing.T) {
+	limiter, now := newTestRateLimiter(domain.RateLimitConfig{Enabled: true, SenderLimit: 1, SenderBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		if decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false); !decision.Allowed {
+			t.Fatalf("Expected request %d within the burst to be allowed, got %+v", i+1, decision)
+		}
+	}
+
+	decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false)
+	if decision.Allowed || decision.Reason != RateLimitSenderRate || decision.Reply != "I'm getting a lot of requests right now. Please try again in 1 minute." {
+		t.Fatalf("Expected a sender cooldown with a reply, got %+v", decision)
+	}
+	if decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false); decision.Allowed || decision.Reply != "" {
+		t.Errorf("Expected the cooldown reply to be sent once, got %+v", decision)
+	}
+	if decision := limiter.Allow("bob@s.whatsapp.net", "group@g.us", false); !decision.Allowed {
+		t.Errorf("Expected other senders to be unaffected, got %+v", decision)
+	}
+
+	*now = now.Add(time.Minute)
+	if decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false); !decision.Allowed {
+		t.Errorf("Expected the bucket to refill after a period, got %+v", decision)
+	}
+}
+
+func TestRateLimiter_GroupBucket(t *testing.T) {
+	limiter, _ := newTestRateLimiter(domain.RateLimitConfig{Enabled: true, GroupLimit: 2})
+
+	limiter.Allow("alice@s.whatsapp.net", "group@g.us", false)
+	limiter.Allow("bob@s.whatsapp.net", "group@g.us", false)
+
+	if decision := limiter.Allow("carol@s.whatsapp.net", "group@g.us", false); decision.Allowed || decision.Reason != RateLimitGroupRate || decision.Reply == "" {
+		t.Errorf("Expected the group limit to apply across senders, got %+v", decision)
+	}
+	if decision := limiter.Allow("dave@s.whatsapp.net", "group@g.us", false); decision.Allowed || decision.Reply != "" {
+		t.Errorf("Expected the group to be told about its limit once, got %+v", decision)
+	}
+	if decision := limiter.Allow("carol@s.whatsapp.net", "carol@s.whatsapp.net", true); !decision.Allowed {
+		t.Errorf("Expected group limits not to apply to direct messages, got %+v", decision)
+	}
+}
+
+func TestRateLimiter_DailyQuota(t *testing.T) {
+	limiter, now := newTestRateLimiter(domain.RateLimitConfig{Enabled: true, SenderDailyQuota: 2, QuotaMessage: "Limit of {quota} reached"})
+
+	limiter.Allow("alice@s.whatsapp.net", "group@g.us", false)
+	limiter.Allow("alice@s.whatsapp.net", "group@g.us", false)
+
+	decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false)
+	if decision.Allowed || decision.Reason != RateLimitSenderQuota || decision.Reply != "Limit of 2 reached" || decision.RetryAfter != 12*time.Hour {
+		t.Fatalf("Expected the quota to be used up until midnight, got %+v", decision)
+	}
+
+	*now = now.Add(12 * time.Hour)
+	if decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false); !decision.Allowed {
+		t.Errorf("Expected the quota to reset at midnight, got %+v", decision)
+	}
+}
+
+func TestRateLimiter_ExemptAndDisabled(t *testing.T) {
+	limiter, _ := newTestRateLimiter(domain.RateLimitConfig{Enabled: true, SenderLimit: 1, Exempt: []string{"491701234567"}})
+
+	for i := 0; i < 3; i++ {
+		if decision := limiter.Allow("491701234567@s.whatsapp.net", "group@g.us", false); !decision.Allowed || decision.Reason != RateLimitExempt {
+			t.Fatalf("Expected exempt senders to be allowed, got %+v", decision)
+		}
+	}
+
+	limiter.UpdateConfig(domain.RateLimitConfig{SenderLimit: 1})
+	for i := 0; i < 3; i++ {
+		if decision := limiter.Allow("alice@s.whatsapp.net", "group@g.us", false); !decision.Allowed {
+			t.Fatalf("Expected no limits while disabled, got %+v", decision)
+		}
+	}
+}
+
+func TestChatService_ProcessMessage_RateLimited(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	llm := &MockLLMProvider{response: "Hi!"}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+
+	service := NewChatService(llm, &MockMessageRepository{}, whatsapp, groupMgr, &MockWebhookClient{}, []string{"@sasi"}, nil, logger)
+	service.SetRateLimiter(NewRateLimiter(domain.RateLimitConfig{Enabled: true, SenderLimit: 1, CooldownMessage: "Slow down"}, logger))
+
+	for i := 0; i < 2; i++ {
+		err := service.ProcessMessage(ctx, &domain.Message{
+			ID:        "msg1",
+			GroupJID:  "test-group@g.us",
+			Sender:    "user@s.whatsapp.net",
+			Content:   "@sasi hello",
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("ProcessMessage() error = %v", err)
+		}
+	}
+
+	if len(whatsapp.sentMessages) != 2 || whatsapp.sentMessages[0] != "Hi!" || whatsapp.sentMessages[1] != "Slow down" {
+		t.Errorf("Expected one answer and one cooldown reply, got %v", whatsapp.sentMessages)
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -16,6 +17,8 @@ type SchedulerService struct {
 	repository    domain.ScheduleRepository
 	webhookClient domain.WebhookClient
 	whatsapp      domain.WhatsAppClient
+	llmProvider   domain.LLMProvider // answers "ask" parts of webhook responses, optional
+	webhooks      []domain.WebhookConfig
 	logger        *slog.Logger
 	ticker        *time.Ticker
 	stopChan      chan struct{}
@@ -39,6 +42,36 @@ func NewSchedulerService(
 	}
 }
 
+// SetLLMProvider lets scheduled webhooks answer "ask" response parts with the LLM
+func (s *SchedulerService) SetLLMProvider(llmProvider domain.LLMProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.llmProvider = llmProvider
+}
+
+// UpdateWebhooks sets the configured webhooks, whose credentials are used when a schedule calls their URL
+func (s *SchedulerService) UpdateWebhooks(webhooks []domain.WebhookConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks = webhooks
+}
+
+// webhookFor returns the configured webhook with the URL, or an unauthenticated one
+func (s *SchedulerService) webhookFor(url string) *domain.WebhookConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.webhooks {
+		if s.webhooks[i].URL == url {
+			webhook := s.webhooks[i]
+			return &webhook
+		}
+	}
+	return &domain.WebhookConfig{URL: url}
+}
+
 // Start starts the scheduler
 func (s *SchedulerService) Start(ctx context.Context) error {
 	s.mu.Lock()
@@ -235,6 +268,12 @@ func (s *SchedulerService) executeSchedule(ctx context.Context, schedule *domain
 		s.logger.Error("Failed to update last run", "error", err, "schedule_id", schedule.ID)
 	}
 
+	// Schedules without a webhook are plain reminders: post the prompt as is
+	if schedule.WebhookURL == "" {
+		s.sendReminder(ctx, schedule, execution)
+		return
+	}
+
 	// Call webhook with custom prompt if enabled
 	var message string
 	if schedule.UsePrompt {
@@ -243,48 +282,63 @@ func (s *SchedulerService) executeSchedule(ctx context.Context, schedule *domain
 	} else {
 		s.logger.Info("Calling webhook with empty message (prompt disabled)", "schedule_id", schedule.ID)
 	}
-	response, err := s.webhookClient.Call(ctx, schedule.WebhookURL, message)
+	webhook := s.webhookFor(schedule.WebhookURL)
+	response, err := s.webhookClient.Call(ctx, webhook, &domain.WebhookRequest{
+		Version:   webhookPayloadVersion(webhook),
+		Message:   message,
+		Source:    webhookSourceSchedule,
+		ChatJID:   schedule.GroupJID,
+		GroupJID:  schedule.GroupJID,
+		Timestamp: execution.ExecutedAt,
+	})
 	if err != nil {
 		s.logger.Error("Failed to call webhook for schedule",
 			"error", err,
 			"schedule_id", schedule.ID,
 			"webhook_url", schedule.WebhookURL)
 
+		var webhookErr *domain.WebhookError
+		if errors.As(err, &webhookErr) {
+			execution.Attempts = webhookErr.Attempts
+		}
 		execution.Success = false
 		execution.Error = err.Error()
 		s.repository.LogExecution(ctx, execution)
 		return
 	}
-
-	// Handle response based on content type
-	var responseContent string
-	if response.ContentType == "image/jpeg" || response.ContentType == "image/png" {
-		// Send as image
-		s.logger.Info("Sending scheduled image",
-			"size", len(response.Content),
-			"mime", response.ContentType,
-			"group", schedule.GroupJID)
-
-		if err := s.whatsapp.SendImage(ctx, schedule.GroupJID, response.Content, response.ContentType, "", "", ""); err != nil {
-			s.logger.Error("Failed to send scheduled image", "error", err)
-			execution.Success = false
-			execution.Error = fmt.Sprintf("failed to send image: %v", err)
-			s.repository.LogExecution(ctx, execution)
-			return
-		}
-		responseContent = "[Image sent]"
-	} else {
-		// Format and send as text
-		formattedText := FormatWebhookResponse(response.TextContent)
-
-		if err := s.whatsapp.SendMessage(ctx, schedule.GroupJID, formattedText); err != nil {
-			s.logger.Error("Failed to send scheduled message", "error", err)
-			execution.Success = false
-			execution.Error = fmt.Sprintf("failed to send message: %v", err)
-			s.repository.LogExecution(ctx, execution)
-			return
+	execution.Attempts = response.Attempts
+
+	// Send each part of the response to the group
+	s.mu.RLock()
+	llmProvider := s.llmProvider
+	s.mu.RUnlock()
+
+	var ask askFunc
+	if llmProvider != nil {
+		ask = func(ctx context.Context, prompt string) (string, error) {
+			ctx = withLLMJob(ctx, schedule.GroupJID, LLMPriorityScheduled, nil)
+			response, err := llmProvider.Generate(ctx, &domain.LLMRequest{
+				SystemPrompt: defaultSystemPrompt,
+				Messages:     []domain.ChatMessage{{Role: domain.ChatRoleUser, Content: prompt}},
+			})
+			if err != nil {
+				return "", err
+			}
+			if response.Error != nil {
+				return "", response.Error
+			}
+			return response.Content, nil
 		}
-		responseContent = formattedText
+	}
+
+	responseContent, err := sendWebhookResponse(ctx, s.whatsapp, s.logger, webhookTarget{chatJID: schedule.GroupJID}, response, ask)
+	if err != nil {
+		s.logger.Error("Failed to send scheduled webhook response", "error", err)
+		execution.Success = false
+		execution.Error = err.Error()
+		execution.Response = responseContent
+		s.repository.LogExecution(ctx, execution)
+		return
 	}
 
 	// Log successful execution
@@ -299,6 +353,25 @@ func (s *SchedulerService) executeSchedule(ctx context.Context, schedule *domain
 		"name", schedule.Name)
 }
 
+// sendReminder posts a schedule's prompt to its group and logs the execution
+func (s *SchedulerService) sendReminder(ctx context.Context, schedule *domain.Schedule, execution *domain.ScheduleExecution) {
+	if err := s.whatsapp.SendMessage(ctx, schedule.GroupJID, schedule.Prompt); err != nil {
+		s.logger.Error("Failed to send reminder", "error", err, "schedule_id", schedule.ID)
+		execution.Success = false
+		execution.Error = fmt.Sprintf("failed to send message: %v", err)
+		s.repository.LogExecution(ctx, execution)
+		return
+	}
+
+	execution.Success = true
+	execution.Response = schedule.Prompt
+	if err := s.repository.LogExecution(ctx, execution); err != nil {
+		s.logger.Error("Failed to log execution", "error", err)
+	}
+
+	s.logger.Info("Reminder sent", "schedule_id", schedule.ID, "name", schedule.Name)
+}
+
 // CreateSchedule creates a new schedule
 func (s *SchedulerService) CreateSchedule(ctx context.Context, schedule *domain.Schedule) error {
 	schedule.ID = uuid.New().String()
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// defaultSummaryInterval is how often groups are checked for new history to summarize
+	defaultSummaryInterval = 15 * time.Minute
+
+	// defaultSummaryKeepRecent is the number of newest messages left to the regular context
+	defaultSummaryKeepRecent = 20
+
+	// defaultSummaryMinMessages is the number of new messages required to refresh a summary
+	defaultSummaryMinMessages = 20
+
+	// summaryFetchLimit caps the history read per group on each run
+	summaryFetchLimit = 500
+)
+
+// summarySystemPrompt instructs the model how to condense a conversation
+const summarySystemPrompt = "You maintain a running summary of a WhatsApp group chat. " +
+	"Combine the previous summary with the new messages into a single updated summary. " +
+	"Keep names, decisions, dates, plans, open questions and facts people may refer back to. " +
+	"Drop greetings and small talk. Write plain prose or short bullet points, at most 200 words. " +
+	"Reply with the summary only."
+
+// SummaryService periodically condenses older group history into a stored summary
+type SummaryService struct {
+	llmProvider domain.LLMProvider
+	messages    domain.MessageRepository
+	summaries   domain.SummaryRepository
+	groupMgr    domain.GroupManager
+	interval    time.Duration
+	keepRecent  int
+	minMessages int
+	logger      *slog.Logger
+	ticker      *time.Ticker
+	stopChan    chan struct{}
+	running     bool
+	groupLocks  sync.Map // groupJID -> *sync.Mutex, serializes runs per group
+	mu          sync.RWMutex
+}
+
+// NewSummaryService creates a new summary service. Zero config values fall back to defaults.
+func NewSummaryService(
+	llmProvider domain.LLMProvider,
+	messages domain.MessageRepository,
+	summaries domain.SummaryRepository,
+	groupMgr domain.GroupManager,
+	cfg domain.SummaryConfig,
+	logger *slog.Logger,
+) *SummaryService {
+	s := &SummaryService{
+		llmProvider: llmProvider,
+		messages:    messages,
+		summaries:   summaries,
+		groupMgr:    groupMgr,
+		logger:      logger,
+	}
+	s.UpdateConfig(cfg)
+	return s
+}
+
+// UpdateConfig updates the summary settings; a changed interval restarts the running timer
+func (s *SummaryService) UpdateConfig(cfg domain.SummaryConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	interval := defaultSummaryInterval
+	if cfg.Interval != "" {
+		if parsed, err := time.ParseDuration(cfg.Interval); err == nil && parsed > 0 {
+			interval = parsed
+		}
+	}
+	if s.running && interval != s.interval {
+		s.ticker.Reset(interval)
+		s.logger.Info("Summary interval updated", "interval", interval)
+	}
+	s.interval = interval
+
+	s.keepRecent = cfg.KeepRecent
+	if s.keepRecent <= 0 {
+		s.keepRecent = defaultSummaryKeepRecent
+	}
+
+	s.minMessages = cfg.MinMessages
+	if s.minMessages <= 0 {
+		s.minMessages = defaultSummaryMinMessages
+	}
+}
+
+// Start starts the periodic summarization
+func (s *SummaryService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("summary service already running")
+	}
+
+	s.logger.Info("Starting summary service", "interval", s.interval)
+
+	s.ticker = time.NewTicker(s.interval)
+	s.stopChan = make(chan struct{})
+	s.running = true
+
+	go s.run(ctx, s.ticker, s.stopChan)
+
+	return nil
+}
+
+// Stop stops the periodic summarization
+func (s *SummaryService) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.logger.Info("Stopping summary service")
+	close(s.stopChan)
+	s.ticker.Stop()
+	s.running = false
+
+	return nil
+}
+
+// UpdateEnabled starts or stops the periodic summarization, e.g. when summary.enabled
+// is toggled in the config
+func (s *SummaryService) UpdateEnabled(ctx context.Context, enabled bool) {
+	s.mu.RLock()
+	running := s.running
+	s.mu.RUnlock()
+
+	switch {
+	case enabled && !running:
+		if err := s.Start(ctx); err != nil {
+			s.logger.Error("Failed to start summary service", "error", err)
+		}
+	case !enabled && running:
+		s.Stop()
+	}
+}
+
+// run is the main summarization loop
+func (s *SummaryService) run(ctx context.Context, ticker *time.Ticker, stop <-chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			s.summarizeAll(ctx)
+		case <-stop:
+			s.logger.Info("Summary service stopped")
+			return
+		case <-ctx.Done():
+			s.logger.Info("Summary service context cancelled")
+			return
+		}
+	}
+}
+
+// summarizeAll refreshes the summary of every allowed group
+func (s *SummaryService) summarizeAll(ctx context.Context) {
+	for _, groupJID := range s.groupMgr.GetAllowedGroups() {
+		if _, err := s.SummarizeGroup(ctx, groupJID); err != nil {
+			s.logger.Error("Failed to summarize group", "group", groupJID, "error", err)
+		}
+	}
+}
+
+// GetSummary returns the stored summary for a group, or nil if there is none
+func (s *SummaryService) GetSummary(ctx context.Context, groupJID string) (*domain.ConversationSummary, error) {
+	return s.summaries.Get(ctx, groupJID)
+}
+
+// ResetSummary deletes the stored summary for a group.
+// The next run starts over from the history still held in the message repository.
+func (s *SummaryService) ResetSummary(ctx context.Context, groupJID string) error {
+	lock := s.groupLock(groupJID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.logger.Info("Resetting conversation summary", "group", groupJID)
+	return s.summaries.Delete(ctx, groupJID)
+}
+
+// SummarizeGroup folds messages older than the most recent ones into the group's summary.
+// It returns the current summary, which is unchanged if there was not enough new history.
+func (s *SummaryService) SummarizeGroup(ctx context.Context, groupJID string) (*domain.ConversationSummary, error) {
+	lock := s.groupLock(groupJID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.mu.RLock()
+	keepRecent, minMessages := s.keepRecent, s.minMessages
+	s.mu.RUnlock()
+
+	summary, err := s.summaries.Get(ctx, groupJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summary: %w", err)
+	}
+
+	history, err := s.messages.GetByChatJID(ctx, groupJID, summaryFetchLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	if len(history) <= keepRecent {
+		return summary, nil
+	}
+	older := history[:len(history)-keepRecent]
+
+	// Only messages not yet covered by the existing summary
+	pending := make([]*domain.Message, 0, len(older))
+	for _, msg := range older {
+		if summary == nil || msg.Timestamp.After(summary.CoveredUntil) {
+			pending = append(pending, msg)
+		}
+	}
+
+	if len(pending) < minMessages {
+		return summary, nil
+	}
+
+	s.logger.Info("Summarizing conversation", "group", groupJID, "messages", len(pending))
+
+	response, err := s.llmProvider.Generate(withLLMJob(ctx, groupJID, LLMPriorityBackground, nil), &domain.LLMRequest{
+		SystemPrompt: summarySystemPrompt,
+		Messages: []domain.ChatMessage{{
+			Role:    domain.ChatRoleUser,
+			Content: buildSummaryPrompt(summary, pending),
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", response.Error)
+	}
+
+	updated := &domain.ConversationSummary{
+		GroupJID:     groupJID,
+		Content:      strings.TrimSpace(response.Content),
+		CoveredUntil: pending[len(pending)-1].Timestamp,
+		MessageCount: len(pending),
+		UpdatedAt:    time.Now(),
+	}
+	if summary != nil {
+		updated.MessageCount += summary.MessageCount
+	}
+
+	if err := s.summaries.Save(ctx, updated); err != nil {
+		return nil, fmt.Errorf("failed to save summary: %w", err)
+	}
+
+	return updated, nil
+}
+
+// groupLock returns the mutex serializing summary updates for a group
+func (s *SummaryService) groupLock(groupJID string) *sync.Mutex {
+	lock, _ := s.groupLocks.LoadOrStore(groupJID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// buildSummaryPrompt renders the previous summary and new messages as a transcript
+func buildSummaryPrompt(summary *domain.ConversationSummary, messages []*domain.Message) string {
+	var b strings.Builder
+
+	b.WriteString("Previous summary:\n")
+	if summary != nil && summary.Content != "" {
+		b.WriteString(summary.Content)
+	} else {
+		b.WriteString("(none)")
+	}
+
+	b.WriteString("\n\nNew messages:\n")
+	for _, msg := range messages {
+		chatMsg := toChatMessage(msg)
+		name := chatMsg.SenderName
+		if chatMsg.Role == domain.ChatRoleAssistant {
+			name = "Bot"
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", msg.Timestamp.Format("2006-01-02 15:04"), name, msg.Content)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// MockSummaryRepository is a mock implementation of SummaryRepository
+type MockSummaryRepository struct {
+	summaries map[string]*domain.ConversationSummary
+}
+
+func (m *MockSummaryRepository) Get(ctx context.Context, groupJID string) (*domain.ConversationSummary, error) {
+	return m.summaries[groupJID], nil
+}
+
+func (m *MockSummaryRepository) Save(ctx context.Context, summary *domain.ConversationSummary) error {
+	m.summaries[summary.GroupJID] = summary
+	return nil
+}
+
+func (m *MockSummaryRepository) Delete(ctx context.Context, groupJID string) error {
+	delete(m.summaries, groupJID)
+	return nil
+}
+
+func TestSummaryService_SummarizeGroup(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	groupJID := "group1@g.us"
+
+	base := time.Now().Add(-time.Hour)
+	repo := &MockMessageRepository{}
+	for i := 0; i < 6; i++ {
+		repo.Save(ctx, &domain.Message{
+			ID:         fmt.Sprintf("msg%d", i),
+			GroupJID:   groupJID,
+			Sender:     "user@s.whatsapp.net",
+			SenderName: "Alice",
+			Content:    fmt.Sprintf("message %d", i),
+			Timestamp:  base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	llm := &MockLLMProvider{response: "Alice talked about messages 0-3."}
+	summaries := &MockSummaryRepository{summaries: make(map[string]*domain.ConversationSummary)}
+	service := NewSummaryService(llm, repo, summaries, &MockGroupManager{}, domain.SummaryConfig{KeepRecent: 2, MinMessages: 3}, logger)
+
+	summary, err := service.SummarizeGroup(ctx, groupJID)
+	if err != nil {
+		t.Fatalf("SummarizeGroup() error = %v", err)
+	}
+	if summary == nil || summary.Content != "Alice talked about messages 0-3." {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	if summary.MessageCount != 4 || !summary.CoveredUntil.Equal(base.Add(3*time.Minute)) {
+		t.Errorf("Expected 4 messages covered until message 3, got %+v", summary)
+	}
+
+	prompt := llm.lastRequest.Messages[0].Content
+	if !strings.Contains(prompt, "Alice: message 3") || strings.Contains(prompt, "message 4") {
+		t.Errorf("Expected only older messages in prompt, got %q", prompt)
+	}
+
+	// Not enough new history: summary stays unchanged and the LLM is not called
+	llm.lastRequest = nil
+	if _, err := service.SummarizeGroup(ctx, groupJID); err != nil {
+		t.Fatalf("SummarizeGroup() error = %v", err)
+	}
+	if llm.lastRequest != nil {
+		t.Error("Expected no LLM call without new history")
+	}
+
+	if err := service.ResetSummary(ctx, groupJID); err != nil {
+		t.Fatalf("ResetSummary() error = %v", err)
+	}
+	if summary, _ := service.GetSummary(ctx, groupJID); summary != nil {
+		t.Errorf("Expected summary to be reset, got %+v", summary)
+	}
+}
+
+func TestChatService_ProcessMessage_WithSummary(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	groupJID := "group1@g.us"
+
+	base := time.Now().Add(-time.Hour)
+	repo := &MockMessageRepository{}
+	repo.Save(ctx, &domain.Message{ID: "old", GroupJID: groupJID, Sender: "user@s.whatsapp.net", Content: "Old topic", Timestamp: base})
+
+	summaries := &MockSummaryRepository{summaries: map[string]*domain.ConversationSummary{
+		groupJID: {GroupJID: groupJID, Content: "They planned a trip.", CoveredUntil: base},
+	}}
+
+	llm := &MockLLMProvider{response: "Sure"}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{groupJID: true}}
+	service := NewChatService(llm, repo, &MockWhatsAppClient{}, groupMgr, &MockWebhookClient{}, []string{"@bot"}, nil, logger)
+	service.SetSummaryRepository(summaries)
+
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "new",
+		GroupJID:  groupJID,
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@bot Where are we going?",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	if !strings.Contains(llm.lastRequest.SystemPrompt, "They planned a trip.") {
+		t.Errorf("Expected summary in system prompt, got %q", llm.lastRequest.SystemPrompt)
+	}
+	if len(llm.lastRequest.Messages) != 1 {
+		t.Errorf("Expected summarized history to be dropped, got %+v", llm.lastRequest.Messages)
+	}
+}
+
+func TestSummaryService_UpdateEnabled(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	summaries := &MockSummaryRepository{summaries: make(map[string]*domain.ConversationSummary)}
+	service := NewSummaryService(&MockLLMProvider{}, &MockMessageRepository{}, summaries, &MockGroupManager{}, domain.SummaryConfig{}, logger)
+	defer service.Stop()
+
+	running := func() bool {
+		service.mu.RLock()
+		defer service.mu.RUnlock()
+		return service.running
+	}
+
+	// Toggling summary.enabled starts and stops the service without a restart
+	for _, enabled := range []bool{true, true, false, true} {
+		service.UpdateEnabled(ctx, enabled)
+		if running() != enabled {
+			t.Fatalf("Expected running = %v", enabled)
+		}
+	}
+}
+
+func TestSummaryService_UpdateConfigInterval(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	groupMgr := &countingGroupManager{calls: make(chan struct{}, 10)}
+	summaries := &MockSummaryRepository{summaries: make(map[string]*domain.ConversationSummary)}
+	service := NewSummaryService(&MockLLMProvider{}, &MockMessageRepository{}, summaries, groupMgr, domain.SummaryConfig{Interval: "1h"}, logger)
+
+	service.UpdateEnabled(context.Background(), true)
+	defer service.Stop()
+
+	// A shorter interval applies to the running service
+	service.UpdateConfig(domain.SummaryConfig{Interval: "10ms"})
+	select {
+	case <-groupMgr.calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a summary run with the new interval")
+	}
+}
+
+// countingGroupManager signals every time the allowed groups are listed
+type countingGroupManager struct {
+	MockGroupManager
+	calls chan struct{}
+}
+
+func (m *countingGroupManager) GetAllowedGroups() []string {
+	select {
+	case m.calls <- struct{}{}:
+	default:
+	}
+	return nil
+}
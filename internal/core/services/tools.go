@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// Tool is a function the LLM can call while answering a message
+type Tool interface {
+	Definition() domain.ToolDefinition
+	// Execute runs the tool with JSON-encoded arguments; message is the chat message being answered
+	Execute(ctx context.Context, arguments string, message *domain.Message) (string, error)
+}
+
+// ToolRegistry holds the tools exposed to the LLM
+type ToolRegistry struct {
+	builtIns map[string]Tool
+	webhooks map[string]Tool
+	mu       sync.RWMutex
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		builtIns: make(map[string]Tool),
+		webhooks: make(map[string]Tool),
+	}
+}
+
+// Register adds a built-in tool, replacing any tool with the same name
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.builtIns[tool.Definition().Name] = tool
+}
+
+// UpdateWebhooks replaces the webhook-backed tools with those marked as tools in configs.
+// Webhooks whose tool name is already taken by a built-in or another webhook are left out
+// and reported in the returned error.
+func (r *ToolRegistry) UpdateWebhooks(configs []domain.WebhookConfig, client domain.WebhookClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhooks := make(map[string]Tool)
+	var errs []error
+	for _, cfg := range configs {
+		if !cfg.Tool {
+			continue
+		}
+		tool := newWebhookTool(cfg, client)
+		name := tool.Definition().Name
+		if _, ok := r.builtIns[name]; ok {
+			errs = append(errs, fmt.Errorf("webhook %q: tool name %q is taken by a built-in tool", cfg.SubTrigger, name))
+			continue
+		}
+		if _, ok := webhooks[name]; ok {
+			errs = append(errs, fmt.Errorf("webhook %q: tool name %q is taken by another webhook", cfg.SubTrigger, name))
+			continue
+		}
+		webhooks[name] = tool
+	}
+
+	r.webhooks = webhooks
+	return errors.Join(errs...)
+}
+
+// Definitions returns the definitions of all registered tools, sorted by name
+func (r *ToolRegistry) Definitions() []domain.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]domain.ToolDefinition, 0, len(r.builtIns)+len(r.webhooks))
+	for _, tools := range []map[string]Tool{r.builtIns, r.webhooks} {
+		for _, tool := range tools {
+			definitions = append(definitions, tool.Definition())
+		}
+	}
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+// Execute runs the named tool. Failures are returned as text so the model can react to them.
+func (r *ToolRegistry) Execute(ctx context.Context, call domain.ToolCall, message *domain.Message) string {
+	r.mu.RLock()
+	tool, ok := r.builtIns[call.Name]
+	if !ok {
+		tool, ok = r.webhooks[call.Name]
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		return fmt.Sprintf("Error: unknown tool %q", call.Name)
+	}
+
+	result, err := tool.Execute(ctx, call.Arguments, message)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return result
+}
+
+// decodeArguments unmarshals tool call arguments, treating empty input as an empty object
+func decodeArguments(arguments string, v interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// ServerTimeTool reports the server's current time, so the model can resolve relative dates
+type ServerTimeTool struct{}
+
+// Definition returns the tool definition
+func (t *ServerTimeTool) Definition() domain.ToolDefinition {
+	return domain.ToolDefinition{
+		Name:        "get_server_time",
+		Description: "Get the current date, time, weekday and timezone of the server. Use it before scheduling anything relative to now.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	}
+}
+
+// Execute returns the current time
+func (t *ServerTimeTool) Execute(ctx context.Context, arguments string, message *domain.Message) (string, error) {
+	now := time.Now()
+	return fmt.Sprintf("%s (%s)", now.Format("2006-01-02 15:04:05 MST"), now.Weekday()), nil
+}
+
+// CreateScheduleTool lets the model create a schedule for the group the message came from
+type CreateScheduleTool struct {
+	scheduler *SchedulerService
+	audit     *AuditService
+	webhooks  func() []domain.WebhookConfig
+}
+
+// NewCreateScheduleTool creates the create_schedule tool. webhooks returns the current
+// webhook configs, so a schedule can target a webhook by its sub-trigger. Created schedules are
+// recorded in the audit log like those created through the API.
+func NewCreateScheduleTool(scheduler *SchedulerService, audit *AuditService, webhooks func() []domain.WebhookConfig) *CreateScheduleTool {
+	return &CreateScheduleTool{
+		scheduler: scheduler,
+		audit:     audit,
+		webhooks:  webhooks,
+	}
+}
+
+// createScheduleArgs are the arguments accepted by create_schedule
+type createScheduleArgs struct {
+	Name         string `json:"name"`
+	Message      string `json:"message"`
+	ScheduleType string `json:"schedule_type"`
+	DayOfWeek    *int   `json:"day_of_week"`
+	Month        *int   `json:"month"`
+	DayOfMonth   *int   `json:"day_of_month"`
+	Date         string `json:"date"`
+	Hour         int    `json:"hour"`
+	Minute       int    `json:"minute"`
+	Webhook      string `json:"webhook"`
+}
+
+// Definition returns the tool definition
+func (t *CreateScheduleTool) Definition() domain.ToolDefinition {
+	return domain.ToolDefinition{
+		Name: "create_schedule",
+		Description: "Create a recurring or one-time reminder in this chat. " +
+			"At the scheduled time the message is posted to the group, or sent to a webhook if one is given. " +
+			"Times are in the server's timezone.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name":          map[string]interface{}{"type": "string", "description": "Short name for the schedule"},
+				"message":       map[string]interface{}{"type": "string", "description": "Text to post (or to send to the webhook)"},
+				"schedule_type": map[string]interface{}{"type": "string", "enum": []string{"weekly", "yearly", "once"}},
+				"day_of_week":   map[string]interface{}{"type": "integer", "description": "0 = Sunday ... 6 = Saturday, for weekly"},
+				"month":         map[string]interface{}{"type": "integer", "description": "1-12, for yearly"},
+				"day_of_month":  map[string]interface{}{"type": "integer", "description": "1-31, for yearly"},
+				"date":          map[string]interface{}{"type": "string", "description": "YYYY-MM-DD, for once"},
+				"hour":          map[string]interface{}{"type": "integer", "description": "0-23"},
+				"minute":        map[string]interface{}{"type": "integer", "description": "0-59"},
+				"webhook":       map[string]interface{}{"type": "string", "description": "Optional webhook sub-trigger, e.g. @news"},
+			},
+			"required": []string{"name", "message", "schedule_type", "hour", "minute"},
+		},
+	}
+}
+
+// Execute creates the schedule
+func (t *CreateScheduleTool) Execute(ctx context.Context, arguments string, message *domain.Message) (string, error) {
+	var args createScheduleArgs
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	if args.Hour < 0 || args.Hour > 23 || args.Minute < 0 || args.Minute > 59 {
+		return "", fmt.Errorf("invalid time %02d:%02d", args.Hour, args.Minute)
+	}
+
+	schedule := &domain.Schedule{
+		Name:         args.Name,
+		GroupJID:     message.ChatJID,
+		UsePrompt:    true,
+		Prompt:       args.Message,
+		ScheduleType: args.ScheduleType,
+		Hour:         args.Hour,
+		Minute:       args.Minute,
+		Enabled:      true,
+	}
+	if schedule.Name == "" {
+		schedule.Name = "Reminder"
+	}
+
+	switch args.ScheduleType {
+	case "weekly":
+		if args.DayOfWeek == nil || *args.DayOfWeek < 0 || *args.DayOfWeek > 6 {
+			return "", fmt.Errorf("weekly schedules need day_of_week between 0 and 6")
+		}
+		schedule.DayOfWeek = args.DayOfWeek
+	case "yearly":
+		if args.Month == nil || args.DayOfMonth == nil {
+			return "", fmt.Errorf("yearly schedules need month and day_of_month")
+		}
+		schedule.Month = args.Month
+		schedule.DayOfMonth = args.DayOfMonth
+	case "once":
+		date, err := time.ParseInLocation("2006-01-02", args.Date, time.Local)
+		if err != nil {
+			return "", fmt.Errorf("once schedules need date as YYYY-MM-DD: %w", err)
+		}
+		schedule.SpecificDate = &date
+	default:
+		return "", fmt.Errorf("schedule_type must be weekly, yearly or once")
+	}
+
+	if args.Webhook != "" {
+		for _, webhook := range t.webhooks() {
+			if webhook.SubTrigger == args.Webhook {
+				schedule.WebhookURL = webhook.URL
+				break
+			}
+		}
+		if schedule.WebhookURL == "" {
+			return "", fmt.Errorf("unknown webhook %q", args.Webhook)
+		}
+	}
+
+	if err := t.scheduler.CreateSchedule(ctx, schedule); err != nil {
+		return "", err
+	}
+	t.audit.Record(ctx, scheduleToolActor(message), AuditScheduleCreate, schedule.ID, nil, schedule)
+
+	return fmt.Sprintf("Created schedule %q (id %s)", schedule.Name, schedule.ID), nil
+}
+
+// scheduleToolActor names the chat user whose message made the model create a schedule
+func scheduleToolActor(message *domain.Message) string {
+	if message.SenderName == "" {
+		return message.Sender
+	}
+	return message.SenderName + " (" + message.Sender + ")"
+}
+
+// webhookTool exposes a configured webhook as a tool taking a single message argument
+type webhookTool struct {
+	config domain.WebhookConfig
+	client domain.WebhookClient
+}
+
+// newWebhookTool creates a tool for a webhook config
+func newWebhookTool(config domain.WebhookConfig, client domain.WebhookClient) *webhookTool {
+	return &webhookTool{config: config, client: client}
+}
+
+// Definition returns the tool definition, named after the webhook's sub-trigger
+func (t *webhookTool) Definition() domain.ToolDefinition {
+	description := t.config.Description
+	if description == "" {
+		description = fmt.Sprintf("Call the %s webhook with a message and return its reply.", t.config.SubTrigger)
+	}
+
+	return domain.ToolDefinition{
+		Name:        webhookToolName(t.config.SubTrigger),
+		Description: description,
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"message": map[string]interface{}{"type": "string", "description": "The request to send"},
+			},
+			"required": []string{"message"},
+		},
+	}
+}
+
+// Execute calls the webhook and returns its text reply
+func (t *webhookTool) Execute(ctx context.Context, arguments string, message *domain.Message) (string, error) {
+	var args struct {
+		Message string `json:"message"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	// The client applies the webhook's timeout to each attempt, so the call is not bounded here
+	response, err := t.client.Call(ctx, &t.config, newWebhookRequest(&t.config, webhookSourceTool, args.Message, message, nil))
+	if err != nil {
+		return "", fmt.Errorf("failed to call webhook: %w", err)
+	}
+
+	if response.ContentType != "text" && response.TextContent == "" {
+		return fmt.Sprintf("The webhook returned %s content, which cannot be shown here.", response.ContentType), nil
+	}
+	return response.TextContent, nil
+}
+
+// webhookToolName derives a function name from a sub-trigger, e.g. "@web" -> "web"
+func webhookToolName(subTrigger string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return -1
+		}
+	}, subTrigger)
+
+	if name == "" {
+		return "webhook"
+	}
+	return name
+}
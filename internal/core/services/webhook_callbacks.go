@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+const (
+	// defaultCallbackTimeout is how long an async webhook has to post its result
+	defaultCallbackTimeout = time.Hour
+
+	// defaultCallbackAck is the reply sent while an async webhook is working
+	defaultCallbackAck = "Working on it…"
+
+	// callbackTimeoutMessage is sent when an async webhook never posts its result
+	callbackTimeoutMessage = "Sorry, this is taking too long. Please try again later."
+)
+
+var (
+	// ErrCallbackNotFound is returned for a callback that is unknown, completed or expired
+	ErrCallbackNotFound = errors.New("callback not found or expired")
+
+	// ErrInvalidCallback is returned for a callback result that cannot be read
+	ErrInvalidCallback = errors.New("invalid callback result")
+
+	// ErrCallbackUnauthorized is returned for a callback result not signed with the webhook's secret
+	ErrCallbackUnauthorized = errors.New("callback signature invalid")
+)
+
+// CallbackResult is what an async webhook posts to its callback: a body in the format of a
+// synchronous webhook response, signed like the call to the webhook
+type CallbackResult struct {
+	ContentType        string
+	ContentDisposition string
+	Timestamp          string
+	Signature          string
+	Body               []byte
+}
+
+// pendingCallback is an async webhook call waiting for its result
+type pendingCallback struct {
+	webhook domain.WebhookConfig
+	message *domain.Message
+	expires time.Time
+	timer   *time.Timer
+}
+
+// callbackRegistry tracks the async webhook calls waiting for their result. Pending
+// callbacks are kept in memory and do not survive a restart.
+type callbackRegistry struct {
+	pending map[string]*pendingCallback
+	mu      sync.Mutex
+}
+
+// newCallbackRegistry creates an empty callback registry
+func newCallbackRegistry() *callbackRegistry {
+	return &callbackRegistry{pending: make(map[string]*pendingCallback)}
+}
+
+// add registers a callback and calls expire if it is not completed by callback.expires
+func (r *callbackRegistry) add(id string, callback *pendingCallback, expire func(*pendingCallback)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	callback.timer = time.AfterFunc(time.Until(callback.expires), func() {
+		if expired := r.take(id); expired != nil {
+			expire(expired)
+		}
+	})
+	r.pending[id] = callback
+}
+
+// get returns a pending callback without removing it, or nil if it is unknown
+func (r *callbackRegistry) get(id string) *pendingCallback {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pending[id]
+}
+
+// take removes a pending callback, returning nil if it is unknown or already taken
+func (r *callbackRegistry) take(id string) *pendingCallback {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	callback, ok := r.pending[id]
+	if !ok {
+		return nil
+	}
+	callback.timer.Stop()
+	delete(r.pending, id)
+	return callback
+}
+
+// UpdatePublicURL updates the base URL async webhooks post their results to
+func (s *ChatService) UpdatePublicURL(publicURL string) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.publicURL = strings.TrimRight(publicURL, "/")
+}
+
+// callWebhookAsync calls an async webhook with a callback URL and acknowledges the message;
+// the result is sent when the workflow posts it to the callback
+func (s *ChatService) callWebhookAsync(ctx context.Context, message *domain.Message, webhook *domain.WebhookConfig, request *domain.WebhookRequest) error {
+	s.configMu.RLock()
+	publicURL := s.publicURL
+	s.configMu.RUnlock()
+
+	timeout := defaultCallbackTimeout
+	if parsed, err := time.ParseDuration(webhook.CallbackTimeout); err == nil && parsed > 0 {
+		timeout = parsed
+	}
+
+	// Acknowledge and register before calling, as the result may arrive before the call returns
+	ack := webhook.AckMessage
+	if ack == "" {
+		ack = defaultCallbackAck
+	}
+	if err := s.whatsapp.SendReply(ctx, message.ChatJID, ack, message.ID, message.Sender); err != nil {
+		s.logger.Error("Failed to send acknowledgement", "error", err)
+	}
+
+	id := uuid.New().String()
+	request.CallbackID = id
+	request.CallbackURL = publicURL + "/api/callbacks/" + id
+	s.callbacks.add(id, &pendingCallback{webhook: *webhook, message: message, expires: time.Now().Add(timeout)}, s.expireCallback)
+
+	if _, err := s.webhookClient.Call(ctx, webhook, request); err != nil {
+		s.callbacks.take(id)
+		s.logger.Error("Failed to call async webhook", "error", err, "url", webhook.URL)
+
+		if err := s.whatsapp.SendReply(ctx, message.ChatJID, technicalErrorMessage, message.ID, message.Sender); err != nil {
+			s.logger.Error("Failed to send error message", "error", err)
+		}
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+
+	s.logger.Info("Async webhook accepted", "sub_trigger", webhook.SubTrigger, "callback_id", id, "timeout", timeout)
+	return nil
+}
+
+// CompleteCallback sends the result an async webhook posted to its callback as a reply
+// to the message that triggered it
+func (s *ChatService) CompleteCallback(ctx context.Context, id string, result CallbackResult) error {
+	pending := s.callbacks.get(id)
+	if pending == nil {
+		return ErrCallbackNotFound
+	}
+
+	// Knowing the callback URL is not enough, the result must be signed with the webhook's secret
+	if err := s.webhookClient.VerifySignature(&pending.webhook, result.Timestamp, result.Signature, result.Body); err != nil {
+		s.logger.Warn("Rejected async webhook result", "sub_trigger", pending.webhook.SubTrigger, "callback_id", id, "error", err)
+		return fmt.Errorf("%w: %v", ErrCallbackUnauthorized, err)
+	}
+
+	response, err := s.webhookClient.ParseResponse(ctx, result.ContentType, result.ContentDisposition, result.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+
+	// Each callback is delivered once
+	callback := s.callbacks.take(id)
+	if callback == nil {
+		return ErrCallbackNotFound
+	}
+
+	message := callback.message
+	s.logger.Info("Async webhook result received", "sub_trigger", callback.webhook.SubTrigger, "callback_id", id, "type", response.ContentType)
+
+	target := webhookTarget{chatJID: message.ChatJID, messageID: message.ID, sender: message.Sender}
+	responseContent, err := sendWebhookResponse(ctx, s.whatsapp, s.logger, target, response, s.webhookAsk(message.ChatJID))
+	if err != nil {
+		// Keep the callback so the workflow can post the result again
+		s.logger.Error("Failed to send webhook response", "error", err)
+		s.callbacks.add(id, callback, s.expireCallback)
+		return err
+	}
+
+	s.saveWebhookReply(ctx, message, responseContent)
+	return nil
+}
+
+// expireCallback tells the user that an async webhook never posted its result
+func (s *ChatService) expireCallback(callback *pendingCallback) {
+	message := callback.message
+	s.logger.Warn("Async webhook result timed out", "sub_trigger", callback.webhook.SubTrigger, "message_id", message.ID)
+
+	if err := s.whatsapp.SendReply(context.Background(), message.ChatJID, callbackTimeoutMessage, message.ID, message.Sender); err != nil {
+		s.logger.Error("Failed to send timeout message", "error", err)
+	}
+}
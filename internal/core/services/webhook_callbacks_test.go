@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+func TestChatService_ProcessMessage_AsyncWebhook(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	repository := &MockMessageRepository{}
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	webhookClient := &MockWebhookClient{}
+	webhooks := []domain.WebhookConfig{{SubTrigger: "#report", URL: "http://n8n/webhook/report", Async: true, Secret: "s3cret"}}
+
+	service := NewChatService(&MockLLMProvider{}, repository, whatsapp, groupMgr, webhookClient, []string{"@sasi"}, webhooks, logger)
+	service.UpdatePublicURL("http://bot.local:8080/")
+
+	err := service.ProcessMessage(ctx, &domain.Message{
+		ID:        "msg1",
+		GroupJID:  "test-group@g.us",
+		Sender:    "user@s.whatsapp.net",
+		Content:   "@sasi #report yearly",
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	request := webhookClient.lastRequest
+	if request.CallbackID == "" || request.CallbackURL != "http://bot.local:8080/api/callbacks/"+request.CallbackID {
+		t.Fatalf("Expected a callback URL, got %+v", request)
+	}
+	if len(whatsapp.sentMessages) != 1 || whatsapp.sentMessages[0] != defaultCallbackAck {
+		t.Fatalf("Expected only the acknowledgement, got %v", whatsapp.sentMessages)
+	}
+
+	// A result without the webhook's signature is refused and keeps the callback pending
+	unsigned := signed([]byte("Revenue is down"))
+	unsigned.Signature = "sha256=forged"
+	if err := service.CompleteCallback(ctx, request.CallbackID, unsigned); !errors.Is(err, ErrCallbackUnauthorized) {
+		t.Errorf("Expected ErrCallbackUnauthorized, got %v", err)
+	}
+
+	// An unreadable result keeps the callback pending
+	if err := service.CompleteCallback(ctx, request.CallbackID, signed(nil)); !errors.Is(err, ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback, got %v", err)
+	}
+
+	if err := service.CompleteCallback(ctx, request.CallbackID, signed([]byte("Revenue is up 12%"))); err != nil {
+		t.Fatalf("CompleteCallback() error = %v", err)
+	}
+	if len(whatsapp.sentMessages) != 2 || whatsapp.sentMessages[1] != "Revenue is up 12%" {
+		t.Errorf("Expected the result as a reply, got %v", whatsapp.sentMessages)
+	}
+	if last := repository.messages[len(repository.messages)-1]; !last.IsFromBot || !strings.Contains(last.Content, "Revenue") {
+		t.Errorf("Expected the result to be saved, got %+v", last)
+	}
+
+	if err := service.CompleteCallback(ctx, request.CallbackID, signed([]byte("again"))); !errors.Is(err, ErrCallbackNotFound) {
+		t.Errorf("Expected a callback to be delivered once, got %v", err)
+	}
+	if err := service.CompleteCallback(ctx, "unknown", signed([]byte("hi"))); !errors.Is(err, ErrCallbackNotFound) {
+		t.Errorf("Expected ErrCallbackNotFound, got %v", err)
+	}
+}
+
+// signed returns a plain text callback result with the signature MockWebhookClient accepts
+func signed(body []byte) CallbackResult {
+	return CallbackResult{ContentType: "text/plain", Timestamp: "1700000000", Signature: "sha256=valid", Body: body}
+}
+
+func TestChatService_CompleteCallback_SendFails(t *testing.T) {
+	ctx := context.Background()
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	webhookClient := &MockWebhookClient{}
+	webhooks := []domain.WebhookConfig{{SubTrigger: "#report", URL: "http://n8n/webhook/report", Async: true, Secret: "s3cret"}}
+	service := NewChatService(&MockLLMProvider{}, &MockMessageRepository{}, whatsapp, groupMgr, webhookClient, []string{"@sasi"}, webhooks, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	message := &domain.Message{ID: "msg1", GroupJID: "test-group@g.us", ChatJID: "test-group@g.us", Sender: "user@s.whatsapp.net", Content: "@sasi #report", Timestamp: time.Now()}
+	if err := service.ProcessMessage(ctx, message); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	id := webhookClient.lastRequest.CallbackID
+
+	// A result that could not be sent may be posted again
+	whatsapp.replyErr = errors.New("not connected")
+	if err := service.CompleteCallback(ctx, id, signed([]byte("done"))); err == nil {
+		t.Fatal("Expected the failed send to be reported")
+	}
+	whatsapp.replyErr = nil
+	if err := service.CompleteCallback(ctx, id, signed([]byte("done"))); err != nil {
+		t.Fatalf("Expected the retried result to be delivered, got %v", err)
+	}
+	if last := whatsapp.sentMessages[len(whatsapp.sentMessages)-1]; last != "done" {
+		t.Errorf("Expected the result as the last reply, got %v", whatsapp.sentMessages)
+	}
+}
+
+func TestChatService_CompleteCallback_BeforeCallReturns(t *testing.T) {
+	ctx := context.Background()
+	whatsapp := &MockWhatsAppClient{}
+	groupMgr := &MockGroupManager{allowedGroups: map[string]bool{"test-group@g.us": true}}
+	webhookClient := &MockWebhookClient{}
+	webhooks := []domain.WebhookConfig{{SubTrigger: "#report", URL: "http://n8n/webhook/report", Async: true, Secret: "s3cret"}}
+	service := NewChatService(&MockLLMProvider{}, &MockMessageRepository{}, whatsapp, groupMgr, webhookClient, []string{"@sasi"}, webhooks, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	// The workflow posts its result while the call is still open
+	webhookClient.onCall = func(request *domain.WebhookRequest) {
+		if err := service.CompleteCallback(ctx, request.CallbackID, signed([]byte("fast result"))); err != nil {
+			t.Errorf("CompleteCallback() error = %v", err)
+		}
+	}
+
+	message := &domain.Message{ID: "msg1", GroupJID: "test-group@g.us", ChatJID: "test-group@g.us", Sender: "user@s.whatsapp.net", Content: "@sasi #report", Timestamp: time.Now()}
+	if err := service.ProcessMessage(ctx, message); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+
+	want := []string{defaultCallbackAck, "fast result"}
+	if len(whatsapp.sentMessages) != 2 || whatsapp.sentMessages[0] != want[0] || whatsapp.sentMessages[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, whatsapp.sentMessages)
+	}
+}
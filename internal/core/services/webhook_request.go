@@ -0,0 +1,85 @@
+package services
+
+import (
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// defaultWebhookContextMessages is the number of recent messages in version 2 webhook payloads
+const defaultWebhookContextMessages = 10
+
+// Sources of webhook requests
+const (
+	webhookSourceMessage  = "message"
+	webhookSourceTool     = "tool"
+	webhookSourceSchedule = "schedule"
+)
+
+// newWebhookRequest builds the payload of a webhook call about a chat message. history is the
+// recent conversation, oldest first, and is only sent in version 2 payloads.
+func newWebhookRequest(webhook *domain.WebhookConfig, source, text string, message *domain.Message, history []*domain.Message) *domain.WebhookRequest {
+	request := &domain.WebhookRequest{
+		Version:    webhookPayloadVersion(webhook),
+		Message:    text,
+		Source:     source,
+		MessageID:  message.ID,
+		ChatJID:    message.ChatJID,
+		GroupJID:   message.GroupJID,
+		GroupName:  message.GroupName,
+		IsDirect:   message.IsDirect,
+		Sender:     message.Sender,
+		SenderName: message.SenderName,
+		Timestamp:  message.Timestamp,
+	}
+
+	if message.QuotedMessageID != "" {
+		request.QuotedMessage = &domain.WebhookQuotedMessage{
+			ID:      message.QuotedMessageID,
+			Sender:  message.QuotedSender,
+			Content: message.QuotedContent,
+		}
+	}
+
+	if media := message.Media; media != nil {
+		request.Media = &domain.WebhookMediaReference{
+			Type:      media.Type,
+			MimeType:  media.MimeType,
+			FileName:  media.FileName,
+			Size:      len(media.Data),
+			MessageID: media.MessageID,
+		}
+	}
+
+	for _, msg := range history {
+		if msg.ID == message.ID {
+			continue
+		}
+		request.Context = append(request.Context, domain.WebhookContextMessage{
+			Sender:     msg.Sender,
+			SenderName: msg.SenderName,
+			Content:    msg.Content,
+			IsFromBot:  msg.IsFromBot,
+			Timestamp:  msg.Timestamp,
+		})
+	}
+
+	return request
+}
+
+// webhookPayloadVersion returns the payload version a webhook is called with, default 1
+func webhookPayloadVersion(webhook *domain.WebhookConfig) int {
+	if webhook.PayloadVersion < domain.WebhookPayloadV1 {
+		return domain.WebhookPayloadV1
+	}
+	return webhook.PayloadVersion
+}
+
+// webhookContextLimit returns how many recent messages a webhook's payload includes
+func webhookContextLimit(webhook *domain.WebhookConfig) int {
+	if webhook.PayloadVersion < domain.WebhookPayloadV2 {
+		return 0
+	}
+	if webhook.ContextMessages > 0 {
+		return webhook.ContextMessages
+	}
+	return defaultWebhookContextMessages
+}
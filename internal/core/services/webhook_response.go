@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/vibin/whatsapp-llm-bot/internal/core/domain"
+)
+
+// webhookTarget is the chat a webhook response is sent to and the message it answers, if any
+type webhookTarget struct {
+	chatJID   string
+	messageID string // empty for scheduled webhooks
+	sender    string
+}
+
+// askFunc answers the prompt of an "ask" part with the LLM
+type askFunc func(ctx context.Context, prompt string) (string, error)
+
+// webhookParts returns the parts of a response; single text or media responses become one part
+func webhookParts(response *domain.WebhookResponse) []domain.WebhookResponsePart {
+	if len(response.Parts) > 0 {
+		return response.Parts
+	}
+	if response.Media != nil {
+		return []domain.WebhookResponsePart{{Type: domain.WebhookPartMedia, Media: response.Media}}
+	}
+	return []domain.WebhookResponsePart{{Type: domain.WebhookPartText, Text: response.TextContent}}
+}
+
+// sendWebhookResponse sends each part of a webhook response in order. Only the first message
+// quotes the triggering message. Reactions need a triggering message and ask parts need ask,
+// otherwise they are skipped. It returns what was sent, for message history and execution logs.
+func sendWebhookResponse(ctx context.Context, whatsapp domain.WhatsAppClient, logger *slog.Logger, target webhookTarget, response *domain.WebhookResponse, ask askFunc) (string, error) {
+	var sent []string
+	replyTo := target.messageID
+
+	sendText := func(text string, mentions []string) error {
+		formattedText := FormatWebhookResponse(text)
+		if strings.TrimSpace(formattedText) == "" {
+			return nil
+		}
+
+		var err error
+		switch {
+		case len(mentions) > 0:
+			err = whatsapp.SendMentions(ctx, target.chatJID, formattedText, mentions, replyTo, target.sender)
+		case replyTo != "":
+			err = whatsapp.SendReply(ctx, target.chatJID, formattedText, replyTo, target.sender)
+		default:
+			err = whatsapp.SendMessage(ctx, target.chatJID, formattedText)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		replyTo = ""
+		sent = append(sent, formattedText)
+		return nil
+	}
+
+	for _, part := range webhookParts(response) {
+		switch part.Type {
+		case domain.WebhookPartText:
+			if err := sendText(part.Text, part.Mentions); err != nil {
+				return strings.Join(sent, "\n"), err
+			}
+
+		case domain.WebhookPartMedia:
+			caption := FormatWebhookResponse(part.Text)
+			logger.Info("Sending webhook media", "type", part.Media.Type, "size", len(part.Media.Data), "mime", part.Media.MimeType, "chat", target.chatJID)
+			if err := whatsapp.SendMedia(ctx, target.chatJID, part.Media, caption, replyTo, target.sender); err != nil {
+				return strings.Join(sent, "\n"), fmt.Errorf("failed to send %s: %w", part.Media.Type, err)
+			}
+			replyTo = ""
+
+			label := mediaSentLabel(part.Media)
+			if caption != "" {
+				label += " " + caption
+			}
+			sent = append(sent, label)
+
+		case domain.WebhookPartReaction:
+			if target.messageID == "" {
+				logger.Debug("Skipping webhook reaction without a message to react to", "chat", target.chatJID)
+				continue
+			}
+			if err := whatsapp.SendReaction(ctx, target.chatJID, target.messageID, target.sender, part.Emoji); err != nil {
+				return strings.Join(sent, "\n"), err
+			}
+
+		case domain.WebhookPartAsk:
+			if ask == nil {
+				logger.Warn("Skipping webhook ask part, no LLM available", "chat", target.chatJID)
+				continue
+			}
+			answer, err := ask(ctx, part.Text)
+			if err != nil {
+				return strings.Join(sent, "\n"), fmt.Errorf("failed to answer webhook prompt: %w", err)
+			}
+			if err := sendText(answer, nil); err != nil {
+				return strings.Join(sent, "\n"), err
+			}
+		}
+	}
+
+	return strings.Join(sent, "\n"), nil
+}
+
+// mediaSentLabel describes sent media in message history, e.g. "[Image sent]"
+func mediaSentLabel(media *domain.Media) string {
+	label := string(media.Type)
+	label = strings.ToUpper(label[:1]) + label[1:]
+	if media.Type == domain.MediaTypeDocument && media.FileName != "" {
+		return fmt.Sprintf("[%s sent: %s]", label, media.FileName)
+	}
+	return fmt.Sprintf("[%s sent]", label)
+}
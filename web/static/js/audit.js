@@ -0,0 +1,82 @@
+// Load audit entries matching the filters
+async function loadAuditLog() {
+    const params = new URLSearchParams({ limit: 200 });
+
+    const actor = document.getElementById('actor-filter').value.trim();
+    const action = document.getElementById('action-filter').value;
+    const since = document.getElementById('since-filter').value;
+
+    if (actor) params.set('actor', actor);
+    if (action) params.set('action', action);
+    if (since) params.set('since', new Date(since + 'T00:00:00').toISOString());
+
+    try {
+        const response = await fetch(`/api/audit?${params}`);
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        displayEntries(await response.json());
+    } catch (error) {
+        console.error('Error loading audit log:', error);
+        showError('Failed to load audit log');
+    }
+}
+
+// Display audit entries
+function displayEntries(entries) {
+    const tbody = document.getElementById('audit-tbody');
+    tbody.innerHTML = '';
+
+    if (entries.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="5" style="text-align: center;">No audit entries found</td></tr>';
+        return;
+    }
+
+    entries.forEach(entry => {
+        const row = document.createElement('tr');
+        const isDelete = entry.action.endsWith('.delete');
+
+        row.innerHTML = `
+            <td>${new Date(entry.timestamp).toLocaleString()}</td>
+            <td><span class="actor">${escapeHtml(entry.actor)}</span></td>
+            <td><span class="action-badge ${isDelete ? 'delete' : ''}">${escapeHtml(entry.action)}</span></td>
+            <td>${escapeHtml(entry.target || '-')}</td>
+            <td>
+                <div class="diff-text" title="${escapeHtml(entry.before || entry.after || '')}">
+                    ${escapeHtml(entry.diff || '-')}
+                </div>
+            </td>
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+// Utility function
+function escapeHtml(text) {
+    if (!text) return '';
+    const div = document.createElement('div');
+    div.textContent = text;
+    return div.innerHTML;
+}
+
+// Initialize
+document.addEventListener('DOMContentLoaded', () => {
+    // Check for filters in URL
+    const urlParams = new URLSearchParams(window.location.search);
+    if (urlParams.get('actor')) {
+        document.getElementById('actor-filter').value = urlParams.get('actor');
+    }
+    if (urlParams.get('action')) {
+        document.getElementById('action-filter').value = urlParams.get('action');
+    }
+
+    loadAuditLog();
+
+    // Add filter event listeners
+    document.getElementById('actor-filter').addEventListener('change', loadAuditLog);
+    document.getElementById('action-filter').addEventListener('change', loadAuditLog);
+    document.getElementById('since-filter').addEventListener('change', loadAuditLog);
+
+    // Auto-refresh every 30 seconds
+    setInterval(loadAuditLog, 30000);
+});
@@ -0,0 +1,66 @@
+// API Authentication
+// Adds a stored API token or OIDC access token to admin API calls. Basic auth users are
+// asked for their password by the browser; bearer-only setups are asked for a token here.
+const API_TOKEN_KEY = 'apiToken';
+
+const originalFetch = window.fetch.bind(window);
+
+// isAPIRequest reports whether a fetch goes to this server's API
+function isAPIRequest(input) {
+    const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+    return url.origin === window.location.origin && url.pathname.startsWith('/api/');
+}
+
+// withToken returns fetch options carrying the stored token, unless they set their own
+function withToken(init) {
+    const token = localStorage.getItem(API_TOKEN_KEY);
+    const headers = new Headers((init && init.headers) || {});
+    if (token && !headers.has('Authorization')) {
+        headers.set('Authorization', `Bearer ${token}`);
+    }
+    return { ...init, headers };
+}
+
+window.fetch = async function (input, init) {
+    if (!isAPIRequest(input)) {
+        return originalFetch(input, init);
+    }
+
+    const options = withToken(init);
+    const response = await originalFetch(input, options);
+    const challenge = response.headers.get('WWW-Authenticate') || '';
+    if (response.status !== 401 || !challenge.startsWith('Bearer')) {
+        return response;
+    }
+
+    // The token is missing, expired or wrong: ask for one and try once more
+    const token = await askForToken(options.headers.get('Authorization'));
+    if (!token) {
+        return response;
+    }
+    return originalFetch(input, withToken(init));
+};
+
+// Requests made together share one prompt
+let tokenPrompt = null;
+
+// askForToken prompts for a new token unless another request already got one
+function askForToken(rejected) {
+    const current = localStorage.getItem(API_TOKEN_KEY);
+    if (current && rejected !== `Bearer ${current}`) {
+        return Promise.resolve(current);
+    }
+
+    if (!tokenPrompt) {
+        tokenPrompt = Promise.resolve().then(() => {
+            localStorage.removeItem(API_TOKEN_KEY);
+            const token = (window.prompt('This bot requires an API token or OIDC access token:') || '').trim();
+            if (token) {
+                localStorage.setItem(API_TOKEN_KEY, token);
+            }
+            tokenPrompt = null;
+            return token;
+        });
+    }
+    return tokenPrompt;
+}
@@ -92,6 +92,7 @@ function displayExecutions(executions) {
                 <span class="status-badge ${exec.success ? 'success' : 'failed'}">
                     ${exec.success ? '✓ Success' : '✗ Failed'}
                 </span>
+                ${exec.attempts > 1 ? `<small title="Webhook calls including retries">${exec.attempts} attempts</small>` : ''}
             </td>
             <td>
                 <div class="response-preview" title="${escapeHtml(exec.response || '')}">